@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zakkor/server/toolfns"
+)
+
+// auditEntry is a line of the audit log, written for each tool call.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	ChatID     string    `json:"chat_id"`
+	Tool       string    `json:"tool"`
+	Arguments  any       `json:"arguments"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Redactions int       `json:"redactions"`
+}
+
+// AuditLog records the tool calls, one JSON object per line. Secrets are
+// removed from the arguments that are logged.
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{enc: json.NewEncoder(f)}, nil
+}
+
+// Hook returns a tool hook that logs each call once it has completed. It must
+// be registered before toolfns.RedactSecrets, so that it sees the number of
+// secrets removed from the result.
+func (a *AuditLog) Hook(next toolfns.Invoker) toolfns.Invoker {
+	return func(call *toolfns.Call) (any, error) {
+		args, _ := toolfns.Redact(call.Args)
+
+		start := time.Now()
+		out, err := next(call)
+
+		entry := auditEntry{
+			Time:       start.UTC(),
+			ChatID:     call.ChatID,
+			Tool:       call.Name,
+			Arguments:  args,
+			DurationMS: time.Since(start).Milliseconds(),
+			Redactions: call.Redactions,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if err := a.enc.Encode(entry); err != nil {
+			log.Printf("audit log: %v", err)
+		}
+		return out, err
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zakkor/server/toolfns"
+)
+
+// maxUploadSize is the largest request accepted by FileHandler.Upload.
+const maxUploadSize = 256 << 20
+
+type FileHandler struct {
+	Workspaces *toolfns.Workspaces
+}
+
+// Upload saves the files of a multipart form into the workspace of the chat
+// named by the chat_id field, in the directory named by the optional path
+// field. It responds with a reference to each file saved.
+func (fh *FileHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		ws   *toolfns.Workspace
+		dir  string
+		refs = []*toolfns.FileReference{}
+	)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Fields must come before the files they apply to.
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 4096))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			switch part.FormName() {
+			case "chat_id":
+				if ws, err = fh.Workspaces.Get(string(value)); err != nil {
+					workspaceError(w, err)
+					return
+				}
+			case "path":
+				dir = string(value)
+			}
+			continue
+		}
+
+		if ws == nil {
+			http.Error(w, "chat_id must be sent before the files", http.StatusBadRequest)
+			return
+		}
+		rel := path.Join(dir, filepath.Base(filepath.FromSlash(part.FileName())))
+		if err := saveUpload(ws, rel, part); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, fmt.Sprintf("upload is larger than %d MB", maxUploadSize>>20), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ref, err := ws.FileReference(rel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		http.Error(w, "no files", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(refs)
+}
+
+// saveUpload writes r to rel in the workspace, replacing the file only once it
+// has been received whole.
+func saveUpload(ws *toolfns.Workspace, rel string, r io.Reader) error {
+	dest, err := ws.Path(rel)
+	if err != nil {
+		return err
+	}
+	if dest == ws.Dir {
+		return errors.New("missing file name")
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dest)
+}
+
+// Download serves a file of a workspace. Files are shown inline, in a
+// sandbox, unless the download query parameter is set.
+func (fh *FileHandler) Download(w http.ResponseWriter, r *http.Request) {
+	ws, err := fh.Workspaces.Open(chi.URLParam(r, "chatID"))
+	if err != nil {
+		workspaceError(w, err)
+		return
+	}
+	name, err := ws.Path(chi.URLParam(r, "*"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		http.Error(w, "not a file", http.StatusBadRequest)
+		return
+	}
+
+	disposition := "inline"
+	if r.URL.Query().Has("download") {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", toolfns.FileContentType(name))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Name()}))
+	// Files are written by tools and uploaded by users, so they must not run
+	// scripts with access to the tool server.
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
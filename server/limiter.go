@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrQueueFull = errors.New("tool queue is full")
+
+// Limiter bounds the number of tool calls executing at once, both globally and
+// per tool. Calls over a limit wait in a bounded queue, and are started in the
+// order they arrived as soon as their tool has a free slot.
+type Limiter struct {
+	// Global is the maximum number of calls executing at once. 0 means unlimited.
+	Global int
+	// PerTool is the maximum number of calls executing at once, per tool name.
+	PerTool map[string]int
+	// QueueSize is the maximum number of calls waiting for a slot.
+	QueueSize int
+
+	mu      sync.Mutex
+	total   int
+	running map[string]int
+	queue   []*waiter
+}
+
+// QueuedCall describes a call waiting in the queue.
+type QueuedCall struct {
+	ID       string `json:"id"`
+	ChatID   string `json:"chat_id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+type waiter struct {
+	id, chatID, name string
+	ready            chan struct{}
+}
+
+// Acquire blocks until the named tool may execute, and returns a function that
+// must be called once it is done. It fails with ErrQueueFull if the call would
+// have to wait in a full queue, or with the context's error if it is canceled
+// while waiting.
+func (l *Limiter) Acquire(ctx context.Context, id, chatID, name string) (release func(), err error) {
+	l.mu.Lock()
+	if l.running == nil {
+		l.running = make(map[string]int)
+	}
+
+	release = func() { l.release(name) }
+	// Calls waiting for other tools don't hold this one back: they only wait
+	// while their own tool, or the global limit, is busy.
+	if l.available(name) && !l.queued(name) {
+		l.start(name)
+		l.mu.Unlock()
+		return release, nil
+	}
+
+	if len(l.queue) >= l.QueueSize {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{id: id, chatID: chatID, name: name, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	toolsQueued.WithLabelValues(name).Inc()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return release, nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		for i, qw := range l.queue {
+			if qw == w {
+				l.queue = append(l.queue[:i], l.queue[i+1:]...)
+				toolsQueued.WithLabelValues(name).Dec()
+				l.mu.Unlock()
+				return nil, ctx.Err()
+			}
+		}
+		l.mu.Unlock()
+
+		// The slot was handed over before we could leave the queue.
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// Queue returns the calls currently waiting, in order.
+func (l *Limiter) Queue() []QueuedCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	calls := make([]QueuedCall, len(l.queue))
+	for i, w := range l.queue {
+		calls[i] = QueuedCall{
+			ID:       w.id,
+			ChatID:   w.chatID,
+			Name:     w.name,
+			Position: i + 1,
+		}
+	}
+	return calls
+}
+
+func (l *Limiter) release(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	l.running[name]--
+
+	// Start waiting calls in order, skipping those whose tool is still busy,
+	// until we run out of global slots.
+	for i := 0; i < len(l.queue); {
+		w := l.queue[i]
+		if l.Global > 0 && l.total >= l.Global {
+			return
+		}
+		if !l.available(w.name) {
+			i++
+			continue
+		}
+
+		l.queue = append(l.queue[:i], l.queue[i+1:]...)
+		toolsQueued.WithLabelValues(w.name).Dec()
+		l.start(w.name)
+		close(w.ready)
+	}
+}
+
+func (l *Limiter) available(name string) bool {
+	if l.Global > 0 && l.total >= l.Global {
+		return false
+	}
+	if max := l.PerTool[name]; max > 0 && l.running[name] >= max {
+		return false
+	}
+	return true
+}
+
+// queued reports whether a call to the named tool is waiting.
+func (l *Limiter) queued(name string) bool {
+	for _, w := range l.queue {
+		if w.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Limiter) start(name string) {
+	l.total++
+	l.running[name]++
+}
+
+// toolLimits is a flag.Value collecting repeated Name=N tool limits.
+type toolLimits map[string]int
+
+func (tl toolLimits) String() string {
+	var limits []string
+	for name, max := range tl {
+		limits = append(limits, fmt.Sprintf("%s=%d", name, max))
+	}
+	return strings.Join(limits, ",")
+}
+
+func (tl toolLimits) Set(value string) error {
+	for _, limit := range strings.Split(value, ",") {
+		name, max, ok := strings.Cut(limit, "=")
+		if !ok {
+			return fmt.Errorf("invalid tool limit %q, expected Name=N", limit)
+		}
+		n, err := strconv.Atoi(max)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid tool limit %q, expected Name=N", limit)
+		}
+		tl[strings.TrimSpace(name)] = n
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquireAsync calls Acquire in the background, and waits until the call is
+// queued.
+func acquireAsync(t *testing.T, l *Limiter, ctx context.Context, id, name string) <-chan error {
+	t.Helper()
+	queued := len(l.Queue())
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, id, "chat", name)
+		done <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(l.Queue()) == queued {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not queued", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func mustAcquire(t *testing.T, l *Limiter, id, name string) func() {
+	t.Helper()
+	release, err := l.Acquire(context.Background(), id, "chat", name)
+	if err != nil {
+		t.Fatalf("%s: %v", id, err)
+	}
+	return release
+}
+
+func expectStarted(t *testing.T, done <-chan error, id string) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not started", id)
+	}
+}
+
+func expectWaiting(t *testing.T, done <-chan error, id string) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("%s was started while over the limit: %v", id, err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestLimiterGlobal(t *testing.T) {
+	l := &Limiter{Global: 2, QueueSize: 10}
+	release1 := mustAcquire(t, l, "1", "Shell")
+	mustAcquire(t, l, "2", "Search")
+
+	third := acquireAsync(t, l, context.Background(), "3", "Fetch")
+	expectWaiting(t, third, "3")
+	if q := l.Queue(); len(q) != 1 || q[0].ID != "3" || q[0].Position != 1 {
+		t.Errorf("Queue = %+v", q)
+	}
+
+	release1()
+	expectStarted(t, third, "3")
+	if q := l.Queue(); len(q) != 0 {
+		t.Errorf("Queue = %+v after the call started", q)
+	}
+}
+
+func TestLimiterPerTool(t *testing.T) {
+	l := &Limiter{PerTool: map[string]int{"Shell": 1}, QueueSize: 10}
+	release := mustAcquire(t, l, "1", "Shell")
+
+	second := acquireAsync(t, l, context.Background(), "2", "Shell")
+	expectWaiting(t, second, "2")
+
+	// Other tools aren't limited.
+	mustAcquire(t, l, "3", "Search")
+
+	release()
+	expectStarted(t, second, "2")
+}
+
+func TestLimiterQueueFull(t *testing.T) {
+	l := &Limiter{Global: 1, QueueSize: 1}
+	mustAcquire(t, l, "1", "Shell")
+	acquireAsync(t, l, context.Background(), "2", "Shell")
+
+	if _, err := l.Acquire(context.Background(), "3", "chat", "Shell"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire with a full queue = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestLimiterOtherToolsDontWait(t *testing.T) {
+	l := &Limiter{PerTool: map[string]int{"Shell": 1}, QueueSize: 10}
+	release := mustAcquire(t, l, "1", "Shell")
+	shell := acquireAsync(t, l, context.Background(), "2", "Shell")
+
+	// A call to another tool isn't queued behind the waiting Shell call.
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(context.Background(), "3", "chat", "Search")
+		done <- err
+	}()
+	expectStarted(t, done, "3")
+
+	// But a call to the same tool is, so that calls start in order.
+	shell2 := acquireAsync(t, l, context.Background(), "4", "Shell")
+	expectWaiting(t, shell2, "4")
+
+	release()
+	expectStarted(t, shell, "2")
+	expectWaiting(t, shell2, "4")
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := &Limiter{Global: 1, QueueSize: 10}
+	release := mustAcquire(t, l, "1", "Shell")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := acquireAsync(t, l, ctx, "2", "Shell")
+	cancel()
+	if err := <-waiting; !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire = %v, want context.Canceled", err)
+	}
+	if q := l.Queue(); len(q) != 0 {
+		t.Errorf("Queue = %+v after the call was canceled", q)
+	}
+
+	// The slot of the canceled call isn't lost.
+	release()
+	mustAcquire(t, l, "3", "Shell")
+}
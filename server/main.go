@@ -10,27 +10,70 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"path/filepath"
 	"syscall"
+	"time"
 
-	"github.com/byte-sat/llum-tools/schema"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/zakkor/server/toolfns"
 )
 
 var (
-	password = flag.String("password", "", "Password for basic auth.")
+	password      = flag.String("password", "", "Password for basic auth.")
+	configPath    = flag.String("config", "", "Path to a JSON configuration file for the tools.")
+	maxConcurrent = flag.Int("max-concurrent", 0, "Maximum number of tool calls executing at once. 0 means unlimited.")
+	queueSize     = flag.Int("queue-size", 64, "Maximum number of tool calls waiting for a free slot.")
+	auditLogPath  = flag.String("audit-log", "", "Path of a file to log tool calls to, as JSON lines.")
+	origins       = flag.String("origins", "https://llum.chat", "Comma-separated origins, besides localhost and the server itself, that the UI may open WebSockets from.")
+	toolLimit     = toolLimits{}
+
+	workspaceRoot   = flag.String("workspaces", "workspaces", "Directory holding the per-chat workspaces.")
+	workspaceMaxAge = flag.Duration("workspace-max-age", 7*24*time.Hour, "Remove workspaces unused for longer than this. 0 disables removal.")
 )
 
+func init() {
+	flag.Var(toolLimit, "tool-limit", "Maximum number of concurrent calls of a tool, as Name=N. Can be repeated.")
+}
+
 func main() {
 	flag.Parse()
 
+	if *configPath != "" {
+		if err := toolfns.LoadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := toolfns.LoadPlugins(); err != nil {
+		log.Fatal(err)
+	}
+	if *auditLogPath != "" {
+		auditLog, err := OpenAuditLog(*auditLogPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		toolfns.Use(auditLog.Hook)
+	}
+	toolfns.Use(toolfns.TruncateOutput, toolfns.RedactSecrets)
+	if err := toolfns.StartIndexing(); err != nil {
+		log.Fatal(err)
+	}
+
+	root, err := filepath.Abs(*workspaceRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workspaces := &toolfns.Workspaces{Root: root}
+	if *workspaceMaxAge > 0 {
+		go collectWorkspaces(workspaces, *workspaceMaxAge)
+	}
+
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 	}))
 	r.Use(authMiddleware)
@@ -39,9 +82,36 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	th := &ToolHandler{Groups: toolfns.ToolGroups}
+	th := &ToolHandler{
+		Groups:     toolfns.ToolGroups,
+		Workspaces: workspaces,
+		Limiter: &Limiter{
+			Global:    *maxConcurrent,
+			PerTool:   toolLimit,
+			QueueSize: *queueSize,
+		},
+	}
 	r.Get("/tool_schema", th.ToolSchema)
 	r.Post("/tool", th.InvokeTool)
+	r.Get("/queue", th.Queue)
+
+	wh := &WorkspaceHandler{Workspaces: workspaces}
+	r.Get("/workspaces", wh.List)
+	r.Get("/workspaces/{chatID}/tarball", wh.Tarball)
+	r.Delete("/workspaces/{chatID}", wh.Delete)
+	r.Get("/workspaces/{chatID}/checkpoints", wh.Checkpoints)
+	r.Post("/workspaces/{chatID}/checkpoints/{id}/restore", wh.Restore)
+
+	fh := &FileHandler{Workspaces: workspaces}
+	r.Post("/files", fh.Upload)
+	r.Get("/files/{chatID}/*", fh.Download)
+
+	termh := &TerminalHandler{}
+	r.Get("/terminals/{chatID}/{name}", termh.Watch)
+
+	qh := &QuestionHandler{}
+	r.Get("/questions/{chatID}", qh.Watch)
+	r.Handle("/metrics", promhttp.Handler())
 
 	fmt.Println("Tool server running at http://localhost:8081")
 	httpServer := &http.Server{Addr: ":8081", Handler: r}
@@ -61,11 +131,18 @@ func main() {
 	if err := httpServer.Shutdown(context.Background()); err != nil {
 		log.Fatal(err)
 	}
+	toolfns.StopProcesses()
+	toolfns.CloseTerminals()
+	toolfns.CloseSSH()
+	toolfns.CloseOutputs()
 }
 
 func authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if *password != "" && r.Header.Get("Authorization") != ("Basic "+*password) {
+		// Browsers can't set headers on WebSocket connections, so those pass
+		// the password as a subprotocol instead. It is never put in URLs,
+		// which end up in logs and browser history.
+		if *password != "" && r.Header.Get("Authorization") != ("Basic "+*password) && !websocketAuthorized(r) {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -74,26 +151,16 @@ func authMiddleware(next http.Handler) http.Handler {
 }
 
 type ToolHandler struct {
-	Groups []*toolfns.Group
+	Groups     []*toolfns.Group
+	Workspaces *toolfns.Workspaces
+	Limiter    *Limiter
 }
 
 func (tr *ToolHandler) ToolSchema(w http.ResponseWriter, r *http.Request) {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 
-	type encodedGroup struct {
-		Name   string            `json:"name"`
-		Schema []schema.Function `json:"schema"`
-	}
-
-	var encodedGroups []encodedGroup
-	for _, group := range tr.Groups {
-		encodedGroups = append(encodedGroups, encodedGroup{
-			Name:   group.Name,
-			Schema: group.Repo.Schema(),
-		})
-	}
-	err := enc.Encode(encodedGroups)
+	err := enc.Encode(tr.Groups)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -102,33 +169,94 @@ func (tr *ToolHandler) ToolSchema(w http.ResponseWriter, r *http.Request) {
 
 func (tr *ToolHandler) InvokeTool(w http.ResponseWriter, r *http.Request) {
 	var call struct {
+		ID     string         `json:"id"`
 		ChatID string         `json:"chat_id"`
 		Name   string         `json:"name"`
 		Args   map[string]any `json:"arguments"`
 	}
 	if err := json.NewDecoder(io.TeeReader(r.Body, os.Stdout)).Decode(&call); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		toolError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	for _, group := range tr.Groups {
-		var out any
-		out, err = group.Repo.Invoke(nil, call.Name, call.Args)
-		if err != nil {
-			if strings.HasPrefix(err.Error(), "tool not found") {
-				continue
-			}
-			json.NewEncoder(w).Encode(map[string]any{
-				"error": err.Error(),
-			})
-			return
+	group := tr.group(call.Name)
+	if group == nil {
+		toolError(w, "tool not found: "+call.Name, http.StatusBadRequest)
+		return
+	}
+
+	release, err := tr.Limiter.Acquire(r.Context(), call.ID, call.ChatID, call.Name)
+	if err == ErrQueueFull {
+		toolError(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		// The client went away while waiting.
+		return
+	}
+	defer release()
+
+	ws, err := tr.Workspaces.Get(call.ChatID)
+	if err != nil {
+		toolError(w, err.Error(), workspaceStatus(err))
+		return
+	}
+	if group.Mutates(call.Name) && call.ID != "" {
+		// Snapshot the workspace, so that the call can be undone along with
+		// the conversation branch it belongs to.
+		if _, err := tr.Workspaces.Checkpoint(ws, call.ID, call.Name); err != nil {
+			log.Printf("workspace %s: checkpoint before %s: %v", ws.ChatID, call.ID, err)
 		}
-		json.NewEncoder(w).Encode(out)
+	}
+	out, err := invoke(r.Context(), group, ws, call.ID, call.Name, call.Args)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]any{
+			"error": err.Error(),
+		})
 		return
 	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// toolError replies to a tool call that couldn't be made. The error is sent in
+// the same JSON shape as the errors of tools, so that the client shows it to
+// the model like any other.
+func toolError(w http.ResponseWriter, msg string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": msg,
+	})
+}
+
+// Queue lists the tool calls waiting for a free slot, with their position in
+// the queue.
+func (tr *ToolHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(tr.Limiter.Queue())
+}
+
+// group returns the group that contains the named tool, or nil.
+func (tr *ToolHandler) group(name string) *toolfns.Group {
+	for _, group := range tr.Groups {
+		if group.Has(name) {
+			return group
+		}
+	}
+	return nil
+}
+
+// invoke calls the named tool, recording its metrics.
+func invoke(ctx context.Context, group *toolfns.Group, ws *toolfns.Workspace, id string, name string, args map[string]any) (any, error) {
+	inFlight := toolsInFlight.WithLabelValues(name)
+	inFlight.Inc()
+	defer inFlight.Dec()
 
+	start := time.Now()
+	out, err := group.Invoke(ctx, ws, id, name, args)
+	toolDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	toolInvocations.WithLabelValues(name).Inc()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		toolErrors.WithLabelValues(name).Inc()
 	}
+	return out, err
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	toolInvocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llum_tool_invocations_total",
+		Help: "Number of tool invocations.",
+	}, []string{"tool"})
+
+	toolErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llum_tool_errors_total",
+		Help: "Number of tool invocations that returned an error.",
+	}, []string{"tool"})
+
+	toolDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llum_tool_duration_seconds",
+		Help:    "Time taken by tool invocations.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+	}, []string{"tool"})
+
+	toolsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "llum_tool_executions_in_flight",
+		Help: "Number of tool invocations currently executing.",
+	}, []string{"tool"})
+
+	toolsQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "llum_tool_executions_queued",
+		Help: "Number of tool invocations waiting for a free slot.",
+	}, []string{"tool"})
+)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zakkor/server/toolfns"
+)
+
+// questionsMessage is sent to WebSocket clients each time the questions of a
+// chat change.
+type questionsMessage struct {
+	Questions []toolfns.Question `json:"questions"`
+}
+
+type QuestionHandler struct{}
+
+// Watch sends the questions tools of a chat are waiting on over WebSocket, as
+// JSON, each time they change, and passes the answers the client sends back
+// to the tools.
+func (qh *QuestionHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chatID")
+	if err := toolfns.CheckChatID(chatID); err != nil {
+		workspaceError(w, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied.
+		return
+	}
+	defer conn.Close()
+
+	changes, stop := toolfns.WatchQuestions(chatID)
+	defer stop()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var answer toolfns.Answer
+			if err := conn.ReadJSON(&answer); err != nil {
+				return
+			}
+			// Questions that timed out or were answered elsewhere are gone.
+			if err := toolfns.AnswerQuestion(chatID, answer); err != nil {
+				log.Printf("questions %s: %s: %v", chatID, answer.ID, err)
+			}
+		}
+	}()
+
+	for {
+		if err := conn.WriteJSON(questionsMessage{Questions: toolfns.PendingQuestions(chatID)}); err != nil {
+			return
+		}
+		select {
+		case <-changes:
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/zakkor/server/toolfns"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+	// The client also offers the auth subprotocol, which must not be echoed
+	// back.
+	Subprotocols: []string{websocketProtocol},
+}
+
+const (
+	// websocketProtocol is the subprotocol the UI opens WebSockets with.
+	websocketProtocol = "llum"
+	// websocketAuthPrefix starts the subprotocol that carries the password,
+	// encoded as unpadded base64url.
+	websocketAuthPrefix = "auth."
+)
+
+// websocketAuthorized reports whether r is a WebSocket handshake offering the
+// server password as a subprotocol.
+func websocketAuthorized(r *http.Request) bool {
+	want := websocketAuthPrefix + base64.RawURLEncoding.EncodeToString([]byte(*password))
+	for _, protocol := range websocket.Subprotocols(r) {
+		if subtle.ConstantTimeCompare([]byte(protocol), []byte(want)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin lets pages served by the server itself, from localhost, or from
+// one of the -origins open WebSockets. Any page can send requests to the
+// server, but browsers don't apply CORS to WebSockets, so without this a
+// page on another site could watch and type into terminals when no password
+// is set.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser.
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	for _, allowed := range strings.Split(*origins, ",") {
+		if strings.EqualFold(strings.TrimSpace(allowed), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// terminalMessage is sent by WebSocket clients to type into a terminal, or
+// resize it.
+type terminalMessage struct {
+	Type string `json:"type"` // "input" or "resize"
+	Data string `json:"data"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+type TerminalHandler struct{}
+
+// Watch streams the screen of a terminal session over WebSocket as JSON, each
+// time it changes, and types what the client sends into the terminal.
+func (th *TerminalHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	t, err := toolfns.OpenedTerminal(chi.URLParam(r, "chatID"), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied.
+		return
+	}
+	defer conn.Close()
+
+	changes, stop := t.Changes()
+	defer stop()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg terminalMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var err error
+			switch msg.Type {
+			case "input":
+				_, err = t.Write([]byte(msg.Data))
+			case "resize":
+				err = t.Resize(msg.Cols, msg.Rows)
+			}
+			if err != nil {
+				log.Printf("terminal %s: %v", r.URL.Path, err)
+			}
+		}
+	}()
+
+	for {
+		if err := conn.WriteJSON(t.Screen()); err != nil {
+			return
+		}
+		select {
+		case <-changes:
+			// Let quick bursts of output land in a single update.
+			time.Sleep(20 * time.Millisecond)
+		case <-t.Done():
+			conn.WriteJSON(t.Screen())
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "exited"))
+			return
+		case <-closed:
+			return
+		}
+	}
+}
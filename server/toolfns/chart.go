@@ -0,0 +1,631 @@
+package toolfns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+type ChartSeries struct {
+	// Name of the series, shown in the legend.
+	Name string `json:"name,omitempty"`
+	// The values of the series. In pie charts, the sizes of the slices.
+	Values []float64 `json:"values"`
+	// X coordinates of the values in line and scatter charts. Defaults to the labels, or to 1, 2, 3...
+	X []float64 `json:"x,omitempty"`
+	// A color name such as "red", or a hex color such as "#ff0000". Defaults to a color from the palette.
+	Color string `json:"color,omitempty"`
+}
+
+// chartPalette is the Tableau 10 palette.
+var chartPalette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+var (
+	chartText = color.NRGBA{0x33, 0x33, 0x33, 0xff}
+	chartAxis = color.NRGBA{0x99, 0x99, 0x99, 0xff}
+	chartGrid = color.NRGBA{0xe5, 0xe5, 0xe5, 0xff}
+)
+
+// Draws a line, bar, scatter or pie chart, and shows it to the user.
+// @mutates
+// kind: The kind of chart. @enum line, bar, scatter, pie
+// series: The data series. Pie charts take a single series. @min 1
+// labels: Category labels of bar charts and slice labels of pie charts. In line charts, labels for the x axis. @optional
+// title: Title of the chart. @optional
+// xlabel: Label of the x axis. @optional
+// ylabel: Label of the y axis. @optional
+// width: Width in pixels. @min 200 @max 4000 @default 800
+// height: Height in pixels. @min 200 @max 4000 @default 500
+// format: Format of the chart. @enum png, svg @default png
+// output: Path in the workspace to save the chart to. @optional
+func Chart(ws *Workspace, kind string, series []ChartSeries, labels []string, title string, xlabel string, ylabel string, width int, height int, format string, output string) (*ContentTypeResponse, error) {
+	c := &chart{
+		kind:   kind,
+		series: series,
+		labels: labels,
+		title:  title,
+		xlabel: xlabel,
+		ylabel: ylabel,
+		width:  float64(width),
+		height: float64(height),
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+		canvas := newSVGCanvas(width, height)
+		c.draw(canvas)
+		data = canvas.bytes()
+	} else {
+		canvas := newPNGCanvas(width, height)
+		c.draw(canvas)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, canvas.img); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	if output != "" {
+		p, err := ws.Path(output)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ContentTypeResponse{
+		Name:        output,
+		ContentType: contentType,
+		Content:     "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+type chart struct {
+	kind                  string
+	series                []ChartSeries
+	labels                []string
+	title, xlabel, ylabel string
+	width, height         float64
+
+	colors []color.NRGBA
+}
+
+func (c *chart) validate() error {
+	if c.kind == "pie" && len(c.series) != 1 {
+		return errors.New("pie charts take a single series")
+	}
+	for i, s := range c.series {
+		name := s.Name
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		if len(s.Values) == 0 {
+			return fmt.Errorf("series %s has no values", name)
+		}
+		if len(s.X) > 0 && len(s.X) != len(s.Values) {
+			return fmt.Errorf("series %s has %d x coordinates for %d values", name, len(s.X), len(s.Values))
+		}
+		for _, values := range [][]float64{s.Values, s.X} {
+			for _, v := range values {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					return fmt.Errorf("series %s has a value that isn't a number", name)
+				}
+			}
+		}
+		if c.kind == "pie" {
+			for _, v := range s.Values {
+				if v < 0 {
+					return errors.New("pie charts can't have negative values")
+				}
+			}
+		}
+
+		col := chartPalette[i%len(chartPalette)]
+		if s.Color != "" {
+			col = s.Color
+		}
+		parsed, err := parseColor(col)
+		if err != nil {
+			return fmt.Errorf("series %s: %w", name, err)
+		}
+		c.colors = append(c.colors, color.NRGBAModel.Convert(parsed).(color.NRGBA))
+	}
+	return nil
+}
+
+// chartCanvas is what charts are drawn on. Text is vertically centered on its
+// position.
+type chartCanvas interface {
+	polyline(points []chartPoint, width float64, c color.NRGBA)
+	polygon(points []chartPoint, c color.NRGBA)
+	text(p chartPoint, s string, size float64, anchor string, vertical bool, c color.NRGBA)
+}
+
+type chartPoint struct{ X, Y float64 }
+
+// plot is the area of the chart inside the axes.
+type plot struct {
+	left, top, right, bottom float64
+}
+
+func (c *chart) draw(canvas chartCanvas) {
+	p := plot{left: 60, top: 20, right: c.width - 20, bottom: c.height - 40}
+	if c.title != "" {
+		canvas.text(chartPoint{c.width / 2, 22}, c.title, 18, "middle", false, chartText)
+		p.top += 30
+	}
+	if c.legend() != nil {
+		p.top += 24
+	}
+	if c.xlabel != "" {
+		canvas.text(chartPoint{(p.left + p.right) / 2, c.height - 16}, c.xlabel, 13, "middle", false, chartText)
+		p.bottom -= 24
+	}
+	if c.ylabel != "" {
+		canvas.text(chartPoint{18, (p.top + p.bottom) / 2}, c.ylabel, 13, "middle", true, chartText)
+		p.left += 24
+	}
+	c.drawLegend(canvas, p.top-16)
+
+	switch c.kind {
+	case "pie":
+		c.drawPie(canvas, p)
+	case "bar":
+		c.drawBars(canvas, p)
+	default:
+		c.drawXY(canvas, p)
+	}
+}
+
+type legendEntry struct {
+	name  string
+	color color.NRGBA
+}
+
+func (c *chart) legend() []legendEntry {
+	var entries []legendEntry
+	if c.kind == "pie" {
+		for i := range c.series[0].Values {
+			entries = append(entries, legendEntry{c.label(i), c.sliceColor(i)})
+		}
+		return entries
+	}
+	if len(c.series) < 2 && c.series[0].Name == "" {
+		return nil
+	}
+	for i, s := range c.series {
+		name := s.Name
+		if name == "" {
+			name = "Series " + strconv.Itoa(i+1)
+		}
+		entries = append(entries, legendEntry{name, c.colors[i]})
+	}
+	return entries
+}
+
+func (c *chart) drawLegend(canvas chartCanvas, y float64) {
+	entries := c.legend()
+	const size = 12
+	total := 0.0
+	for _, e := range entries {
+		total += 18 + textWidth(e.name, size) + 16
+	}
+	x := max(10, (c.width-total)/2)
+	for _, e := range entries {
+		canvas.polygon(rect(x, y-6, 12, 12), e.color)
+		canvas.text(chartPoint{x + 18, y}, e.name, size, "start", false, chartText)
+		x += 18 + textWidth(e.name, size) + 16
+	}
+}
+
+// label returns the label of the i-th category.
+func (c *chart) label(i int) string {
+	if i < len(c.labels) {
+		return c.labels[i]
+	}
+	return strconv.Itoa(i + 1)
+}
+
+func (c *chart) sliceColor(i int) color.NRGBA {
+	parsed, _ := parseColor(chartPalette[i%len(chartPalette)])
+	return color.NRGBAModel.Convert(parsed).(color.NRGBA)
+}
+
+func (c *chart) drawPie(canvas chartCanvas, p plot) {
+	values := c.series[0].Values
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	if total == 0 {
+		canvas.text(chartPoint{(p.left + p.right) / 2, (p.top + p.bottom) / 2}, "No data", 14, "middle", false, chartText)
+		return
+	}
+
+	cx, cy := (p.left+p.right)/2, (p.top+p.bottom)/2
+	r := min(p.right-p.left, p.bottom-p.top)/2 - 10
+	angle := -math.Pi / 2
+	for i, v := range values {
+		sweep := v / total * 2 * math.Pi
+		points := []chartPoint{{cx, cy}}
+		steps := max(2, int(sweep/(math.Pi/180)))
+		for s := 0; s <= steps; s++ {
+			a := angle + sweep*float64(s)/float64(steps)
+			points = append(points, chartPoint{cx + r*math.Cos(a), cy + r*math.Sin(a)})
+		}
+		canvas.polygon(points, c.sliceColor(i))
+		canvas.polyline(append(points, points[0]), 1.5, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+
+		if v/total >= 0.05 {
+			mid := angle + sweep/2
+			label := strconv.FormatFloat(math.Round(v/total*1000)/10, 'f', -1, 64) + "%"
+			canvas.text(chartPoint{cx + r*0.65*math.Cos(mid), cy + r*0.65*math.Sin(mid)}, label, 13, "middle", false, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+		angle += sweep
+	}
+}
+
+func (c *chart) drawBars(canvas chartCanvas, p plot) {
+	n := len(c.labels)
+	lo, hi := 0.0, 0.0
+	for _, s := range c.series {
+		n = max(n, len(s.Values))
+		for _, v := range s.Values {
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+	ticks, lo, hi := niceTicks(lo, hi, 6)
+	y := func(v float64) float64 { return p.bottom - (v-lo)/(hi-lo)*(p.bottom-p.top) }
+	c.drawYAxis(canvas, p, ticks, y)
+
+	slot := (p.right - p.left) / float64(n)
+	group := slot * 0.8
+	bar := group / float64(len(c.series))
+	for i := 0; i < n; i++ {
+		x := p.left + slot*float64(i) + (slot-group)/2
+		for j, s := range c.series {
+			if i >= len(s.Values) {
+				continue
+			}
+			top, bottom := y(max(s.Values[i], 0)), y(min(s.Values[i], 0))
+			canvas.polygon(rect(x+bar*float64(j), top, bar-1, bottom-top), c.colors[j])
+		}
+	}
+
+	canvas.polyline([]chartPoint{{p.left, y(0)}, {p.right, y(0)}}, 1, chartAxis)
+	every := labelStep(n, slot, c.labels)
+	for i := 0; i < n; i += every {
+		canvas.text(chartPoint{p.left + slot*(float64(i)+0.5), p.bottom + 14}, c.label(i), 12, "middle", false, chartText)
+	}
+}
+
+func (c *chart) drawXY(canvas chartCanvas, p plot) {
+	xs := func(s ChartSeries, i int) float64 {
+		if len(s.X) > 0 {
+			return s.X[i]
+		}
+		return float64(i + 1)
+	}
+	// Labels name the points of series without x coordinates.
+	categorical := len(c.labels) > 0
+	for _, s := range c.series {
+		if len(s.X) > 0 {
+			categorical = false
+		}
+	}
+
+	xlo, xhi := math.Inf(1), math.Inf(-1)
+	ylo, yhi := math.Inf(1), math.Inf(-1)
+	n := 0
+	for _, s := range c.series {
+		n = max(n, len(s.Values))
+		for i, v := range s.Values {
+			xlo, xhi = min(xlo, xs(s, i)), max(xhi, xs(s, i))
+			ylo, yhi = min(ylo, v), max(yhi, v)
+		}
+	}
+
+	yticks, ylo, yhi := niceTicks(ylo, yhi, 6)
+	y := func(v float64) float64 { return p.bottom - (v-ylo)/(yhi-ylo)*(p.bottom-p.top) }
+	c.drawYAxis(canvas, p, yticks, y)
+
+	var x func(float64) float64
+	if categorical {
+		slot := (p.right - p.left) / float64(max(n-1, 1))
+		x = func(v float64) float64 { return p.left + (v-1)*slot }
+		if n == 1 {
+			x = func(float64) float64 { return (p.left + p.right) / 2 }
+		}
+		every := labelStep(n, slot, c.labels)
+		for i := 0; i < n; i += every {
+			canvas.text(chartPoint{x(float64(i + 1)), p.bottom + 14}, c.label(i), 12, "middle", false, chartText)
+		}
+	} else {
+		var xticks []float64
+		xticks, xlo, xhi = niceTicks(xlo, xhi, max(2, int((p.right-p.left)/100)))
+		x = func(v float64) float64 { return p.left + (v-xlo)/(xhi-xlo)*(p.right-p.left) }
+		for _, t := range xticks {
+			canvas.polyline([]chartPoint{{x(t), p.bottom}, {x(t), p.bottom + 4}}, 1, chartAxis)
+			canvas.text(chartPoint{x(t), p.bottom + 14}, formatTick(t, xticks), 12, "middle", false, chartText)
+		}
+	}
+	canvas.polyline([]chartPoint{{p.left, p.bottom}, {p.right, p.bottom}}, 1, chartAxis)
+
+	for j, s := range c.series {
+		points := make([]chartPoint, len(s.Values))
+		for i, v := range s.Values {
+			points[i] = chartPoint{x(xs(s, i)), y(v)}
+		}
+		if c.kind == "line" {
+			canvas.polyline(points, 2, c.colors[j])
+		}
+		if c.kind == "scatter" || len(points) <= 30 {
+			radius := 3.0
+			if c.kind == "scatter" {
+				radius = 4
+			}
+			for _, pt := range points {
+				canvas.polygon(circle(pt, radius), c.colors[j])
+			}
+		}
+	}
+}
+
+func (c *chart) drawYAxis(canvas chartCanvas, p plot, ticks []float64, y func(float64) float64) {
+	for _, t := range ticks {
+		canvas.polyline([]chartPoint{{p.left, y(t)}, {p.right, y(t)}}, 1, chartGrid)
+		canvas.text(chartPoint{p.left - 8, y(t)}, formatTick(t, ticks), 12, "end", false, chartText)
+	}
+	canvas.polyline([]chartPoint{{p.left, p.top}, {p.left, p.bottom}}, 1, chartAxis)
+}
+
+// labelStep returns how many categories to skip between labels so that they
+// don't overlap.
+func labelStep(n int, slot float64, labels []string) int {
+	widest := 0.0
+	for i := 0; i < n; i++ {
+		label := strconv.Itoa(i + 1)
+		if i < len(labels) {
+			label = labels[i]
+		}
+		widest = max(widest, textWidth(label, 12))
+	}
+	return max(1, int(math.Ceil((widest+8)/slot)))
+}
+
+// maxTicks bounds the number of ticks of an axis.
+const maxTicks = 100
+
+// niceTicks returns about n round tick values covering lo to hi, and the range
+// they span.
+func niceTicks(lo, hi float64, n int) ([]float64, float64, float64) {
+	first, last := lo, hi
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	step := niceNumber((hi - lo) / float64(n-1))
+	lo = math.Floor(lo/step) * step
+	hi = math.Ceil(hi/step) * step
+
+	// With large values close together, the step can be below their precision,
+	// so that adding it changes nothing. Fall back to a single tick, in the
+	// middle of a range wide enough to be represented.
+	count := math.Round((hi - lo) / step)
+	if lo+step == lo || !(count >= 0 && count < maxTicks) {
+		mid := first + (last-first)/2
+		pad := math.Max(math.Abs(mid)*1e-6, 1)
+		return []float64{mid}, mid - pad, mid + pad
+	}
+
+	ticks := make([]float64, 0, int(count)+1)
+	for i := 0; i <= int(count); i++ {
+		v := lo + float64(i)*step
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	return ticks, lo, hi
+}
+
+// niceNumber rounds x to 1, 2 or 5 times a power of ten.
+func niceNumber(x float64) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+	switch {
+	case f < 1.5:
+		f = 1
+	case f < 3:
+		f = 2
+	case f < 7:
+		f = 5
+	default:
+		f = 10
+	}
+	return f * math.Pow(10, exp)
+}
+
+// formatTick formats a tick value with as many decimals as the step between
+// ticks needs.
+func formatTick(v float64, ticks []float64) string {
+	decimals := 0
+	if len(ticks) > 1 {
+		decimals = max(0, int(-math.Floor(math.Log10(ticks[1]-ticks[0])+1e-9)))
+	}
+	if v == 0 {
+		v = 0 // Avoids "-0".
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+func rect(x, y, w, h float64) []chartPoint {
+	return []chartPoint{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+}
+
+func circle(center chartPoint, r float64) []chartPoint {
+	const steps = 16
+	points := make([]chartPoint, steps)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / steps
+		points[i] = chartPoint{center.X + r*math.Cos(a), center.Y + r*math.Sin(a)}
+	}
+	return points
+}
+
+func textWidth(s string, size float64) float64 {
+	return float64(font.MeasureString(chartFace(size), s).Ceil())
+}
+
+// chartFace returns the Go font at size. Charts only use a few fixed sizes,
+// so should it fail anyway, the text is drawn with a basic font rather than
+// failing the chart.
+func chartFace(size float64) font.Face {
+	face, err := fontFace(size)
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Go, Helvetica, Arial, sans-serif">`, width, height, width, height)
+	fmt.Fprintf(&c.b, `<rect width="100%%" height="100%%" fill="#fff"/>`)
+	return c
+}
+
+func (c *svgCanvas) bytes() []byte {
+	return []byte(c.b.String() + "</svg>\n")
+}
+
+func (c *svgCanvas) polyline(points []chartPoint, width float64, col color.NRGBA) {
+	fmt.Fprintf(&c.b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%g" stroke-linejoin="round" stroke-linecap="round"/>`, svgPoints(points), svgColor(col), width)
+}
+
+func (c *svgCanvas) polygon(points []chartPoint, col color.NRGBA) {
+	fmt.Fprintf(&c.b, `<polygon points="%s" fill="%s"/>`, svgPoints(points), svgColor(col))
+}
+
+func (c *svgCanvas) text(p chartPoint, s string, size float64, anchor string, vertical bool, col color.NRGBA) {
+	transform := ""
+	if vertical {
+		transform = fmt.Sprintf(` transform="rotate(-90 %.1f %.1f)"`, p.X, p.Y)
+	}
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" font-size="%g" text-anchor="%s" fill="%s"%s>%s</text>`,
+		p.X, p.Y+size*0.35, size, anchor, svgColor(col), transform, html.EscapeString(s))
+}
+
+func svgPoints(points []chartPoint) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%.1f,%.1f", p.X, p.Y)
+	}
+	return strings.Join(parts, " ")
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	stddraw.Draw(img, img.Bounds(), image.White, image.Point{}, stddraw.Src)
+	return &pngCanvas{img: img}
+}
+
+func (c *pngCanvas) polyline(points []chartPoint, width float64, col color.NRGBA) {
+	// Each segment is drawn as a rectangle, with round joins.
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*width/2, dx/length*width/2
+		c.polygon([]chartPoint{{a.X + nx, a.Y + ny}, {b.X + nx, b.Y + ny}, {b.X - nx, b.Y - ny}, {a.X - nx, a.Y - ny}}, col)
+		if width > 1.5 && i < len(points)-1 {
+			c.polygon(circle(b, width/2), col)
+		}
+	}
+}
+
+func (c *pngCanvas) polygon(points []chartPoint, col color.NRGBA) {
+	if len(points) < 3 {
+		return
+	}
+	b := c.img.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	z.MoveTo(float32(points[0].X), float32(points[0].Y))
+	for _, p := range points[1:] {
+		z.LineTo(float32(p.X), float32(p.Y))
+	}
+	z.ClosePath()
+	z.Draw(c.img, b, image.NewUniform(col), image.Point{})
+}
+
+func (c *pngCanvas) text(p chartPoint, s string, size float64, anchor string, vertical bool, col color.NRGBA) {
+	face := chartFace(size)
+	width := font.MeasureString(face, s).Ceil()
+	metrics := face.Metrics()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+
+	// Text is drawn horizontally on its own image, so that it can be rotated.
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(col), Face: face, Dot: fixed.P(0, metrics.Ascent.Ceil())}
+	d.DrawString(s)
+
+	var drawn image.Image = img
+	if vertical {
+		drawn = rotateImage(img, 270, false)
+	}
+	bounds := drawn.Bounds().Size()
+
+	x, y := p.X, p.Y-float64(bounds.Y)/2
+	if vertical {
+		x, y = p.X-float64(bounds.X)/2, p.Y-float64(bounds.Y)/2
+	} else {
+		switch anchor {
+		case "middle":
+			x -= float64(bounds.X) / 2
+		case "end":
+			x -= float64(bounds.X)
+		}
+	}
+	r := image.Rect(int(x), int(y), int(x)+bounds.X, int(y)+bounds.Y)
+	stddraw.Draw(c.img, r, drawn, drawn.Bounds().Min, stddraw.Over)
+}
@@ -0,0 +1,22 @@
+package toolfns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNiceTicks(t *testing.T) {
+	ticks, lo, hi := niceTicks(0.3, 9.2, 6)
+	want := []float64{0, 2, 4, 6, 8, 10}
+	if !reflect.DeepEqual(ticks, want) || lo != 0 || hi != 10 {
+		t.Errorf("niceTicks(0.3, 9.2) = %v, %v, %v", ticks, lo, hi)
+	}
+
+	// The step is below the precision of the values.
+	for _, r := range [][2]float64{{1e16, 1e16 + 2}, {1e16, 1e16}, {-1e300, -1e300}} {
+		ticks, lo, hi := niceTicks(r[0], r[1], 6)
+		if len(ticks) != 1 || !(lo < ticks[0] && ticks[0] < hi) {
+			t.Errorf("niceTicks(%g, %g) = %v, %v, %v", r[0], r[1], ticks, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package toolfns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// CheckpointInfo describes a snapshot of a workspace, taken right before a
+// tool call.
+type CheckpointInfo struct {
+	// ID is the ID of the tool call.
+	ID      string    `json:"id"`
+	Tool    string    `json:"tool"`
+	Created time.Time `json:"created"`
+	Files   int       `json:"files"`
+	Size    int64     `json:"size"`
+}
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// maxCheckpoints is the number of checkpoints kept per workspace. Older ones
+// are removed as new ones are taken.
+const maxCheckpoints = 200
+
+// checkpointsDir holds the checkpoints of all workspaces, under the root. The
+// dot keeps it from being taken for a workspace.
+const checkpointsDir = ".checkpoints"
+
+// checkpointIndex lists the checkpoints of a workspace, oldest first, so that
+// they can be listed and pruned without reading every manifest.
+const checkpointIndex = "index.json"
+
+// checkpointPruneBatch is how many checkpoints past maxCheckpoints are removed
+// at once, as finding the contents no longer used reads all manifests.
+const checkpointPruneBatch = 20
+
+// skipCheckpointDir reports whether a directory is left out of checkpoints.
+// These hold dependencies and caches, which are large and can be recreated, so
+// Restore leaves them as they are.
+func skipCheckpointDir(name string) bool {
+	return name == "node_modules" || name == "__pycache__" || name == ".venv"
+}
+
+// checkpoint is the manifest of a snapshot. File contents are stored once per
+// workspace, by hash, and shared between checkpoints.
+type checkpoint struct {
+	CheckpointInfo
+	Entries []checkpointEntry `json:"entries"`
+}
+
+type checkpointEntry struct {
+	Path    string      `json:"path"`
+	Mode    fs.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mod_time"`
+	Hash    string      `json:"hash,omitempty"`
+	Link    string      `json:"link,omitempty"`
+}
+
+// Checkpoint snapshots the files of a workspace before the tool call with the
+// given ID.
+func (ws *Workspaces) Checkpoint(w *Workspace, id, tool string) (*CheckpointInfo, error) {
+	if !chatIDRegex.MatchString(id) {
+		return nil, fmt.Errorf("invalid tool call id %q", id)
+	}
+	ws.checkpointMu.Lock()
+	defer ws.checkpointMu.Unlock()
+
+	dir := ws.checkpointDir(w.ChatID)
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0o755); err != nil {
+		return nil, err
+	}
+
+	infos, err := ws.readCheckpointIndex(w.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files that haven't changed since the last checkpoint aren't hashed again.
+	previous := map[string]checkpointEntry{}
+	if len(infos) > 0 {
+		if last, err := ws.loadCheckpoint(w.ChatID, infos[len(infos)-1].ID); err == nil {
+			for _, entry := range last.Entries {
+				previous[entry.Path] = entry
+			}
+		}
+	}
+
+	cp := &checkpoint{CheckpointInfo: CheckpointInfo{ID: id, Tool: tool, Created: time.Now().UTC()}}
+	err = filepath.WalkDir(w.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.Dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() && skipCheckpointDir(d.Name()) {
+			return fs.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := checkpointEntry{
+			Path:    filepath.ToSlash(rel),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		switch {
+		case info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			if prev, ok := previous[entry.Path]; ok && prev.Size == entry.Size && prev.ModTime.Equal(entry.ModTime) && prev.Hash != "" {
+				entry.Hash = prev.Hash
+			} else if entry.Hash, err = storeObject(dir, path); err != nil {
+				return err
+			}
+			cp.Files++
+			cp.Size += entry.Size
+		default:
+			// Sockets, pipes and devices can't be restored.
+			return nil
+		}
+		cp.Entries = append(cp.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".json"), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	// A call that is retried replaces its checkpoint.
+	infos = slices.DeleteFunc(infos, func(info *CheckpointInfo) bool { return info.ID == id })
+	infos = append(infos, &cp.CheckpointInfo)
+	if len(infos) > maxCheckpoints {
+		if infos, err = ws.pruneCheckpoints(w.ChatID, infos); err != nil {
+			return nil, err
+		}
+	}
+	if err := ws.writeCheckpointIndex(w.ChatID, infos); err != nil {
+		return nil, err
+	}
+	return &cp.CheckpointInfo, nil
+}
+
+// Checkpoints lists the checkpoints of a workspace, oldest first.
+func (ws *Workspaces) Checkpoints(chatID string) ([]*CheckpointInfo, error) {
+	if err := CheckChatID(chatID); err != nil {
+		return nil, err
+	}
+	ws.checkpointMu.Lock()
+	defer ws.checkpointMu.Unlock()
+	return ws.readCheckpointIndex(chatID)
+}
+
+// Restore puts the files of a workspace back the way they were before the
+// tool call with the given ID. Files created since are removed.
+func (ws *Workspaces) Restore(chatID, id string) (*CheckpointInfo, error) {
+	w, err := ws.Open(chatID)
+	if err != nil {
+		return nil, err
+	}
+	ws.checkpointMu.Lock()
+	defer ws.checkpointMu.Unlock()
+
+	cp, err := ws.loadCheckpoint(chatID, id)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]checkpointEntry, len(cp.Entries))
+	for _, entry := range cp.Entries {
+		entries[entry.Path] = entry
+	}
+
+	// Remove what wasn't there, or was there as something else.
+	err = filepath.WalkDir(w.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.Dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		entry, ok := entries[filepath.ToSlash(rel)]
+		if ok && entry.Mode.Type() == d.Type() {
+			return nil
+		}
+		if d.IsDir() && skipCheckpointDir(d.Name()) {
+			return fs.SkipDir
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dir := ws.checkpointDir(chatID)
+	for _, entry := range cp.Entries {
+		path := filepath.Join(w.Dir, filepath.FromSlash(entry.Path))
+		switch {
+		case entry.Mode.IsDir():
+			if err := os.MkdirAll(path, entry.Mode.Perm()); err != nil {
+				return nil, err
+			}
+			if err := os.Chmod(path, entry.Mode.Perm()); err != nil {
+				return nil, err
+			}
+		case entry.Mode&fs.ModeSymlink != 0:
+			if link, err := os.Readlink(path); err == nil && link == entry.Link {
+				continue
+			}
+			os.Remove(path)
+			if err := os.Symlink(entry.Link, path); err != nil {
+				return nil, err
+			}
+		default:
+			if info, err := os.Lstat(path); err == nil && info.Size() == entry.Size && info.ModTime().Equal(entry.ModTime) && info.Mode() == entry.Mode {
+				continue
+			}
+			if err := restoreObject(dir, entry, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Restore the times of directories last, as filling them changes them.
+	for i := len(cp.Entries) - 1; i >= 0; i-- {
+		if entry := cp.Entries[i]; entry.Mode.IsDir() {
+			os.Chtimes(filepath.Join(w.Dir, filepath.FromSlash(entry.Path)), entry.ModTime, entry.ModTime)
+		}
+	}
+	return &cp.CheckpointInfo, nil
+}
+
+func (ws *Workspaces) checkpointDir(chatID string) string {
+	return filepath.Join(ws.Root, checkpointsDir, chatID)
+}
+
+func (ws *Workspaces) loadCheckpoint(chatID, id string) (*checkpoint, error) {
+	if !chatIDRegex.MatchString(chatID) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidChatID, chatID)
+	}
+	if !chatIDRegex.MatchString(id) {
+		return nil, ErrCheckpointNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(ws.checkpointDir(chatID), id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("reading checkpoint %s: %w", id, err)
+	}
+	return &cp, nil
+}
+
+// readCheckpointIndex returns the checkpoints of a workspace, oldest first.
+func (ws *Workspaces) readCheckpointIndex(chatID string) ([]*CheckpointInfo, error) {
+	data, err := os.ReadFile(filepath.Join(ws.checkpointDir(chatID), checkpointIndex))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*CheckpointInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos := []*CheckpointInfo{}
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, fmt.Errorf("reading checkpoint index: %w", err)
+	}
+	return infos, nil
+}
+
+func (ws *Workspaces) writeCheckpointIndex(chatID string, infos []*CheckpointInfo) error {
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return err
+	}
+	dir := ws.checkpointDir(chatID)
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, checkpointIndex))
+}
+
+// pruneCheckpoints removes the oldest checkpoints, leaving checkpointPruneBatch
+// fewer than maxCheckpoints, and the contents no longer used by any checkpoint.
+// It returns the checkpoints that are left.
+func (ws *Workspaces) pruneCheckpoints(chatID string, infos []*CheckpointInfo) ([]*CheckpointInfo, error) {
+	dir := ws.checkpointDir(chatID)
+	n := len(infos) - maxCheckpoints + checkpointPruneBatch
+	for _, info := range infos[:n] {
+		if err := os.Remove(filepath.Join(dir, info.ID+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	infos = infos[n:]
+
+	used := map[string]bool{}
+	for _, info := range infos {
+		cp, err := ws.loadCheckpoint(chatID, info.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range cp.Entries {
+			used[entry.Hash] = true
+		}
+	}
+	err := filepath.WalkDir(filepath.Join(dir, "objects"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !used[d.Name()] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	return infos, err
+}
+
+// removeCheckpoints deletes all checkpoints of a workspace.
+func (ws *Workspaces) removeCheckpoints(chatID string) error {
+	ws.checkpointMu.Lock()
+	defer ws.checkpointMu.Unlock()
+	return os.RemoveAll(ws.checkpointDir(chatID))
+}
+
+// storeObject copies the file at path into the objects of a checkpoint dir,
+// unless it's already there, and returns its hash.
+func storeObject(dir, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(dir, "objects"), "tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), f); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	object := objectPath(dir, hash)
+	if _, err := os.Stat(object); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(object), 0o755); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp.Name(), object)
+}
+
+// restoreObject writes the contents of a file entry to path.
+func restoreObject(dir string, entry checkpointEntry, path string) error {
+	src, err := os.Open(objectPath(dir, entry.Hash))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), entry.Mode.Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), entry.ModTime, entry.ModTime); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func objectPath(dir, hash string) string {
+	return filepath.Join(dir, "objects", hash[:2], hash)
+}
@@ -0,0 +1,132 @@
+package toolfns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCheckpointWorkspace(t *testing.T) (*Workspaces, *Workspace) {
+	t.Helper()
+	workspaces := &Workspaces{Root: t.TempDir()}
+	ws, err := workspaces.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workspaces, ws
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	workspaces, ws := newCheckpointWorkspace(t)
+	writeFile(t, ws, "a.txt", "one\n")
+	writeFile(t, ws, "node_modules/dep/index.js", "module.exports = 1\n")
+
+	if _, err := workspaces.Checkpoint(ws, "call1", "Shell"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "a.txt", "two\n")
+	writeFile(t, ws, "b.txt", "new\n")
+	writeFile(t, ws, "node_modules/dep/index.js", "module.exports = 2\n")
+	if _, err := workspaces.Checkpoint(ws, "call2", "Shell"); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := workspaces.Checkpoints("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].ID != "call1" || infos[1].ID != "call2" {
+		t.Fatalf("Checkpoints = %+v", infos)
+	}
+	// Dependencies aren't part of checkpoints.
+	if infos[1].Files != 2 {
+		t.Errorf("call2 has %d files, want 2", infos[1].Files)
+	}
+
+	if _, err := workspaces.Restore("test", "call1"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(ws.Dir, "a.txt")); err != nil || string(data) != "one\n" {
+		t.Errorf("a.txt = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Dir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("b.txt wasn't removed: %v", err)
+	}
+	// Nor are they touched by restores.
+	if data, err := os.ReadFile(filepath.Join(ws.Dir, "node_modules/dep/index.js")); err != nil || string(data) != "module.exports = 2\n" {
+		t.Errorf("node_modules/dep/index.js = %q, %v", data, err)
+	}
+
+	// A retried call replaces its checkpoint.
+	if _, err := workspaces.Checkpoint(ws, "call1", "Shell"); err != nil {
+		t.Fatal(err)
+	}
+	if infos, err := workspaces.Checkpoints("test"); err != nil || len(infos) != 2 || infos[1].ID != "call1" {
+		t.Errorf("Checkpoints after retrying call1 = %+v, %v", infos, err)
+	}
+}
+
+func TestCheckpointPrune(t *testing.T) {
+	workspaces, ws := newCheckpointWorkspace(t)
+	for i := range maxCheckpoints + 1 {
+		writeFile(t, ws, "a.txt", fmt.Sprintf("version %d\n", i))
+		if _, err := workspaces.Checkpoint(ws, fmt.Sprintf("call%d", i), "Shell"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := workspaces.Checkpoints("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := maxCheckpoints - checkpointPruneBatch; len(infos) != want {
+		t.Fatalf("%d checkpoints are left, want %d", len(infos), want)
+	}
+	if want := fmt.Sprintf("call%d", checkpointPruneBatch+1); infos[0].ID != want {
+		t.Errorf("oldest checkpoint is %s, want %s", infos[0].ID, want)
+	}
+	if _, err := workspaces.Restore("test", "call0"); err != ErrCheckpointNotFound {
+		t.Errorf("Restore of a pruned checkpoint = %v", err)
+	}
+
+	// Only the contents of the remaining checkpoints are kept.
+	objects := 0
+	filepath.WalkDir(filepath.Join(workspaces.checkpointDir("test"), "objects"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			objects++
+		}
+		return nil
+	})
+	if objects != len(infos) {
+		t.Errorf("%d objects are left, want %d", objects, len(infos))
+	}
+}
+
+func TestMutates(t *testing.T) {
+	groups := map[string]*Group{}
+	for _, g := range ToolGroups {
+		for _, fn := range g.Schema {
+			groups[fn.Name] = g
+			if strings.Contains(fn.Description, "@mutates") {
+				t.Errorf("%s: description has @mutates: %q", fn.Name, fn.Description)
+			}
+		}
+	}
+	for name, want := range map[string]bool{
+		"Shell":     true,
+		"GitCommit": true,
+		"GitStatus": false,
+		"Search":    false,
+		"Unknown":   false,
+	} {
+		g, ok := groups[name]
+		if !ok {
+			g = ToolGroups[0]
+		}
+		if got := g.Mutates(name); got != want {
+			t.Errorf("Mutates(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
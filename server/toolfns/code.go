@@ -0,0 +1,268 @@
+package toolfns
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CodeConfig sets the interpreters and resource limits of RunCode.
+type CodeConfig struct {
+	// Python is the Python interpreter. Defaults to python3.
+	Python string `json:"python,omitempty"`
+	// Node is the Node.js executable. Defaults to node.
+	Node string `json:"node,omitempty"`
+	// MemoryMB is the maximum memory of a run, in megabytes. Defaults to 1024.
+	MemoryMB int `json:"memory_mb,omitempty"`
+	// CPUSeconds is the maximum CPU time of a run. Defaults to 120.
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// MaxFileSizeMB is the maximum size of a file written by a run, in
+	// megabytes. Defaults to 100.
+	MaxFileSizeMB int `json:"max_file_size_mb,omitempty"`
+}
+
+type CodeResult struct {
+	ExitCode int                   `json:"exit_code"`
+	TimedOut bool                  `json:"timed_out,omitempty"`
+	Stdout   string                `json:"stdout"`
+	Stderr   string                `json:"stderr"`
+	Files    []ContentTypeResponse `json:"files,omitempty"`
+}
+
+const (
+	maxCodeOutput   = 64 * 1024
+	maxArtifacts    = 10
+	maxArtifactSize = 5 * 1024 * 1024
+	maxTextArtifact = 64 * 1024
+)
+
+// Runs Python or Node.js code in the workspace, and returns its output along with the files it created or modified. Save plots and other results to files to show them to the user, for example with plt.savefig("plot.png").
+// @mutates
+// language: The language of the code. @enum python, node
+// code: The code to run.
+// timeout: Maximum time the code may run, in seconds. @min 1 @max 600 @default 60
+func RunCode(ws *Workspace, language string, code string, timeout int) (*CodeResult, error) {
+	c := config.Code
+
+	ext, interpreter, args := ".py", c.python(), []string{}
+	if language == "node" {
+		ext, interpreter = ".js", c.node()
+		args = append(args, "--max-old-space-size="+strconv.Itoa(c.memoryMB()))
+	}
+
+	f, err := os.CreateTemp("", "llum-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(code); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.Close()
+
+	before := snapshotFiles(ws.Dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := limitedCommand(ctx, c, language == "python", interpreter, append(args, f.Name())...)
+	cmd.Dir = ws.Dir
+	cmd.Env = append(os.Environ(), "MPLBACKEND=Agg", "PYTHONUNBUFFERED=1")
+
+	stdout := &limitedBuffer{max: maxCodeOutput}
+	stderr := &limitedBuffer{max: maxCodeOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	result := &CodeResult{}
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.TimedOut = true
+		result.ExitCode = -1
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, err
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Files = changedFiles(ws.Dir, before)
+	return result, nil
+}
+
+// snapshotFiles returns the modification times of the files in dir.
+func snapshotFiles(dir string) map[string]time.Time {
+	files := map[string]time.Time{}
+	walkWorkspace(dir, func(rel string, info fs.FileInfo) {
+		files[rel] = info.ModTime()
+	})
+	return files
+}
+
+// changedFiles returns the files in dir that were created or modified since
+// the before snapshot was taken. Images are returned as data URLs, and text
+// files as text.
+func changedFiles(dir string, before map[string]time.Time) []ContentTypeResponse {
+	type changed struct {
+		rel  string
+		info fs.FileInfo
+	}
+	var files []changed
+	walkWorkspace(dir, func(rel string, info fs.FileInfo) {
+		if mtime, ok := before[rel]; !ok || info.ModTime().After(mtime) {
+			files = append(files, changed{rel, info})
+		}
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+
+	var artifacts []ContentTypeResponse
+	for _, file := range files {
+		if len(artifacts) == maxArtifacts {
+			break
+		}
+
+		artifact := ContentTypeResponse{
+			Name:        file.rel,
+			ContentType: mime.TypeByExtension(filepath.Ext(file.rel)),
+		}
+		if file.info.Size() > maxArtifactSize {
+			artifacts = append(artifacts, artifact)
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, file.rel))
+		if err != nil {
+			continue
+		}
+		if artifact.ContentType == "" {
+			artifact.ContentType = http.DetectContentType(data)
+		}
+		mediaType, _, _ := mime.ParseMediaType(artifact.ContentType)
+
+		switch {
+		case strings.HasPrefix(mediaType, "image/"):
+			artifact.Content = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		case isText(mediaType, data):
+			if len(data) > maxTextArtifact {
+				data = data[:maxTextArtifact]
+			}
+			artifact.Content = strings.ToValidUTF8(string(data), "")
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
+
+// walkWorkspace calls fn for each regular file in dir, skipping hidden
+// directories and dependency folders.
+func walkWorkspace(dir string, fn func(rel string, info fs.FileInfo)) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "__pycache__") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		fn(filepath.ToSlash(rel), info)
+		return nil
+	})
+}
+
+func isText(mediaType string, data []byte) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "image/svg+xml":
+		return true
+	}
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+// limitedBuffer keeps the first max bytes written to it, and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.Len(); n < len(p) {
+		b.truncated = true
+		if n > 0 {
+			b.Buffer.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return strings.ToValidUTF8(b.Buffer.String(), "") + "\n[output truncated]"
+	}
+	return b.Buffer.String()
+}
+
+func (c CodeConfig) python() string {
+	if c.Python == "" {
+		return "python3"
+	}
+	return c.Python
+}
+
+func (c CodeConfig) node() string {
+	if c.Node == "" {
+		return "node"
+	}
+	return c.Node
+}
+
+func (c CodeConfig) memoryMB() int {
+	if c.MemoryMB <= 0 {
+		return 1024
+	}
+	return c.MemoryMB
+}
+
+func (c CodeConfig) cpuSeconds() int {
+	if c.CPUSeconds <= 0 {
+		return 120
+	}
+	return c.CPUSeconds
+}
+
+func (c CodeConfig) maxFileSizeMB() int {
+	if c.MaxFileSizeMB <= 0 {
+		return 100
+	}
+	return c.MaxFileSizeMB
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package toolfns
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// limitedCommand returns a command that runs name under the CPU time, file
+// size and, if limitMemory is set, address space limits of c. The command
+// runs in its own process group, which is killed when ctx is done.
+func limitedCommand(ctx context.Context, c CodeConfig, limitMemory bool, name string, args ...string) *exec.Cmd {
+	// POSIX sh counts file sizes in 512-byte blocks and memory in KiB.
+	limits := "ulimit -t " + strconv.Itoa(c.cpuSeconds()) +
+		" && ulimit -f " + strconv.Itoa(c.maxFileSizeMB()*2048)
+	if limitMemory {
+		limits += " && ulimit -v " + strconv.Itoa(c.memoryMB()*1024)
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", limits + ` && exec "$@"`, "sh", name}, args...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Children that outlive the process may hold on to its output.
+	cmd.WaitDelay = time.Second
+	return cmd
+}
@@ -0,0 +1,12 @@
+package toolfns
+
+import (
+	"context"
+	"os/exec"
+)
+
+// limitedCommand returns a command that runs name until ctx is done. Resource
+// limits are not supported on Windows.
+func limitedCommand(ctx context.Context, c CodeConfig, limitMemory bool, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
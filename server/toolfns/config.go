@@ -0,0 +1,67 @@
+package toolfns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config holds the settings of the tool groups that need them, and is read
+// from the JSON file given to the server with -config.
+type Config struct {
+	// Databases are the connections available to the Database tools, by name.
+	Databases map[string]DatabaseConfig `json:"databases"`
+	// HTTP restricts the destinations of the HTTP tools.
+	HTTP HTTPConfig `json:"http"`
+	// Code sets the interpreters and resource limits of the Code tools.
+	Code CodeConfig `json:"code"`
+	// Search sets the folders indexed for the Search tool.
+	Search SearchConfig `json:"search"`
+	// Memory sets where the Memory tools keep their entries.
+	Memory MemoryConfig `json:"memory"`
+	// SSH are the remote hosts available to the SSH tools, by name.
+	SSH map[string]SSHHostConfig `json:"ssh"`
+	// Plugins are the WebAssembly modules loaded as tools.
+	Plugins PluginsConfig `json:"plugins"`
+	// Redaction sets how secrets are removed from the results of tools.
+	Redaction RedactionConfig `json:"redaction"`
+}
+
+var config Config
+
+// LoadConfig reads the configuration file at path, and makes it available to
+// the tools.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	config = c
+	return nil
+}
+
+// Duration is a time.Duration that is read from a string such as "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
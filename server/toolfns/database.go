@@ -0,0 +1,414 @@
+package toolfns
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// DatabaseConfig describes a database connection.
+type DatabaseConfig struct {
+	// Driver is one of sqlite, postgres or mysql.
+	Driver string `json:"driver"`
+	// DSN is the data source name passed to the driver. For sqlite, this is
+	// the path of the database file.
+	DSN string `json:"dsn"`
+	// Description tells the model what the database contains.
+	Description string `json:"description,omitempty"`
+	// Writable allows statements that modify the database. Connections are
+	// read-only by default.
+	Writable bool `json:"writable,omitempty"`
+	// MaxRows is the maximum number of rows returned by a query. Defaults to 500.
+	MaxRows int `json:"max_rows,omitempty"`
+	// Timeout is the maximum time a query may run. Defaults to 30s.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+type DatabaseConnection struct {
+	Name        string `json:"name"`
+	Driver      string `json:"driver"`
+	Description string `json:"description,omitempty"`
+	Writable    bool   `json:"writable"`
+}
+
+type DatabaseColumn struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Nullable   bool    `json:"nullable"`
+	Default    *string `json:"default,omitempty"`
+	PrimaryKey bool    `json:"primary_key,omitempty"`
+}
+
+type DatabaseTable struct {
+	Name    string           `json:"name"`
+	Columns []DatabaseColumn `json:"columns"`
+}
+
+type DatabaseQueryResult struct {
+	Columns      []string `json:"columns,omitempty"`
+	Rows         [][]any  `json:"rows,omitempty"`
+	Truncated    bool     `json:"truncated,omitempty"`
+	RowsAffected *int64   `json:"rows_affected,omitempty"`
+}
+
+// Lists the configured database connections.
+func DatabaseConnections() []DatabaseConnection {
+	conns := []DatabaseConnection{}
+	for name, c := range config.Databases {
+		conns = append(conns, DatabaseConnection{
+			Name:        name,
+			Driver:      c.Driver,
+			Description: c.Description,
+			Writable:    c.Writable,
+		})
+	}
+	sort.Slice(conns, func(i, j int) bool { return conns[i].Name < conns[j].Name })
+	return conns
+}
+
+// Lists the tables and views of a database.
+// connection: Name of the database connection.
+func DatabaseTables(connection string) ([]string, error) {
+	db, c, err := openDatabase(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, dialects[c.Driver].tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// Describes the columns of database tables.
+// connection: Name of the database connection.
+// tables: Names of the tables to describe. Describes all tables if empty. @optional
+func DatabaseSchema(connection string, tables []string) ([]DatabaseTable, error) {
+	db, c, err := openDatabase(connection)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		if tables, err = DatabaseTables(connection); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	described := make([]DatabaseTable, 0, len(tables))
+	for _, table := range tables {
+		columns, err := dialects[c.Driver].columns(ctx, db, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("table not found: %s", table)
+		}
+		described = append(described, DatabaseTable{Name: table, Columns: columns})
+	}
+	return described, nil
+}
+
+// Runs an SQL query against a database and returns the results. Connections are read-only unless configured otherwise.
+// connection: Name of the database connection.
+// query: The SQL query to run.
+// format: Format of the results. @enum markdown, json @default markdown
+func DatabaseQuery(connection string, query string, format string) (any, error) {
+	db, c, err := openDatabase(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: !c.Writable && c.Driver != "sqlite"})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := runQuery(ctx, tx, query, c.maxRows())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("query timed out after %s", c.timeout())
+		}
+		return nil, err
+	}
+	if c.Writable {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == "json" {
+		return result, nil
+	}
+	return result.markdown(), nil
+}
+
+func runQuery(ctx context.Context, tx *sql.Tx, query string, maxRows int) (*DatabaseQueryResult, error) {
+	if !returnsRows(query) {
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return &DatabaseQueryResult{}, nil
+		}
+		return &DatabaseQueryResult{RowsAffected: &n}, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DatabaseQueryResult{Columns: columns, Rows: [][]any{}}
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated = true
+			break
+		}
+
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case []byte:
+				values[i] = string(v)
+			case time.Time:
+				values[i] = v.Format(time.RFC3339Nano)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// returnsRows guesses whether a statement returns rows, or should be executed
+// to get the number of rows it affected instead.
+func returnsRows(query string) bool {
+	upper := strings.ToUpper(query)
+	fields := strings.Fields(upper)
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "CREATE", "DROP", "ALTER", "TRUNCATE":
+		return strings.Contains(upper, "RETURNING")
+	}
+	return true
+}
+
+func (r *DatabaseQueryResult) markdown() string {
+	if r.RowsAffected != nil {
+		return fmt.Sprintf("%d rows affected", *r.RowsAffected)
+	}
+	if len(r.Columns) == 0 {
+		return "OK"
+	}
+
+	cell := func(v any) string {
+		var s string
+		switch v := v.(type) {
+		case nil:
+			s = "NULL"
+		case string:
+			s = v
+		default:
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		s = strings.ReplaceAll(s, "|", `\|`)
+		return strings.ReplaceAll(s, "\n", "<br>")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("|")
+	for _, col := range r.Columns {
+		sb.WriteString(" " + cell(col) + " |")
+	}
+	sb.WriteString("\n|")
+	for range r.Columns {
+		sb.WriteString(" --- |")
+	}
+	for _, row := range r.Rows {
+		sb.WriteString("\n|")
+		for _, v := range row {
+			sb.WriteString(" " + cell(v) + " |")
+		}
+	}
+	fmt.Fprintf(&sb, "\n\n%d rows", len(r.Rows))
+	if r.Truncated {
+		sb.WriteString(" (truncated, refine the query to see more)")
+	}
+	return sb.String()
+}
+
+func (c DatabaseConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Timeout)
+}
+
+func (c DatabaseConfig) maxRows() int {
+	if c.MaxRows <= 0 {
+		return 500
+	}
+	return c.MaxRows
+}
+
+var (
+	databases   = map[string]*sql.DB{}
+	databasesMu sync.Mutex
+)
+
+// openDatabase returns the pool of the named connection, opening it on first use.
+func openDatabase(name string) (*sql.DB, DatabaseConfig, error) {
+	c, ok := config.Databases[name]
+	if !ok {
+		return nil, c, fmt.Errorf("unknown database connection: %s", name)
+	}
+	d, ok := dialects[c.Driver]
+	if !ok {
+		return nil, c, fmt.Errorf("%s: unsupported driver %q", name, c.Driver)
+	}
+
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	if db, ok := databases[name]; ok {
+		return db, c, nil
+	}
+
+	dsn := c.DSN
+	if c.Driver == "sqlite" && !c.Writable {
+		// SQLite doesn't support read-only transactions, so make the whole
+		// connection read-only instead.
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "mode=ro&_pragma=query_only(1)"
+		if !strings.HasPrefix(dsn, "file:") {
+			dsn = "file:" + dsn
+		}
+	}
+
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, c, err
+	}
+	databases[name] = db
+	return db, c, nil
+}
+
+// dialect holds the driver specific parts of the Database tools.
+type dialect struct {
+	driver  string
+	tables  string
+	columns func(ctx context.Context, db *sql.DB, table string) ([]DatabaseColumn, error)
+}
+
+var dialects = map[string]dialect{
+	"sqlite": {
+		driver: "sqlite",
+		tables: `SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+		columns: func(ctx context.Context, db *sql.DB, table string) ([]DatabaseColumn, error) {
+			return scanColumns(db.QueryContext(ctx, `SELECT name, type, "notnull" = 0, dflt_value, pk > 0 FROM pragma_table_info(?)`, table))
+		},
+	},
+	"postgres": {
+		driver: "pgx",
+		tables: `SELECT table_schema || '.' || table_name FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY 1`,
+		columns: func(ctx context.Context, db *sql.DB, table string) ([]DatabaseColumn, error) {
+			schema, name, ok := strings.Cut(table, ".")
+			if !ok {
+				schema, name = "public", table
+			}
+			return scanColumns(db.QueryContext(ctx, `
+				SELECT c.column_name, c.data_type, c.is_nullable = 'YES', c.column_default,
+					EXISTS (
+						SELECT 1 FROM information_schema.table_constraints tc
+						JOIN information_schema.key_column_usage k
+							ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema
+						WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema
+							AND tc.table_name = c.table_name AND k.column_name = c.column_name
+					)
+				FROM information_schema.columns c
+				WHERE c.table_schema = $1 AND c.table_name = $2
+				ORDER BY c.ordinal_position`, schema, name))
+		},
+	},
+	"mysql": {
+		driver: "mysql",
+		tables: `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY 1`,
+		columns: func(ctx context.Context, db *sql.DB, table string) ([]DatabaseColumn, error) {
+			return scanColumns(db.QueryContext(ctx, `
+				SELECT column_name, column_type, is_nullable = 'YES', column_default, column_key = 'PRI'
+				FROM information_schema.columns
+				WHERE table_schema = DATABASE() AND table_name = ?
+				ORDER BY ordinal_position`, table))
+		},
+	},
+}
+
+func scanColumns(rows *sql.Rows, err error) ([]DatabaseColumn, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []DatabaseColumn{}
+	for rows.Next() {
+		var col DatabaseColumn
+		var def sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &def, &col.PrimaryKey); err != nil {
+			return nil, err
+		}
+		if def.Valid {
+			col.Default = &def.String
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
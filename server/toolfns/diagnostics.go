@@ -0,0 +1,398 @@
+package toolfns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DiagnosticsReport struct {
+	// Checkers are the checkers that were run.
+	Checkers    []string      `json:"checkers"`
+	Errors      int           `json:"errors"`
+	Warnings    int           `json:"warnings"`
+	Diagnostics []*Diagnostic `json:"diagnostics"`
+	// Truncated reports that there were more diagnostics than returned.
+	Truncated bool `json:"truncated,omitempty"`
+	// Output is the end of the output of a checker that failed without any
+	// diagnostic that could be parsed.
+	Output string `json:"output,omitempty"`
+}
+
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // "error", "warning" or "info"
+	Message  string `json:"message"`
+	// Source is the checker that reported the diagnostic, and its rule or
+	// error code if it has one.
+	Source string `json:"source"`
+	// Context holds the source lines around the diagnostic, with the line
+	// numbers, and the diagnostic's line marked with '>'.
+	Context string `json:"context,omitempty"`
+}
+
+// diagnosticsTimeout bounds the run of each checker.
+const diagnosticsTimeout = 5 * time.Minute
+
+// Checks the code of a project in the workspace with its compiler or linter, and returns the errors and warnings with the source lines around them. Run it after editing code.
+// @mutates
+// checker: The checker to run. go runs go build and go vet. auto picks go if there is a go.mod, and otherwise whichever of svelte-check, tsc and eslint the project uses. @enum auto, go, tsc, eslint, svelte-check @default auto
+// path: The directory of the project, relative to the workspace. @default .
+// context: Number of source lines to show before and after each diagnostic. @min 0 @max 10 @default 2
+// limit: Maximum number of diagnostics to return, errors first. @min 1 @max 500 @default 100
+func Diagnostics(ws *Workspace, checker string, path string, context int, limit int) (*DiagnosticsReport, error) {
+	dir, err := ws.Path(path)
+	if err != nil {
+		return nil, err
+	}
+	checkers := []string{checker}
+	if checker == "auto" {
+		if checkers, err = detectCheckers(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &DiagnosticsReport{Diagnostics: []*Diagnostic{}}
+	var all []*Diagnostic
+	for _, c := range checkers {
+		var diags []*Diagnostic
+		var output string
+		switch c {
+		case "go":
+			diags, output, err = goDiagnostics(dir)
+		case "tsc":
+			diags, output, err = tscDiagnostics(dir)
+		case "eslint":
+			diags, output, err = eslintDiagnostics(dir)
+		case "svelte-check":
+			diags, output, err = svelteCheckDiagnostics(dir)
+		default:
+			return nil, fmt.Errorf("unknown checker %q", c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c, err)
+		}
+		report.Checkers = append(report.Checkers, c)
+		all = append(all, diags...)
+		if output != "" {
+			report.Output = strings.TrimSpace(report.Output + "\n" + output)
+		}
+	}
+
+	// Checkers often report the same problem more than once, such as go build
+	// and go vet, or tsc and svelte-check.
+	seen := map[string]bool{}
+	var unique []*Diagnostic
+	for _, d := range all {
+		d.File = relativeTestPath(dir, d.File)
+		key := fmt.Sprintf("%s:%d:%d:%s", d.File, d.Line, d.Column, d.Message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, d)
+		switch d.Severity {
+		case "error":
+			report.Errors++
+		case "warning":
+			report.Warnings++
+		}
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		a, b := unique[i], unique[j]
+		if (a.Severity == "error") != (b.Severity == "error") {
+			return a.Severity == "error"
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	if len(unique) > limit {
+		unique = unique[:limit]
+		report.Truncated = true
+	}
+
+	files := map[string][]string{}
+	for _, d := range unique {
+		if d.Line > 0 {
+			d.Context = sourceContext(ws, dir, d.File, d.Line, context, files)
+		}
+		report.Diagnostics = append(report.Diagnostics, d)
+	}
+	report.Output = truncateHead(report.Output, maxTestOutput)
+	return report, nil
+}
+
+// detectCheckers picks the checkers of the project in dir.
+func detectCheckers(dir string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return []string{"go"}, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, errors.New("couldn't tell how to check the project: pass a checker")
+	}
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("reading package.json: %w", err)
+	}
+	uses := func(name string) bool {
+		_, dep := pkg.Dependencies[name]
+		_, dev := pkg.DevDependencies[name]
+		return dep || dev
+	}
+
+	var checkers []string
+	switch {
+	case uses("svelte-check"):
+		// svelte-check also type checks the TypeScript files.
+		checkers = append(checkers, "svelte-check")
+	case uses("typescript"):
+		checkers = append(checkers, "tsc")
+	}
+	if uses("eslint") {
+		checkers = append(checkers, "eslint")
+	}
+	if len(checkers) == 0 {
+		return nil, errors.New("the project has none of svelte-check, typescript and eslint in its dependencies: pass a checker")
+	}
+	return checkers, nil
+}
+
+// runChecker runs a checker in dir, and returns its stdout and stderr. A
+// non-zero exit isn't an error, as checkers exit with 1 when they find
+// problems.
+func runChecker(dir, name string, args ...string) (stdout, stderr string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+	cmd := projectCommand(ctx, dir, name, args...)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", "", fmt.Errorf("timed out after %s", diagnosticsTimeout)
+	}
+	if errors.As(err, new(*exec.ExitError)) {
+		err = nil
+	}
+	return out.String(), errOut.String(), err
+}
+
+var goDiagnosticRegex = regexp.MustCompile(`^(?:vet: )?(\S+?\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+func goDiagnostics(dir string) ([]*Diagnostic, string, error) {
+	var diags []*Diagnostic
+	var output []string
+	for _, step := range []struct{ name, severity string }{{"build", "error"}, {"vet", "warning"}} {
+		_, stderr, err := runChecker(dir, "go", step.name, "./...")
+		if err != nil {
+			return nil, "", err
+		}
+		found := false
+		var last *Diagnostic
+		for _, line := range strings.Split(stderr, "\n") {
+			if m := goDiagnosticRegex.FindStringSubmatch(line); m != nil {
+				last = &Diagnostic{File: m[1], Severity: step.severity, Message: m[4], Source: "go " + step.name}
+				last.Line, _ = strconv.Atoi(m[2])
+				last.Column, _ = strconv.Atoi(m[3])
+				// Type errors found by vet are still errors.
+				if step.name == "vet" && strings.HasPrefix(line, "vet: ") {
+					last.Severity = "error"
+				}
+				diags = append(diags, last)
+				found = true
+			} else if last != nil && strings.HasPrefix(line, "\t") {
+				last.Message += "\n" + strings.TrimSpace(line)
+			} else {
+				last = nil
+			}
+		}
+		if !found && strings.TrimSpace(stderr) != "" && !onlyPackageHeaders(stderr) {
+			output = append(output, stderr)
+		}
+		if step.name == "build" && found {
+			// vet would only report the same errors again.
+			break
+		}
+	}
+	return diags, strings.Join(output, "\n"), nil
+}
+
+// onlyPackageHeaders reports whether the output of go vet holds nothing but
+// "# package" lines.
+func onlyPackageHeaders(s string) bool {
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		if !strings.HasPrefix(line, "# ") {
+			return false
+		}
+	}
+	return true
+}
+
+var tscDiagnosticRegex = regexp.MustCompile(`^(.+?)\((\d+),(\d+)\): (error|warning|message) (TS\d+): (.*)$`)
+
+func tscDiagnostics(dir string) ([]*Diagnostic, string, error) {
+	stdout, stderr, err := runChecker(dir, "npx", "--no-install", "tsc", "--noEmit", "--pretty", "false")
+	if err != nil {
+		return nil, "", err
+	}
+	var diags []*Diagnostic
+	var last *Diagnostic
+	for _, line := range strings.Split(stdout, "\n") {
+		if m := tscDiagnosticRegex.FindStringSubmatch(line); m != nil {
+			last = &Diagnostic{File: m[1], Severity: m[4], Message: m[6], Source: "tsc " + m[5]}
+			if last.Severity == "message" {
+				last.Severity = "info"
+			}
+			last.Line, _ = strconv.Atoi(m[2])
+			last.Column, _ = strconv.Atoi(m[3])
+			diags = append(diags, last)
+		} else if last != nil && strings.HasPrefix(line, " ") {
+			last.Message += "\n" + strings.TrimSpace(line)
+		}
+	}
+	if len(diags) == 0 {
+		return nil, strings.TrimSpace(stdout + "\n" + stderr), nil
+	}
+	return diags, "", nil
+}
+
+func eslintDiagnostics(dir string) ([]*Diagnostic, string, error) {
+	stdout, stderr, err := runChecker(dir, "npx", "--no-install", "eslint", "--format", "json", ".")
+	if err != nil {
+		return nil, "", err
+	}
+	var files []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &files); err != nil {
+		// eslint failed before linting, such as with a broken config.
+		return nil, strings.TrimSpace(stdout + "\n" + stderr), nil
+	}
+
+	var diags []*Diagnostic
+	for _, f := range files {
+		for _, m := range f.Messages {
+			d := &Diagnostic{File: f.FilePath, Line: m.Line, Column: m.Column, Severity: "warning", Message: m.Message, Source: "eslint"}
+			if m.Severity == 2 {
+				d.Severity = "error"
+			}
+			if m.RuleID != "" {
+				d.Source += " " + m.RuleID
+			}
+			diags = append(diags, d)
+		}
+	}
+	return diags, "", nil
+}
+
+// svelteCheckRegex matches the diagnostics of `svelte-check --output machine`,
+// such as:
+//
+//	1590680326283 ERROR "src/App.svelte" 1:16 "Cannot find name 'foo'"
+var svelteCheckRegex = regexp.MustCompile(`^\d+ (ERROR|WARNING|HINT) ("(?:[^"\\]|\\.)*") (\d+):(\d+) ("(?:[^"\\]|\\.)*")(?: ("(?:[^"\\]|\\.)*"))?`)
+
+func svelteCheckDiagnostics(dir string) ([]*Diagnostic, string, error) {
+	stdout, stderr, err := runChecker(dir, "npx", "--no-install", "svelte-check", "--output", "machine")
+	if err != nil {
+		return nil, "", err
+	}
+	var diags []*Diagnostic
+	completed := false
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, " COMPLETED ") {
+			completed = true
+		}
+		m := svelteCheckRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := &Diagnostic{Severity: strings.ToLower(m[1]), Source: "svelte-check"}
+		if d.Severity == "hint" {
+			d.Severity = "info"
+		}
+		d.File, _ = strconv.Unquote(m[2])
+		d.Message, _ = strconv.Unquote(m[5])
+		if m[6] != "" {
+			if code, err := strconv.Unquote(m[6]); err == nil && code != "" {
+				d.Source += " " + code
+			}
+		}
+		d.Line, _ = strconv.Atoi(m[3])
+		d.Column, _ = strconv.Atoi(m[4])
+		diags = append(diags, d)
+	}
+	if !completed {
+		return diags, strings.TrimSpace(stdout + "\n" + stderr), nil
+	}
+	return diags, "", nil
+}
+
+// sourceContext returns the lines of file around line, numbered. Files are
+// read once, through files.
+func sourceContext(ws *Workspace, dir, file string, line, around int, files map[string][]string) string {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, filepath.FromSlash(file))
+	}
+	if path != ws.Dir && !strings.HasPrefix(path, ws.Dir+string(filepath.Separator)) {
+		return ""
+	}
+
+	lines, ok := files[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		files[path] = lines
+	}
+	if line > len(lines) {
+		return ""
+	}
+
+	first, last := max(1, line-around), min(len(lines), line+around)
+	width := len(strconv.Itoa(last))
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		numbered := fmt.Sprintf("%s %*d | %s", marker, width, n, lines[n-1])
+		b.WriteString(strings.TrimRight(numbered, " \t\r") + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
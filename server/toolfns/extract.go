@@ -0,0 +1,907 @@
+package toolfns
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type ExtractResult struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title,omitempty"`
+	Pages     int      `json:"pages,omitempty"`
+	Sections  []string `json:"sections,omitempty"`
+	Content   string   `json:"content"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+const (
+	maxExtractContent  = 100 * 1024
+	maxExtractSections = 200
+)
+
+// document is the text of a file, split into the units pages are selected by.
+type document struct {
+	Title string
+	// Unit is what the parts are called, such as "page" or "sheet". It is
+	// empty if the document can't be split.
+	Unit  string
+	Parts []string
+	// Pages is the page count, if known.
+	Pages int
+}
+
+// Extracts the text of a PDF, DOCX, XLSX, HTML or EPUB file in the workspace as Markdown or plain text, along with its title, page count and sections.
+// path: Path of the file, relative to the workspace.
+// pages: Pages to extract, such as "1-3,7". For XLSX files these are sheets, and for EPUB files chapters. @optional @example 1-3,7
+// section: Only extract the section under the first heading containing this text. See the sections of the result. @optional
+// format: The format of the extracted content. @enum markdown, text @default markdown
+func Extract(ws *Workspace, path string, pages string, section string, format string) (*ExtractResult, error) {
+	p, err := ws.Path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *document
+	typ := strings.TrimPrefix(strings.ToLower(filepath.Ext(p)), ".")
+	switch typ {
+	case "pdf":
+		doc, err = extractPDF(p)
+	case "docx":
+		doc, err = extractDOCX(p)
+	case "xlsx":
+		doc, err = extractXLSX(p)
+	case "html", "htm", "xhtml":
+		typ = "html"
+		doc, err = extractHTMLFile(p)
+	case "epub":
+		doc, err = extractEPUB(p)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(p))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExtractResult{
+		Type:     typ,
+		Title:    strings.TrimSpace(doc.Title),
+		Pages:    doc.Pages,
+		Sections: []string{},
+	}
+
+	parts := doc.Parts
+	numbers := make([]int, len(parts))
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	if pages != "" {
+		if doc.Unit == "" {
+			return nil, fmt.Errorf("%s files can't be split into pages, select a section instead", typ)
+		}
+		numbers, err = parsePageRanges(pages, len(parts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var content strings.Builder
+	for _, n := range numbers {
+		part := strings.TrimSpace(parts[n-1])
+		if doc.Unit != "" && len(parts) > 1 {
+			if content.Len() > 0 {
+				content.WriteString("\n\n")
+			}
+			fmt.Fprintf(&content, "--- %s %d ---\n\n", doc.Unit, n)
+		} else if content.Len() > 0 {
+			content.WriteString("\n\n")
+		}
+		content.WriteString(part)
+	}
+	result.Content = content.String()
+
+	for _, line := range strings.Split(result.Content, "\n") {
+		if len(result.Sections) == maxExtractSections {
+			break
+		}
+		if level, _ := markdownHeading(line); level > 0 {
+			result.Sections = append(result.Sections, line)
+		}
+	}
+
+	if section != "" {
+		s, ok := markdownSection(result.Content, section)
+		if !ok {
+			return nil, fmt.Errorf("no section matching %q", section)
+		}
+		result.Content = s
+	}
+	if format == "text" {
+		result.Content = markdownToText(result.Content)
+	}
+
+	if len(result.Content) > maxExtractContent {
+		result.Content = strings.ToValidUTF8(result.Content[:maxExtractContent], "")
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// parsePageRanges parses a list of page numbers and ranges, such as "1-3,7",
+// into page numbers between 1 and n.
+func parsePageRanges(s string, n int) ([]int, error) {
+	var pages []int
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range %q", r)
+		}
+		end := start
+		if isRange {
+			end = n
+			if last = strings.TrimSpace(last); last != "" {
+				if end, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("invalid page range %q", r)
+				}
+			}
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("page range %q is outside of 1-%d", r, n)
+		}
+		for p := start; p <= end; p++ {
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
+
+// markdownHeading returns the level and text of a Markdown heading line, or 0
+// if the line isn't a heading.
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(line[level:])
+}
+
+// markdownSection returns the first section of content whose heading contains
+// title, up to the next heading of the same or a higher level.
+func markdownSection(content, title string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	title = strings.ToLower(title)
+	inFence := false
+	start, level := -1, 0
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		l, text := markdownHeading(line)
+		if l == 0 {
+			continue
+		}
+		if start >= 0 && l <= level {
+			return strings.TrimSpace(strings.Join(lines[start:i], "\n")), true
+		}
+		if start < 0 && strings.Contains(strings.ToLower(text), title) {
+			start, level = i, l
+		}
+	}
+	if start < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(strings.Join(lines[start:], "\n")), true
+}
+
+var (
+	markdownLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile(`\*\*([^*]+)\*\*|\*([^*\s][^*]*)\*`)
+)
+
+// markdownToText removes the Markdown syntax from the output of the
+// extractors.
+func markdownToText(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			continue
+		}
+		if _, text := markdownHeading(line); text != "" {
+			line = text
+		}
+		line = markdownLink.ReplaceAllString(line, "$1")
+		line = markdownEmphasis.ReplaceAllString(line, "$1$2")
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+func extractPDF(p string) (*document, error) {
+	title, pages, err := readPDF(p)
+	if err != nil {
+		return nil, err
+	}
+	return &document{
+		Title: title,
+		Unit:  "page",
+		Pages: len(pages),
+		Parts: pages,
+	}, nil
+}
+
+func extractHTMLFile(p string) (*document, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	title, content, err := htmlToMarkdown(f)
+	if err != nil {
+		return nil, err
+	}
+	return &document{Title: title, Parts: []string{content}, Pages: 1}, nil
+}
+
+// htmlToMarkdown converts an HTML document to Markdown, and returns its title.
+func htmlToMarkdown(r io.Reader) (string, string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	var title string
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.DataAtom == atom.Title && title == "" {
+			title = collapseSpace(textContent(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(root)
+
+	var w markdownWriter
+	w.blocks(root)
+	return title, w.String(), nil
+}
+
+type markdownWriter struct {
+	strings.Builder
+}
+
+// block writes s as a paragraph.
+func (w *markdownWriter) block(s string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	if w.Len() > 0 {
+		w.WriteString("\n\n")
+	}
+	w.WriteString(s)
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Body: true, atom.Details: true, atom.Dd: true, atom.Div: true, atom.Dl: true,
+	atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true,
+	atom.Html: true, atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Ul: true,
+}
+
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Button: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// blocks writes the children of n, grouping runs of inline content into
+// paragraphs.
+func (w *markdownWriter) blocks(n *html.Node) {
+	var inline strings.Builder
+	flush := func() {
+		w.block(collapseLines(inline.String()))
+		inline.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && skippedElements[c.DataAtom] {
+			continue
+		}
+		if c.Type != html.ElementNode || !blockElements[c.DataAtom] {
+			inline.WriteString(inlineMarkdown(c))
+			continue
+		}
+		flush()
+
+		switch c.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			if text := collapseSpace(inlineMarkdown(c)); text != "" {
+				w.block(strings.Repeat("#", headingLevels[c.DataAtom]) + " " + text)
+			}
+		case atom.P, atom.Dt, atom.Summary, atom.Figcaption:
+			w.block(collapseLines(inlineMarkdown(c)))
+		case atom.Pre:
+			w.block("```\n" + strings.Trim(textContent(c), "\n") + "\n```")
+		case atom.Ul, atom.Ol:
+			w.block(listMarkdown(c, 0))
+		case atom.Hr:
+			w.block("---")
+		case atom.Table:
+			w.block(tableMarkdown(c))
+		case atom.Blockquote:
+			var q markdownWriter
+			q.blocks(c)
+			lines := strings.Split(q.String(), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSpace("> " + line)
+			}
+			w.block(strings.Join(lines, "\n"))
+		default:
+			w.blocks(c)
+		}
+	}
+	flush()
+}
+
+// inlineMarkdown returns the Markdown of n as inline content.
+func inlineMarkdown(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type == html.ElementNode && skippedElements[n.DataAtom] {
+		return ""
+	}
+
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(inlineMarkdown(c))
+	}
+	s := b.String()
+	if n.Type != html.ElementNode {
+		return s
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Img:
+		return attr(n, "alt")
+	case atom.A:
+		href := attr(n, "href")
+		text := strings.TrimSpace(collapseSpace(s))
+		if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			return s
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Strong, atom.B:
+		if strings.TrimSpace(s) == "" {
+			return s
+		}
+		return "**" + strings.TrimSpace(s) + "**"
+	case atom.Em, atom.I:
+		if strings.TrimSpace(s) == "" {
+			return s
+		}
+		return "*" + strings.TrimSpace(s) + "*"
+	case atom.Code, atom.Kbd, atom.Samp:
+		if strings.TrimSpace(s) == "" || strings.Contains(s, "\n") {
+			return s
+		}
+		return "`" + s + "`"
+	}
+	if blockElements[n.DataAtom] {
+		return " " + s + " "
+	}
+	return s
+}
+
+// listMarkdown returns the Markdown of an ul or ol element, with nested lists
+// indented by depth.
+func listMarkdown(n *html.Node, depth int) string {
+	var lines []string
+	indent := strings.Repeat("  ", depth)
+	i := 0
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		i++
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(i) + ". "
+		}
+
+		var text strings.Builder
+		var nested []string
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Ul || c.DataAtom == atom.Ol {
+				nested = append(nested, listMarkdown(c, depth+1))
+			} else {
+				text.WriteString(inlineMarkdown(c))
+			}
+		}
+		lines = append(lines, indent+marker+collapseSpace(text.String()))
+		lines = append(lines, nested...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tableMarkdown returns the Markdown of a table element. The first row is used
+// as the header.
+func tableMarkdown(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row = append(row, collapseSpace(inlineMarkdown(cell)))
+					}
+				}
+				rows = append(rows, row)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return markdownTable(rows)
+}
+
+// markdownTable formats rows as a Markdown table, using the first row as the
+// header.
+func markdownTable(rows [][]string) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if width == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < width; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = strings.ReplaceAll(strings.ReplaceAll(row[i], "|", `\|`), "\n", " ")
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapseSpace replaces runs of whitespace with a single space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collapseLines collapses the whitespace of each line of s.
+func collapseLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = collapseSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func extractEPUB(p string) (*document, error) {
+	z, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	var container struct {
+		Rootfiles []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readZipXML(&z.Reader, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub has no package file")
+	}
+	opfPath := container.Rootfiles[0].Path
+
+	var pkg struct {
+		Title    string `xml:"metadata>title"`
+		Manifest []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := readZipXML(&z.Reader, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	hrefs := map[string]string{}
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	doc := &document{Title: pkg.Title, Unit: "chapter"}
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		f, err := z.Open(path.Join(path.Dir(opfPath), href))
+		if err != nil {
+			return nil, err
+		}
+		_, content, err := htmlToMarkdown(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", href, err)
+		}
+		doc.Parts = append(doc.Parts, content)
+	}
+	doc.Pages = len(doc.Parts)
+	return doc, nil
+}
+
+func extractDOCX(p string) (*document, error) {
+	z, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	doc := &document{}
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if readZipXML(&z.Reader, "docProps/core.xml", &core) == nil {
+		doc.Title = core.Title
+	}
+	var app struct {
+		Pages int `xml:"Pages"`
+	}
+	if readZipXML(&z.Reader, "docProps/app.xml", &app) == nil {
+		doc.Pages = app.Pages
+	}
+
+	// Heading styles are found by name, since style IDs are localized.
+	var styles struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+		} `xml:"style"`
+	}
+	headings := map[string]int{}
+	if readZipXML(&z.Reader, "word/styles.xml", &styles) == nil {
+		for _, s := range styles.Styles {
+			name := strings.ToLower(s.Name.Val)
+			if name == "title" {
+				headings[s.ID] = 1
+			} else if level, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && strings.HasPrefix(name, "heading ") {
+				headings[s.ID] = min(level, 6)
+			}
+		}
+	}
+
+	f, err := z.Open("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var w markdownWriter
+	var (
+		para      strings.Builder
+		style     string
+		listItem  bool
+		inText    bool
+		tableRows [][]string
+		tableRow  []string
+		cell      []string
+		depth     int // depth of nested tables
+		inList    bool
+	)
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style, listItem = "", false
+			case "pStyle":
+				style = xmlAttr(t, "val")
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				depth++
+				if depth == 1 {
+					tableRows = nil
+				}
+			case "tr":
+				tableRow = nil
+			case "tc":
+				cell = nil
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				switch {
+				case depth > 0:
+					cell = append(cell, text)
+				case text == "":
+				case headings[style] > 0:
+					w.block(strings.Repeat("#", headings[style]) + " " + collapseSpace(text))
+				case listItem && inList:
+					// Consecutive list items are written as one list.
+					w.WriteString("\n- " + text)
+				case listItem:
+					w.block("- " + text)
+				default:
+					w.block(text)
+				}
+				if depth == 0 && text != "" {
+					inList = listItem
+				}
+			case "tc":
+				tableRow = append(tableRow, strings.TrimSpace(strings.Join(cell, " ")))
+			case "tr":
+				if depth == 1 {
+					tableRows = append(tableRows, tableRow)
+				}
+			case "tbl":
+				depth--
+				if depth == 0 {
+					w.block(markdownTable(tableRows))
+					inList = false
+				}
+			}
+		}
+	}
+
+	doc.Parts = []string{w.String()}
+	return doc, nil
+}
+
+func extractXLSX(p string) (*document, error) {
+	z, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	doc := &document{Unit: "sheet"}
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if readZipXML(&z.Reader, "docProps/core.xml", &core) == nil {
+		doc.Title = core.Title
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readZipXML(&z.Reader, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readZipXML(&z.Reader, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	var sst struct {
+		Items []struct {
+			Text string `xml:",innerxml"`
+		} `xml:"si"`
+	}
+	var shared []string
+	if readZipXML(&z.Reader, "xl/sharedStrings.xml", &sst) == nil {
+		for _, item := range sst.Items {
+			shared = append(shared, xmlText(item.Text))
+		}
+	}
+
+	for _, s := range workbook.Sheets {
+		var sheet struct {
+			Rows []struct {
+				Cells []struct {
+					Ref    string `xml:"r,attr"`
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline string `xml:",innerxml"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err := readZipXML(&z.Reader, targets[s.RID], &sheet); err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", s.Name, err)
+		}
+
+		var rows [][]string
+		for _, r := range sheet.Rows {
+			var row []string
+			for i, c := range r.Cells {
+				col := i
+				if c.Ref != "" {
+					col = cellColumn(c.Ref)
+				}
+				for len(row) < col {
+					row = append(row, "")
+				}
+
+				value := c.Value
+				switch c.Type {
+				case "s":
+					if n, err := strconv.Atoi(c.Value); err == nil && n >= 0 && n < len(shared) {
+						value = shared[n]
+					}
+				case "inlineStr":
+					value = xmlText(c.Inline)
+				case "b":
+					value = map[string]string{"0": "FALSE", "1": "TRUE"}[c.Value]
+				}
+				row = append(row, value)
+			}
+			rows = append(rows, row)
+		}
+
+		part := "## " + s.Name
+		if table := markdownTable(rows); table != "" {
+			part += "\n\n" + table
+		}
+		doc.Parts = append(doc.Parts, part)
+	}
+	doc.Pages = len(doc.Parts)
+	return doc, nil
+}
+
+// cellColumn returns the zero-based column of a cell reference such as "C7".
+func cellColumn(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+var xmlTextElement = regexp.MustCompile(`(?s)<(?:\w+:)?t(?:\s[^>]*)?>(.*?)</(?:\w+:)?t>`)
+
+// xmlText returns the text of the <t> elements in an inner XML string, as used
+// by shared and inline strings.
+func xmlText(inner string) string {
+	var b strings.Builder
+	for _, m := range xmlTextElement.FindAllStringSubmatch(inner, -1) {
+		var s string
+		if xml.Unmarshal([]byte("<t>"+m[1]+"</t>"), &s) == nil {
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func readZipXML(z *zip.Reader, name string, v any) error {
+	f, err := z.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
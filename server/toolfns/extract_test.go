@@ -0,0 +1,15 @@
+package toolfns
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractMalformedPDF(t *testing.T) {
+	path := writePDF(t, "<< /Type /Catalog /Pages 2 0 R ) >>", "hello")
+	ws := &Workspace{ChatID: "test", Dir: filepath.Dir(path)}
+	if _, err := Extract(ws, filepath.Base(path), "", "", "markdown"); err == nil || !strings.Contains(err.Error(), "malformed PDF") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package toolfns
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileReference points to a file in a workspace. The UI shows it as a link to
+// download the file from the tool server, at URL.
+type FileReference struct {
+	// Type is always "file", and tells the UI to show a download link.
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	// URL is the path of the file on the tool server.
+	URL string `json:"url"`
+}
+
+// FileReference returns a reference to a file of the workspace, to return
+// from tools that create files the user may want.
+func (w *Workspace) FileReference(rel string) (*FileReference, error) {
+	path, err := w.Path(rel)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", rel)
+	}
+	rel, err = filepath.Rel(w.Dir, path)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	return &FileReference{
+		Type:        "file",
+		Name:        info.Name(),
+		Path:        rel,
+		ContentType: FileContentType(path),
+		Size:        info.Size(),
+		URL:         FileURL(w.ChatID, rel),
+	}, nil
+}
+
+// FileURL returns the path a file of a workspace is downloaded from.
+func FileURL(chatID, rel string) string {
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return "/files/" + url.PathEscape(chatID) + "/" + strings.Join(segments, "/")
+}
+
+// FileContentType returns the content type of a file, from its extension, or
+// from its first bytes when the extension isn't known.
+func FileContentType(path string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(path)); ct != "" {
+		return ct
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
+
+// Shares a file of the workspace with the user, who gets a link to download it. Use it to hand over the files you made, such as reports or archives.
+// path: Path of the file in the workspace.
+func ShareFile(ws *Workspace, path string) (*FileReference, error) {
+	ref, err := ws.FileReference(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s doesn't exist", path)
+	}
+	return ref, err
+}
@@ -1,4 +1,4 @@
-// generated @ 2025-03-08T22:54:18+02:00 by gendoc
+// generated @ 2026-10-19T15:16:51Z by gendoc
 package toolfns
 
 import "github.com/noonien/codoc"
@@ -7,32 +7,3088 @@ func init() {
 	codoc.Register(codoc.Package{
 		ID:   "github.com/zakkor/server/toolfns",
 		Name: "toolfns",
-		Doc:  "generated @ 2025-03-08T22:54:11+02:00 by gendoc",
+		Doc:  "generated @ 2026-10-19T15:14:45Z by gendoc",
 		Functions: map[string]codoc.Function{
+			"AnswerQuestion": {
+				Name: "AnswerQuestion",
+				Doc:  "AnswerQuestion passes the answer of the user to the tool that asked.",
+				Args: []string{
+					"chatID",
+					"a",
+				},
+			},
+			"Chart": {
+				Name: "Chart",
+				Doc:  "Draws a line, bar, scatter or pie chart, and shows it to the user.\n@mutates\nkind: The kind of chart. @enum line, bar, scatter, pie\nseries: The data series. Pie charts take a single series. @min 1\nlabels: Category labels of bar charts and slice labels of pie charts. In line charts, labels for the x axis. @optional\ntitle: Title of the chart. @optional\nxlabel: Label of the x axis. @optional\nylabel: Label of the y axis. @optional\nwidth: Width in pixels. @min 200 @max 4000 @default 800\nheight: Height in pixels. @min 200 @max 4000 @default 500\nformat: Format of the chart. @enum png, svg @default png\noutput: Path in the workspace to save the chart to. @optional",
+				Args: []string{
+					"ws",
+					"kind",
+					"series",
+					"labels",
+					"title",
+					"xlabel",
+					"ylabel",
+					"width",
+					"height",
+					"format",
+					"output",
+				},
+			},
+			"CheckChatID": {
+				Name: "CheckChatID",
+				Doc:  "CheckChatID returns ErrInvalidChatID if chatID can't name a workspace.",
+				Args: []string{
+					"chatID",
+				},
+			},
+			"CloseOutputs": {
+				Name: "CloseOutputs",
+				Doc:  "CloseOutputs deletes the outputs stored for ReadOutput.",
+			},
+			"CloseSSH": {
+				Name: "CloseSSH",
+				Doc:  "CloseSSH closes the connections of the SSH tools.",
+			},
+			"CloseTerminal": {
+				Name: "CloseTerminal",
+				Doc:  "Closes a terminal session, hanging up on the program running in it.\nname: The name of the session.",
+				Args: []string{
+					"ws",
+					"name",
+				},
+			},
+			"CloseTerminals": {
+				Name: "CloseTerminals",
+				Doc:  "CloseTerminals closes the terminal sessions of all chats.",
+			},
+			"DatabaseConnections": {
+				Name: "DatabaseConnections",
+				Doc:  "Lists the configured database connections.",
+			},
+			"DatabaseQuery": {
+				Name: "DatabaseQuery",
+				Doc:  "Runs an SQL query against a database and returns the results. Connections are read-only unless configured otherwise.\nconnection: Name of the database connection.\nquery: The SQL query to run.\nformat: Format of the results. @enum markdown, json @default markdown",
+				Args: []string{
+					"connection",
+					"query",
+					"format",
+				},
+			},
+			"DatabaseSchema": {
+				Name: "DatabaseSchema",
+				Doc:  "Describes the columns of database tables.\nconnection: Name of the database connection.\ntables: Names of the tables to describe. Describes all tables if empty. @optional",
+				Args: []string{
+					"connection",
+					"tables",
+				},
+			},
+			"DatabaseTables": {
+				Name: "DatabaseTables",
+				Doc:  "Lists the tables and views of a database.\nconnection: Name of the database connection.",
+				Args: []string{
+					"connection",
+				},
+			},
+			"Diagnostics": {
+				Name: "Diagnostics",
+				Doc:  "Checks the code of a project in the workspace with its compiler or linter, and returns the errors and warnings with the source lines around them. Run it after editing code.\n@mutates\nchecker: The checker to run. go runs go build and go vet. auto picks go if there is a go.mod, and otherwise whichever of svelte-check, tsc and eslint the project uses. @enum auto, go, tsc, eslint, svelte-check @default auto\npath: The directory of the project, relative to the workspace. @default .\ncontext: Number of source lines to show before and after each diagnostic. @min 0 @max 10 @default 2\nlimit: Maximum number of diagnostics to return, errors first. @min 1 @max 500 @default 100",
+				Args: []string{
+					"ws",
+					"checker",
+					"path",
+					"context",
+					"limit",
+				},
+			},
+			"Extract": {
+				Name: "Extract",
+				Doc:  "Extracts the text of a PDF, DOCX, XLSX, HTML or EPUB file in the workspace as Markdown or plain text, along with its title, page count and sections.\npath: Path of the file, relative to the workspace.\npages: Pages to extract, such as \"1-3,7\". For XLSX files these are sheets, and for EPUB files chapters. @optional @example 1-3,7\nsection: Only extract the section under the first heading containing this text. See the sections of the result. @optional\nformat: The format of the extracted content. @enum markdown, text @default markdown",
+				Args: []string{
+					"ws",
+					"path",
+					"pages",
+					"section",
+					"format",
+				},
+			},
+			"FileContentType": {
+				Name: "FileContentType",
+				Doc:  "FileContentType returns the content type of a file, from its extension, or\nfrom its first bytes when the extension isn't known.",
+				Args: []string{
+					"path",
+				},
+			},
+			"FileURL": {
+				Name: "FileURL",
+				Doc:  "FileURL returns the path a file of a workspace is downloaded from.",
+				Args: []string{
+					"chatID",
+					"rel",
+				},
+			},
+			"Forget": {
+				Name: "Forget",
+				Doc:  "Forgets a remembered entry.\nid: The ID of the entry, as returned by Remember, Recall or ListMemories.",
+				Args: []string{
+					"ws",
+					"id",
+				},
+			},
+			"GitBlame": {
+				Name: "GitBlame",
+				Doc:  "Returns the commit that last changed each line of a file.\nrepo: Path of the repository, relative to the workspace. @default .\npath: Path of the file, relative to the repository.\nrev: Revision to blame at. Uses the working tree if empty. @optional\nstart: First line to blame. @min 1 @optional\nend: Last line to blame. @min 1 @optional",
+				Args: []string{
+					"ws",
+					"repo",
+					"path",
+					"rev",
+					"start",
+					"end",
+				},
+			},
+			"GitBranch": {
+				Name: "GitBranch",
+				Doc:  "Lists the local branches of a git repository, optionally creating a new one first.\n@mutates\nrepo: Path of the repository, relative to the workspace. @default .\ncreate: Name of a branch to create. @optional\nstart: Revision the created branch starts at. Defaults to HEAD. @optional",
+				Args: []string{
+					"ws",
+					"repo",
+					"create",
+					"start",
+				},
+			},
+			"GitCheckout": {
+				Name: "GitCheckout",
+				Doc:  "Switches to a branch or commit, or restores files from it, and returns the resulting status.\n@mutates\nrepo: Path of the repository, relative to the workspace. @default .\nref: The branch, tag or commit to check out.\ncreate: Create ref as a new branch before switching to it. @optional\npaths: Only restore these paths from ref, without switching. @optional",
+				Args: []string{
+					"ws",
+					"repo",
+					"ref",
+					"create",
+					"paths",
+				},
+			},
+			"GitCommit": {
+				Name: "GitCommit",
+				Doc:  "Records changes to a git repository, and returns the created commit.\n@mutates\nrepo: Path of the repository, relative to the workspace. @default .\nmessage: The commit message.\npaths: Paths to stage before committing. @optional\nall: Stage all modified and deleted files before committing. @optional",
+				Args: []string{
+					"ws",
+					"repo",
+					"message",
+					"paths",
+					"all",
+				},
+			},
+			"GitDiff": {
+				Name: "GitDiff",
+				Doc:  "Returns the changes in a git repository as a list of files with their hunks.\nrepo: Path of the repository, relative to the workspace. @default .\nrev: Revision or range to compare against, such as HEAD~1 or main..feature. Compares the working tree to the index if empty. @optional\nstaged: Show the staged changes instead of the unstaged ones. @optional\npaths: Only show changes to these paths. @optional\ncontext: Number of context lines around each change. @min 0 @max 100 @default 3",
+				Args: []string{
+					"ws",
+					"repo",
+					"rev",
+					"staged",
+					"paths",
+					"context",
+				},
+			},
+			"GitLog": {
+				Name: "GitLog",
+				Doc:  "Returns the commit history of a git repository, with the files changed by each commit.\nrepo: Path of the repository, relative to the workspace. @default .\nrev: Revision or range to list, such as HEAD or main..feature. @default HEAD\npaths: Only list commits that change these paths. @optional\nlimit: Maximum number of commits to return. @min 1 @max 500 @default 20",
+				Args: []string{
+					"ws",
+					"repo",
+					"rev",
+					"paths",
+					"limit",
+				},
+			},
+			"GitShow": {
+				Name: "GitShow",
+				Doc:  "Returns the metadata and changes of a single commit.\nrepo: Path of the repository, relative to the workspace. @default .\nrev: The commit to show. @default HEAD\npaths: Only show changes to these paths. @optional",
+				Args: []string{
+					"ws",
+					"repo",
+					"rev",
+					"paths",
+				},
+			},
+			"GitStatus": {
+				Name: "GitStatus",
+				Doc:  "Returns the current branch and the status of the changed files in a git repository.\nrepo: Path of the repository, relative to the workspace. @default .",
+				Args: []string{
+					"ws",
+					"repo",
+				},
+			},
+			"HTTPDestinations": {
+				Name: "HTTPDestinations",
+				Doc:  "Lists the hosts that HTTP requests can be sent to, and the names of the secrets that can be attached to them.",
+			},
+			"HTTPRequest": {
+				Name: "HTTPRequest",
+				Doc:  "Sends an HTTP request and returns the status, headers and body of the response. Only allowed hosts can be reached.\nmethod: The HTTP method. @enum GET, POST, PUT, PATCH, DELETE, HEAD @default GET\nurl: The URL to send the request to. @format uri\nheaders: Headers to send with the request. @optional\nbody: The request body. @optional\nsecret: Name of a configured secret to attach to the request. See HTTPDestinations. @optional",
+				Args: []string{
+					"method",
+					"url",
+					"headers",
+					"body",
+					"secret",
+				},
+			},
+			"ImageAnnotate": {
+				Name: "ImageAnnotate",
+				Doc:  "Draws boxes and text on an image, and shows it to the user. Use it to point out parts of an image.\n@mutates\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.\nannotations: The boxes and text to draw, in order. @min 1\nformat: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional\noutput: Path in the workspace to save the result to. @optional",
+				Args: []string{
+					"ws",
+					"source",
+					"annotations",
+					"format",
+					"output",
+				},
+			},
+			"ImageConvert": {
+				Name: "ImageConvert",
+				Doc:  "Converts an image to PNG or JPEG, and shows it to the user.\n@mutates\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.\nformat: Format of the result. @enum png, jpeg\noutput: Path in the workspace to save the result to. @optional",
+				Args: []string{
+					"ws",
+					"source",
+					"format",
+					"output",
+				},
+			},
+			"ImageCrop": {
+				Name: "ImageCrop",
+				Doc:  "Crops an image to a rectangle, and shows it to the user.\n@mutates\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.\nx: Left edge of the rectangle in pixels. @min 0\ny: Top edge of the rectangle in pixels. @min 0\nwidth: Width of the rectangle in pixels. @min 1\nheight: Height of the rectangle in pixels. @min 1\nformat: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional\noutput: Path in the workspace to save the result to. @optional",
+				Args: []string{
+					"ws",
+					"source",
+					"x",
+					"y",
+					"width",
+					"height",
+					"format",
+					"output",
+				},
+			},
+			"ImageInfo": {
+				Name: "ImageInfo",
+				Doc:  "Returns the size and format of an image.\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.",
+				Args: []string{
+					"ws",
+					"source",
+				},
+			},
+			"ImageResize": {
+				Name: "ImageResize",
+				Doc:  "Resizes an image, and shows it to the user. If only one of width and height is given, the aspect ratio is kept.\n@mutates\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.\nwidth: The new width in pixels. @min 1 @max 8192 @optional\nheight: The new height in pixels. @min 1 @max 8192 @optional\nformat: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional\noutput: Path in the workspace to save the result to. @optional",
+				Args: []string{
+					"ws",
+					"source",
+					"width",
+					"height",
+					"format",
+					"output",
+				},
+			},
+			"ImageRotate": {
+				Name: "ImageRotate",
+				Doc:  "Rotates an image clockwise, and shows it to the user. Angles that aren't multiples of 90 enlarge the image to fit, with transparent corners, or white ones in JPEGs.\n@mutates\nsource: Path of the image in the workspace, or the image data as base64 or a data URL.\ndegrees: The angle to rotate by, clockwise. @min -360 @max 360\nformat: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional\noutput: Path in the workspace to save the result to. @optional",
+				Args: []string{
+					"ws",
+					"source",
+					"degrees",
+					"format",
+					"output",
+				},
+			},
+			"ListMemories": {
+				Name: "ListMemories",
+				Doc:  "Lists remembered entries, newest first.\nscope: Which entries to list. @enum chat, global, all @default all\ntag: Only list entries with this tag. @optional",
+				Args: []string{
+					"ws",
+					"scope",
+					"tag",
+				},
+			},
+			"ListTerminals": {
+				Name: "ListTerminals",
+				Doc:  "Lists the terminal sessions of this chat.",
+				Args: []string{
+					"ws",
+				},
+			},
+			"LoadConfig": {
+				Name: "LoadConfig",
+				Doc:  "LoadConfig reads the configuration file at path, and makes it available to\nthe tools.",
+				Args: []string{
+					"path",
+				},
+			},
+			"LoadPlugins": {
+				Name: "LoadPlugins",
+				Doc:  "LoadPlugins reads the manifests of the configured WebAssembly modules, and\nadds a tool group for each of them to ToolGroups.",
+			},
 			"NewGroup": {
 				Name: "NewGroup",
 				Args: []string{
 					"name",
-					"fns",
+					"fns",
+				},
+			},
+			"OpenTerminal": {
+				Name: "OpenTerminal",
+				Doc:  "Opens an interactive terminal session in the workspace, for programs that need a TTY such as REPLs, `top` or interactive installers. Use WriteTerminal to type into it, and ReadTerminal to read its screen.\n@mutates\nname: A name for the session, used to refer to it later. @example shell\ncommand: The command to run in the terminal. @default bash\ncols: Width of the terminal in characters. @min 20 @max 400 @default 120\nrows: Height of the terminal in lines. @min 5 @max 200 @default 32",
+				Args: []string{
+					"ws",
+					"name",
+					"command",
+					"cols",
+					"rows",
+				},
+			},
+			"OpenedTerminal": {
+				Name: "OpenedTerminal",
+				Doc:  "OpenedTerminal returns an open terminal session of a chat.",
+				Args: []string{
+					"chatID",
+					"name",
+				},
+			},
+			"PendingQuestions": {
+				Name: "PendingQuestions",
+				Doc:  "PendingQuestions returns the unanswered questions of a chat, oldest first.",
+				Args: []string{
+					"chatID",
+				},
+			},
+			"ProcessLogs": {
+				Name: "ProcessLogs",
+				Doc:  "Returns the output of a background process, starting from an offset. Pass the next_offset of the previous call to only get new output.\nname: The name of the process.\noffset: The offset to start from. Negative offsets count from the end of the log. @default -4096\nlimit: Maximum number of bytes to return. @min 1 @max 65536 @default 16384",
+				Args: []string{
+					"ws",
+					"name",
+					"offset",
+					"limit",
+				},
+			},
+			"ProcessStatus": {
+				Name: "ProcessStatus",
+				Doc:  "Lists the background processes of this chat, or returns the status of one of them.\nname: The name of the process. Lists all processes if empty. @optional",
+				Args: []string{
+					"ws",
+					"name",
+				},
+			},
+			"ReadOutput": {
+				Name: "ReadOutput",
+				Doc:  "Reads an output that was too large to be returned whole, by the handle it was stored under. Returns a range of lines, or the lines matching a pattern.\nhandle: The handle of the output. @example out-3\noffset: Line to start from, starting at 1. @min 1 @default 1\nlimit: Maximum number of lines to return. @min 1 @max 2000 @default 200\npattern: Regular expression to search for. When set, only the matching lines are returned, from offset on. @optional\naround: Number of lines of context to return around each match. @min 0 @max 10 @default 0",
+				Args: []string{
+					"ws",
+					"handle",
+					"offset",
+					"limit",
+					"pattern",
+					"around",
+				},
+			},
+			"ReadTerminal": {
+				Name: "ReadTerminal",
+				Doc:  "Returns the screen of a terminal session, optionally waiting for new output first.\nname: The name of the session.\nwait: Maximum time to wait for new output to settle, in milliseconds. Returns the screen right away if 0. @min 0 @max 30000 @default 0",
+				Args: []string{
+					"ws",
+					"name",
+					"wait",
+				},
+			},
+			"Recall": {
+				Name: "Recall",
+				Doc:  "Searches remembered entries by keywords, and returns the best matches, most relevant first.\nquery: Keywords to search for.\ntags: Only return entries with all of these tags. @optional\nlimit: Maximum number of entries to return. @min 1 @max 100 @default 10",
+				Args: []string{
+					"ws",
+					"query",
+					"tags",
+					"limit",
+				},
+			},
+			"Redact": {
+				Name: "Redact",
+				Doc:  "Redact removes secrets from v, and returns the result along with the\nnumber of secrets removed. Strings are redacted as is; other values are\nredacted in their JSON encoding, and returned as json.RawMessage when\nanything was removed.",
+				Args: []string{
+					"v",
+				},
+			},
+			"Remember": {
+				Name: "Remember",
+				Doc:  "Remembers a fact, preference or piece of information for later conversations. Only remember what the user asks you to, or what will clearly be useful again.\ncontent: What to remember, written so that it makes sense on its own.\ntags: Short labels to group the entry by, such as \"infra\" or \"preferences\". @optional\nscope: Whether the entry is only recalled in this chat, or in all chats. @enum chat, global @default global",
+				Args: []string{
+					"ws",
+					"content",
+					"tags",
+					"scope",
+				},
+			},
+			"RunCode": {
+				Name: "RunCode",
+				Doc:  "Runs Python or Node.js code in the workspace, and returns its output along with the files it created or modified. Save plots and other results to files to show them to the user, for example with plt.savefig(\"plot.png\").\n@mutates\nlanguage: The language of the code. @enum python, node\ncode: The code to run.\ntimeout: Maximum time the code may run, in seconds. @min 1 @max 600 @default 60",
+				Args: []string{
+					"ws",
+					"language",
+					"code",
+					"timeout",
+				},
+			},
+			"SSHDownload": {
+				Name: "SSHDownload",
+				Doc:  "Downloads a file from a remote host into the workspace.\n@mutates\nhost: Name of the host.\nremote_path: Absolute path of the file on the host. It must be inside one of the host's allowed paths.\npath: Path to save the file to in the workspace. Defaults to the name of the remote file. @optional",
+				Args: []string{
+					"ws",
+					"host",
+					"remote_path",
+					"path",
+				},
+			},
+			"SSHHosts": {
+				Name: "SSHHosts",
+				Doc:  "Lists the hosts that the SSH tools can connect to, with the commands and directories allowed on each.",
+			},
+			"SSHRun": {
+				Name: "SSHRun",
+				Doc:  "Runs a command on a remote host over SSH and returns its output. Only the commands allowed for the host can be run, see SSHHosts.\nhost: Name of the host.\ncommand: The command to run, interpreted by the remote user's shell.\ntimeout: Maximum time the command may run, in seconds. @min 1 @max 3600 @default 60",
+				Args: []string{
+					"host",
+					"command",
+					"timeout",
+				},
+			},
+			"SSHUpload": {
+				Name: "SSHUpload",
+				Doc:  "Uploads a file from the workspace to a remote host. The remote directory is created if needed, and an existing file is overwritten.\nhost: Name of the host.\npath: Path of the file in the workspace.\nremote_path: Absolute path to write the file to on the host. It must be inside one of the host's allowed paths.",
+				Args: []string{
+					"ws",
+					"host",
+					"path",
+					"remote_path",
+				},
+			},
+			"Search": {
+				Name: "Search",
+				Doc:  "Searches the configured document folders for the given keywords, and returns the best matching snippets with their file path and line numbers.\nquery: Keywords to search for.\nlimit: Maximum number of results. @min 1 @max 50 @default 10\npath: Only return results from files whose path contains this text. @optional",
+				Args: []string{
+					"query",
+					"limit",
+					"path",
+				},
+			},
+			"ShareFile": {
+				Name: "ShareFile",
+				Doc:  "Shares a file of the workspace with the user, who gets a link to download it. Use it to hand over the files you made, such as reports or archives.\npath: Path of the file in the workspace.",
+				Args: []string{
+					"ws",
+					"path",
+				},
+			},
+			"Shell": {
+				Name: "Shell",
+				Doc:  "Executes the given bash command and returns the output of the command.\n@mutates\ncommand: The bash command to execute.",
+				Args: []string{
+					"ws",
+					"command",
+				},
+			},
+			"StartIndexing": {
+				Name: "StartIndexing",
+				Doc:  "StartIndexing loads the search index, brings it up to date with the\nconfigured folders, and keeps it updated as files change. It does nothing if\nno folders are configured.",
+			},
+			"StartProcess": {
+				Name: "StartProcess",
+				Doc:  "Starts a command in the background in the workspace, such as a dev server or a file watcher, and returns immediately. Use ProcessLogs to read its output.\n@mutates\nname: A name for the process, used to refer to it later. @example dev-server\ncommand: The bash command to run.",
+				Args: []string{
+					"ws",
+					"name",
+					"command",
+				},
+			},
+			"StopProcess": {
+				Name: "StopProcess",
+				Doc:  "Stops a background process, killing it if it doesn't exit within a few seconds.\nname: The name of the process.",
+				Args: []string{
+					"ws",
+					"name",
+				},
+			},
+			"StopProcesses": {
+				Name: "StopProcesses",
+				Doc:  "StopProcesses stops the background processes of all chats, and waits for\nthem to exit.",
+			},
+			"Test": {
+				Name: "Test",
+				Doc:  "Runs the tests of a Go, Python or JavaScript project in the workspace, and returns a summary with the output and location of each failure.\n@mutates\nrunner: The test runner. auto picks go if there is a go.mod, npm if there is a package.json, and pytest otherwise. npm understands Jest, Vitest and node --test. @enum auto, go, pytest, npm @default auto\npath: The directory of the project, relative to the workspace. @default .\nfilter: Only run the tests whose name matches: a regular expression for go and npm, or a -k expression for pytest. @optional\nall: List the passed and skipped tests too, not only the failures. @optional\ntimeout: Maximum time the tests may run, in seconds. @min 1 @max 3600 @default 300",
+				Args: []string{
+					"ws",
+					"runner",
+					"path",
+					"filter",
+					"all",
+					"timeout",
+				},
+			},
+			"Use": {
+				Name: "Use",
+				Doc:  "Use registers hooks that wrap the tools of every group. They run in the\norder they are registered, before the hooks of the group. Hooks must be\nregistered before the server starts handling calls.",
+				Args: []string{
+					"h",
+				},
+			},
+			"WatchQuestions": {
+				Name: "WatchQuestions",
+				Doc:  "WatchQuestions returns a channel that receives a value when the questions of\na chat change, and a function to stop watching.",
+				Args: []string{
+					"chatID",
+				},
+			},
+			"WriteTerminal": {
+				Name: "WriteTerminal",
+				Doc:  "Types into a terminal session, then waits for its output to settle and returns the screen.\n@mutates\nname: The name of the session.\nkeys: The text to type. Special keys are written in angle brackets: <Enter>, <Tab>, <Esc>, <Backspace>, <Space>, <Up>, <Down>, <Left>, <Right>, <Home>, <End>, <PageUp>, <PageDown>, <Delete>, and <C-x> for Ctrl+x. @example ls -la<Enter>\nwait: Maximum time to wait for the output to settle, in milliseconds. @min 0 @max 30000 @default 2000",
+				Args: []string{
+					"ws",
+					"name",
+					"keys",
+					"wait",
+				},
+			},
+			"allowedRemotePath": {
+				Name: "allowedRemotePath",
+				Doc:  "allowedRemotePath cleans the remote path p, and checks that it is inside\none of the allowed paths of host.",
+				Args: []string{
+					"host",
+					"p",
+				},
+			},
+			"attr": {
+				Name: "attr",
+				Args: []string{
+					"n",
+					"name",
+				},
+			},
+			"cellColumn": {
+				Name: "cellColumn",
+				Doc:  "cellColumn returns the zero-based column of a cell reference such as \"C7\".",
+				Args: []string{
+					"ref",
+				},
+			},
+			"changedFiles": {
+				Name: "changedFiles",
+				Doc:  "changedFiles returns the files in dir that were created or modified since\nthe before snapshot was taken. Images are returned as data URLs, and text\nfiles as text.",
+				Args: []string{
+					"dir",
+					"before",
+				},
+			},
+			"chartFace": {
+				Name: "chartFace",
+				Doc:  "chartFace returns the Go font at size. Charts only use a few fixed sizes,\nso should it fail anyway, the text is drawn with a basic font rather than\nfailing the chart.",
+				Args: []string{
+					"size",
+				},
+			},
+			"checkGitArg": {
+				Name: "checkGitArg",
+				Doc:  "checkGitArg refuses revisions and branch names that git would read as\noptions, such as --output=<file>, since they are passed before \"--\".",
+				Args: []string{
+					"name",
+					"value",
+				},
+			},
+			"circle": {
+				Name: "circle",
+				Args: []string{
+					"center",
+					"r",
+				},
+			},
+			"collapseLines": {
+				Name: "collapseLines",
+				Doc:  "collapseLines collapses the whitespace of each line of s.",
+				Args: []string{
+					"s",
+				},
+			},
+			"collapseSpace": {
+				Name: "collapseSpace",
+				Doc:  "collapseSpace replaces runs of whitespace with a single space.",
+				Args: []string{
+					"s",
+				},
+			},
+			"countLines": {
+				Name: "countLines",
+				Args: []string{
+					"s",
+				},
+			},
+			"detectCheckers": {
+				Name: "detectCheckers",
+				Doc:  "detectCheckers picks the checkers of the project in dir.",
+				Args: []string{
+					"dir",
+				},
+			},
+			"detectTestRunner": {
+				Name: "detectTestRunner",
+				Doc:  "detectTestRunner picks the test runner of the project in dir.",
+				Args: []string{
+					"dir",
+				},
+			},
+			"drawBox": {
+				Name: "drawBox",
+				Args: []string{
+					"dst",
+					"r",
+					"width",
+					"c",
+				},
+			},
+			"drawLabel": {
+				Name: "drawLabel",
+				Doc:  "drawLabel draws text in white on a background of color c, just above pt, or\njust below it if there is no room above.",
+				Args: []string{
+					"dst",
+					"pt",
+					"text",
+					"size",
+					"c",
+				},
+			},
+			"drawText": {
+				Name: "drawText",
+				Doc:  "drawText draws text with its top left corner at pt.",
+				Args: []string{
+					"dst",
+					"pt",
+					"text",
+					"size",
+					"c",
+				},
+			},
+			"eslintDiagnostics": {
+				Name: "eslintDiagnostics",
+				Args: []string{
+					"dir",
+				},
+			},
+			"extractDOCX": {
+				Name: "extractDOCX",
+				Args: []string{
+					"p",
+				},
+			},
+			"extractEPUB": {
+				Name: "extractEPUB",
+				Args: []string{
+					"p",
+				},
+			},
+			"extractHTMLFile": {
+				Name: "extractHTMLFile",
+				Args: []string{
+					"p",
+				},
+			},
+			"extractPDF": {
+				Name: "extractPDF",
+				Args: []string{
+					"p",
+				},
+			},
+			"extractXLSX": {
+				Name: "extractXLSX",
+				Args: []string{
+					"p",
+				},
+			},
+			"fieldName": {
+				Name: "fieldName",
+				Doc:  "fieldName returns the argument name of a struct field, following the same\nrules as llum-tools.",
+				Args: []string{
+					"f",
+				},
+				Results: []string{
+					"name",
+					"omitempty",
+				},
+			},
+			"flatten": {
+				Name: "flatten",
+				Doc:  "flatten draws img over a white background, since JPEGs have no\ntransparency.",
+				Args: []string{
+					"img",
+				},
+			},
+			"fontFace": {
+				Name: "fontFace",
+				Doc:  "fontFace returns the Go font at size.",
+				Args: []string{
+					"size",
+				},
+			},
+			"formatTick": {
+				Name: "formatTick",
+				Doc:  "formatTick formats a tick value with as many decimals as the step between\nticks needs.",
+				Args: []string{
+					"v",
+					"ticks",
+				},
+			},
+			"git": {
+				Name: "git",
+				Doc:  "git runs a git command in a repository inside the workspace. Repository\ndiscovery is stopped at the workspace, so that a workspace which isn't a\nrepository is never mistaken for a repository it is contained in.",
+				Args: []string{
+					"ws",
+					"repo",
+					"args",
+				},
+			},
+			"gitStatusCode": {
+				Name: "gitStatusCode",
+				Args: []string{
+					"c",
+				},
+			},
+			"goDiagnostics": {
+				Name: "goDiagnostics",
+				Args: []string{
+					"dir",
+				},
+			},
+			"hasFailedSubtest": {
+				Name: "hasFailedSubtest",
+				Args: []string{
+					"results",
+					"parent",
+				},
+			},
+			"hasFailedTest": {
+				Name: "hasFailedTest",
+				Args: []string{
+					"results",
+					"pkg",
+				},
+			},
+			"hasTags": {
+				Name: "hasTags",
+				Args: []string{
+					"entry",
+					"tags",
+				},
+			},
+			"htmlToMarkdown": {
+				Name: "htmlToMarkdown",
+				Doc:  "htmlToMarkdown converts an HTML document to Markdown, and returns its title.",
+				Args: []string{
+					"r",
+				},
+			},
+			"imageData": {
+				Name: "imageData",
+				Doc:  "imageData returns the bytes of an image given as a workspace path, a data\nURL or base64.",
+				Args: []string{
+					"ws",
+					"src",
+				},
+			},
+			"imageDecodeConfig": {
+				Name: "imageDecodeConfig",
+				Args: []string{
+					"data",
+				},
+			},
+			"init": {
+				Name: "init",
+			},
+			"inlineMarkdown": {
+				Name: "inlineMarkdown",
+				Doc:  "inlineMarkdown returns the Markdown of n as inline content.",
+				Args: []string{
+					"n",
+				},
+			},
+			"isCtrlKey": {
+				Name: "isCtrlKey",
+				Args: []string{
+					"c",
+				},
+			},
+			"isPDF": {
+				Name: "isPDF",
+				Args: []string{
+					"path",
+				},
+			},
+			"isText": {
+				Name: "isText",
+				Args: []string{
+					"mediaType",
+					"data",
+				},
+			},
+			"jsFailureLocation": {
+				Name: "jsFailureLocation",
+				Doc:  "jsFailureLocation returns the first frame of a JavaScript stack trace that\nisn't in Node.js itself or in a dependency.",
+				Args: []string{
+					"stack",
+				},
+			},
+			"labelStep": {
+				Name: "labelStep",
+				Doc:  "labelStep returns how many categories to skip between labels so that they\ndon't overlap.",
+				Args: []string{
+					"n",
+					"slot",
+					"labels",
+				},
+			},
+			"limitedCommand": {
+				Name: "limitedCommand",
+				Doc:  "limitedCommand returns a command that runs name under the CPU time, file\nsize and, if limitMemory is set, address space limits of c. The command\nruns in its own process group, which is killed when ctx is done.",
+				Args: []string{
+					"ctx",
+					"c",
+					"limitMemory",
+					"name",
+					"args",
+				},
+			},
+			"listMarkdown": {
+				Name: "listMarkdown",
+				Doc:  "listMarkdown returns the Markdown of an ul or ol element, with nested lists\nindented by depth.",
+				Args: []string{
+					"n",
+					"depth",
+				},
+			},
+			"literalSecrets": {
+				Name: "literalSecrets",
+				Doc:  "literalSecrets returns the configured secret values, longest first, so\nthat a secret containing another is replaced whole.",
+			},
+			"loadImage": {
+				Name: "loadImage",
+				Args: []string{
+					"ws",
+					"src",
+				},
+			},
+			"markdownHeading": {
+				Name: "markdownHeading",
+				Doc:  "markdownHeading returns the level and text of a Markdown heading line, or 0\nif the line isn't a heading.",
+				Args: []string{
+					"line",
+				},
+			},
+			"markdownSection": {
+				Name: "markdownSection",
+				Doc:  "markdownSection returns the first section of content whose heading contains\ntitle, up to the next heading of the same or a higher level.",
+				Args: []string{
+					"content",
+					"title",
+				},
+			},
+			"markdownTable": {
+				Name: "markdownTable",
+				Doc:  "markdownTable formats rows as a Markdown table, using the first row as the\nheader.",
+				Args: []string{
+					"rows",
+				},
+			},
+			"markdownToText": {
+				Name: "markdownToText",
+				Doc:  "markdownToText removes the Markdown syntax from the output of the\nextractors.",
+				Args: []string{
+					"content",
+				},
+			},
+			"matchCommand": {
+				Name: "matchCommand",
+				Doc:  "matchCommand reports whether command matches one of patterns, in which *\nmatches any text.",
+				Args: []string{
+					"patterns",
+					"command",
+				},
+			},
+			"matchHost": {
+				Name: "matchHost",
+				Doc:  "matchHost reports whether the host of u matches one of patterns. Patterns\nwith a port only match URLs with that port, which for URLs without one is\nthe default port of their scheme.",
+				Args: []string{
+					"patterns",
+					"u",
+				},
+			},
+			"newFunction": {
+				Name: "newFunction",
+				Doc:  "newFunction builds the schema of fn. The arguments are taken from the schema\ngenerated by llum-tools, which already skips injected parameters; their\ndescriptions are then parsed for annotations, such as:\n\n\t// format: Output format. @enum json, markdown @default markdown\n\t// limit: Maximum number of results. @min 1 @max 100 @optional\n\t// url: Address of the page. @format uri @example https://example.com\n\nA line with only @mutates, before the arguments, marks a tool that may\nchange the files of the workspace.",
+				Args: []string{
+					"fn",
+					"generated",
+				},
+			},
+			"newMemoryID": {
+				Name: "newMemoryID",
+			},
+			"newPNGCanvas": {
+				Name: "newPNGCanvas",
+				Args: []string{
+					"width",
+					"height",
+				},
+			},
+			"newSVGCanvas": {
+				Name: "newSVGCanvas",
+				Args: []string{
+					"width",
+					"height",
+				},
+			},
+			"newSearchIndex": {
+				Name: "newSearchIndex",
+			},
+			"niceNumber": {
+				Name: "niceNumber",
+				Doc:  "niceNumber rounds x to 1, 2 or 5 times a power of ten.",
+				Args: []string{
+					"x",
+				},
+			},
+			"niceTicks": {
+				Name: "niceTicks",
+				Doc:  "niceTicks returns about n round tick values covering lo to hi, and the range\nthey span.",
+				Args: []string{
+					"lo",
+					"hi",
+					"n",
+				},
+			},
+			"normalizeTags": {
+				Name: "normalizeTags",
+				Args: []string{
+					"tags",
+				},
+			},
+			"npmTestCommand": {
+				Name: "npmTestCommand",
+				Doc:  "npmTestCommand returns the command running `npm test` in dir, with the\narguments that make its runner write its results to out.",
+				Args: []string{
+					"dir",
+					"filter",
+					"out",
+				},
+				Results: []string{
+					"name",
+					"args",
+					"env",
+					"err",
+				},
+			},
+			"objectPath": {
+				Name: "objectPath",
+				Args: []string{
+					"dir",
+					"hash",
+				},
+			},
+			"onlyPackageHeaders": {
+				Name: "onlyPackageHeaders",
+				Doc:  "onlyPackageHeaders reports whether the output of go vet holds nothing but\n\"# package\" lines.",
+				Args: []string{
+					"s",
+				},
+			},
+			"openDatabase": {
+				Name: "openDatabase",
+				Doc:  "openDatabase returns the pool of the named connection, opening it on first use.",
+				Args: []string{
+					"name",
+				},
+			},
+			"outputExcerpt": {
+				Name: "outputExcerpt",
+				Doc:  "outputExcerpt returns the head and tail of s, cut at line ends where\npossible, with a note about the rest. Strings too short to leave anything\nout are returned whole.",
+				Args: []string{
+					"s",
+					"note",
+				},
+			},
+			"outputFormat": {
+				Name: "outputFormat",
+				Args: []string{
+					"format",
+					"srcFormat",
+				},
+			},
+			"parseColor": {
+				Name: "parseColor",
+				Doc:  "parseColor parses a color name, or a hex color as #rgb, #rrggbb or\n#rrggbbaa. An empty string is red.",
+				Args: []string{
+					"s",
+				},
+			},
+			"parseGitBlame": {
+				Name: "parseGitBlame",
+				Args: []string{
+					"out",
+				},
+			},
+			"parseGitDiff": {
+				Name: "parseGitDiff",
+				Args: []string{
+					"out",
+				},
+			},
+			"parseGitHunkHeader": {
+				Name: "parseGitHunkHeader",
+				Doc:  "parseGitHunkHeader parses a line such as \"@@ -1,5 +1,6 @@ func main() {\".",
+				Args: []string{
+					"line",
+				},
+			},
+			"parseGitLog": {
+				Name: "parseGitLog",
+				Args: []string{
+					"out",
+				},
+			},
+			"parseGitRename": {
+				Name: "parseGitRename",
+				Doc:  "parseGitRename splits a path of the --numstat output, which is written as\n\"old => new\" or \"dir/{old => new}/file\" for renamed files, into the old and\nnew paths. The old path is empty for files that weren't renamed.",
+				Args: []string{
+					"path",
+				},
+				Results: []string{
+					"oldPath",
+					"newPath",
+				},
+			},
+			"parseGitStatus": {
+				Name: "parseGitStatus",
+				Args: []string{
+					"out",
+				},
+			},
+			"parseGoTestJSON": {
+				Name: "parseGoTestJSON",
+				Doc:  "parseGoTestJSON reads the output of `go test -json`. Before Go 1.24, build\nerrors are only written to stderr, under a \"# package\" line.",
+				Args: []string{
+					"r",
+					"stderr",
+				},
+			},
+			"parseJUnitXML": {
+				Name: "parseJUnitXML",
+				Args: []string{
+					"data",
+				},
+			},
+			"parseJestJSON": {
+				Name: "parseJestJSON",
+				Args: []string{
+					"data",
+				},
+			},
+			"parsePageRanges": {
+				Name: "parsePageRanges",
+				Doc:  "parsePageRanges parses a list of page numbers and ranges, such as \"1-3,7\",\ninto page numbers between 1 and n.",
+				Args: []string{
+					"s",
+					"n",
+				},
+			},
+			"parseTerminalKeys": {
+				Name: "parseTerminalKeys",
+				Doc:  "parseTerminalKeys replaces the special keys in keys with what a terminal\nsends for them. Unknown names in angle brackets are left as they are.",
+				Args: []string{
+					"keys",
+				},
+			},
+			"pathBase": {
+				Name: "pathBase",
+				Args: []string{
+					"p",
+				},
+			},
+			"pathDir": {
+				Name: "pathDir",
+				Doc:  "pathDir and pathBase are used by the tools whose path parameter shadows the\npath package.",
+				Args: []string{
+					"p",
+				},
+			},
+			"projectCommand": {
+				Name: "projectCommand",
+				Doc:  "projectCommand returns a command that runs name in the project dir, as in\nCI and without colors. It runs in its own process group, which is killed\nwhen ctx is done.",
+				Args: []string{
+					"ctx",
+					"dir",
+					"name",
+					"args",
+				},
+			},
+			"ptr": {
+				Name: "ptr",
+				Args: []string{
+					"v",
+				},
+			},
+			"readDocument": {
+				Name: "readDocument",
+				Doc:  "readDocument returns the text of the file at path, one string per page.",
+				Args: []string{
+					"path",
+				},
+			},
+			"readPDF": {
+				Name: "readPDF",
+				Doc:  "readPDF returns the title of a PDF and the text of each of its pages. The\npdf package panics on some malformed files instead of failing, which is\nturned into an error: documents come from anywhere, and a bad one must not\nbring down the server.",
+				Args: []string{
+					"path",
+				},
+				Results: []string{
+					"title",
+					"pages",
+					"err",
+				},
+			},
+			"readZipXML": {
+				Name: "readZipXML",
+				Args: []string{
+					"z",
+					"name",
+					"v",
+				},
+			},
+			"rect": {
+				Name: "rect",
+				Args: []string{
+					"x",
+					"y",
+					"w",
+					"h",
+				},
+			},
+			"redactString": {
+				Name: "redactString",
+				Doc:  "redactString replaces the secrets in s with a placeholder naming their kind.",
+				Args: []string{
+					"s",
+				},
+			},
+			"relativeTestPath": {
+				Name: "relativeTestPath",
+				Doc:  "relativeTestPath makes absolute paths relative to the project dir.",
+				Args: []string{
+					"dir",
+					"path",
+				},
+			},
+			"replaceAllSubmatchFunc": {
+				Name: "replaceAllSubmatchFunc",
+				Doc:  "replaceAllSubmatchFunc is regexp.ReplaceAllStringFunc, with s and the\nindexes of the submatches in it passed to repl.",
+				Args: []string{
+					"re",
+					"s",
+					"repl",
+				},
+			},
+			"restoreObject": {
+				Name: "restoreObject",
+				Doc:  "restoreObject writes the contents of a file entry to path.",
+				Args: []string{
+					"dir",
+					"entry",
+					"path",
+				},
+			},
+			"returnsRows": {
+				Name: "returnsRows",
+				Doc:  "returnsRows guesses whether a statement returns rows, or should be executed\nto get the number of rows it affected instead.",
+				Args: []string{
+					"query",
+				},
+			},
+			"rotateImage": {
+				Name: "rotateImage",
+				Args: []string{
+					"img",
+					"degrees",
+					"opaque",
+				},
+			},
+			"runChecker": {
+				Name: "runChecker",
+				Doc:  "runChecker runs a checker in dir, and returns its stdout and stderr. A\nnon-zero exit isn't an error, as checkers exit with 1 when they find\nproblems.",
+				Args: []string{
+					"dir",
+					"name",
+					"args",
+				},
+				Results: []string{
+					"stdout",
+					"stderr",
+					"err",
+				},
+			},
+			"runQuery": {
+				Name: "runQuery",
+				Args: []string{
+					"ctx",
+					"tx",
+					"query",
+					"maxRows",
+				},
+			},
+			"runSession": {
+				Name: "runSession",
+				Doc:  "runSession runs command in session, and kills it if it doesn't exit within\ntimeout.",
+				Args: []string{
+					"session",
+					"command",
+					"timeout",
+				},
+			},
+			"saveImage": {
+				Name: "saveImage",
+				Doc:  "saveImage encodes img, writes it to output in the workspace if it is set,\nand returns it as a data URL.",
+				Args: []string{
+					"ws",
+					"img",
+					"format",
+					"output",
+				},
+			},
+			"scanColumns": {
+				Name: "scanColumns",
+				Args: []string{
+					"rows",
+					"err",
+				},
+			},
+			"setProcessGroup": {
+				Name: "setProcessGroup",
+				Doc:  "setProcessGroup makes cmd start in its own process group, so that its\nchildren can be stopped along with it.",
+				Args: []string{
+					"cmd",
+				},
+			},
+			"shellQuote": {
+				Name: "shellQuote",
+				Doc:  "shellQuote quotes s for a POSIX shell.",
+				Args: []string{
+					"s",
+				},
+			},
+			"skipCheckpointDir": {
+				Name: "skipCheckpointDir",
+				Doc:  "skipCheckpointDir reports whether a directory is left out of checkpoints.\nThese hold dependencies and caches, which are large and can be recreated, so\nRestore leaves them as they are.",
+				Args: []string{
+					"name",
 				},
 			},
-			"Shell": {
-				Name: "Shell",
-				Doc:  "Executes the given bash command and returns the output of the command.\ncommand: The bash command to execute.",
+			"skipDir": {
+				Name: "skipDir",
 				Args: []string{
-					"command",
+					"name",
 				},
 			},
-			"init": {
-				Name: "init",
+			"snapshotFiles": {
+				Name: "snapshotFiles",
+				Doc:  "snapshotFiles returns the modification times of the files in dir.",
+				Args: []string{
+					"dir",
+				},
+			},
+			"sourceContext": {
+				Name: "sourceContext",
+				Doc:  "sourceContext returns the lines of file around line, numbered. Files are\nread once, through files.",
+				Args: []string{
+					"ws",
+					"dir",
+					"file",
+					"line",
+					"around",
+					"files",
+				},
+			},
+			"sshSession": {
+				Name: "sshSession",
+				Doc:  "sshSession opens a session on the named host, reusing its connection if it\nis still alive.",
+				Args: []string{
+					"name",
+				},
+			},
+			"storeObject": {
+				Name: "storeObject",
+				Doc:  "storeObject copies the file at path into the objects of a checkpoint dir,\nunless it's already there, and returns its hash.",
+				Args: []string{
+					"dir",
+					"path",
+				},
+			},
+			"storeOutput": {
+				Name: "storeOutput",
+				Doc:  "storeOutput stores s for chatID, and returns its excerpt.",
+				Args: []string{
+					"chatID",
+					"s",
+				},
+			},
+			"svelteCheckDiagnostics": {
+				Name: "svelteCheckDiagnostics",
+				Args: []string{
+					"dir",
+				},
+			},
+			"svgColor": {
+				Name: "svgColor",
+				Args: []string{
+					"c",
+				},
+			},
+			"svgPoints": {
+				Name: "svgPoints",
+				Args: []string{
+					"points",
+				},
+			},
+			"tableMarkdown": {
+				Name: "tableMarkdown",
+				Doc:  "tableMarkdown returns the Markdown of a table element. The first row is used\nas the header.",
+				Args: []string{
+					"n",
+				},
+			},
+			"terminateProcess": {
+				Name: "terminateProcess",
+				Doc:  "terminateProcess sends SIGTERM, or SIGKILL if force is set, to the process\ngroup of cmd.",
+				Args: []string{
+					"cmd",
+					"force",
+				},
+			},
+			"textContent": {
+				Name: "textContent",
+				Args: []string{
+					"n",
+				},
+			},
+			"textSize": {
+				Name: "textSize",
+				Doc:  "textSize returns the size of a JSON encoded result, without its large\nbase64 strings.",
+				Args: []string{
+					"data",
+				},
+			},
+			"textWidth": {
+				Name: "textWidth",
+				Args: []string{
+					"s",
+					"size",
+				},
+			},
+			"tokenize": {
+				Name: "tokenize",
+				Doc:  "tokenize splits text into lowercase words. Identifiers written in camelCase\nor snake_case are also split into their parts.",
+				Args: []string{
+					"text",
+				},
+			},
+			"transferError": {
+				Name: "transferError",
+				Args: []string{
+					"err",
+					"stderr",
+				},
+			},
+			"transferTimeout": {
+				Name: "transferTimeout",
+				Doc:  "transferTimeout allows a minute, plus ten seconds per megabyte to transfer.",
+				Args: []string{
+					"size",
+				},
+			},
+			"truncateHead": {
+				Name: "truncateHead",
+				Doc:  "truncateHead keeps the last max bytes of s.",
+				Args: []string{
+					"s",
+					"max",
+				},
+			},
+			"truncateResult": {
+				Name: "truncateResult",
+				Args: []string{
+					"chatID",
+					"out",
+				},
+			},
+			"truncateString": {
+				Name: "truncateString",
+				Args: []string{
+					"s",
+					"n",
+				},
+			},
+			"tscDiagnostics": {
+				Name: "tscDiagnostics",
+				Args: []string{
+					"dir",
+				},
+			},
+			"typeDefinition": {
+				Name: "typeDefinition",
+				Args: []string{
+					"t",
+				},
+			},
+			"uniqueTerms": {
+				Name: "uniqueTerms",
+				Args: []string{
+					"terms",
+				},
+			},
+			"walkWorkspace": {
+				Name: "walkWorkspace",
+				Doc:  "walkWorkspace calls fn for each regular file in dir, skipping hidden\ndirectories and dependency folders.",
+				Args: []string{
+					"dir",
+					"fn",
+				},
+			},
+			"watchIndex": {
+				Name: "watchIndex",
+				Doc:  "watchIndex reindexes changed files, batching changes that happen together.",
+				Args: []string{
+					"watcher",
+					"idx",
+					"c",
+				},
+			},
+			"watchTree": {
+				Name: "watchTree",
+				Args: []string{
+					"watcher",
+					"root",
+				},
+			},
+			"xmlAttr": {
+				Name: "xmlAttr",
+				Args: []string{
+					"e",
+					"name",
+				},
+			},
+			"xmlText": {
+				Name: "xmlText",
+				Doc:  "xmlText returns the text of the <t> elements in an inner XML string, as used\nby shared and inline strings.",
+				Args: []string{
+					"inner",
+				},
 			},
 		},
 		Structs: map[string]codoc.Struct{
+			"Answer": {
+				Name: "Answer",
+				Doc:  "Answer is the answer of the user to a question, as sent by the UI.",
+				Fields: map[string]codoc.Field{
+					"Cancelled": {
+						Doc: "Cancelled is set when the user dismissed the question.",
+					},
+				},
+			},
+			"Call": {
+				Name: "Call",
+				Doc:  "Call is a tool call, as seen by hooks.",
+				Fields: map[string]codoc.Field{
+					"Args": {
+						Doc: "Args are the arguments of the call, as sent by the model. Hooks may\nchange them before calling the next invoker; defaults are filled in and\nconstraints checked after all hooks have run.",
+					},
+					"ChatID": {
+						Doc: "ChatID is the chat the call was made in.",
+					},
+					"Context": {
+						Doc: "Context is canceled when the client that made the call goes away.",
+					},
+					"Group": {
+						Doc: "Group is the name of the group of the tool.",
+					},
+					"ID": {
+						Doc: "ID is the ID of the tool call, as sent by the client.",
+					},
+					"Name": {
+						Doc: "Name is the name of the tool.",
+					},
+					"Redactions": {
+						Doc: "Redactions is the number of secrets removed from the result by\nRedactSecrets.",
+					},
+					"Workspace": {
+						Doc: "Workspace is the workspace of the chat.",
+					},
+				},
+			},
+			"ChartSeries": {
+				Name: "ChartSeries",
+				Fields: map[string]codoc.Field{
+					"Color": {
+						Doc: "A color name such as \"red\", or a hex color such as \"#ff0000\". Defaults to a color from the palette.",
+					},
+					"Name": {
+						Doc: "Name of the series, shown in the legend.",
+					},
+					"Values": {
+						Doc: "The values of the series. In pie charts, the sizes of the slices.",
+					},
+					"X": {
+						Doc: "X coordinates of the values in line and scatter charts. Defaults to the labels, or to 1, 2, 3...",
+					},
+				},
+			},
+			"CheckpointInfo": {
+				Name: "CheckpointInfo",
+				Doc:  "CheckpointInfo describes a snapshot of a workspace, taken right before a\ntool call.",
+				Fields: map[string]codoc.Field{
+					"ID": {
+						Doc: "ID is the ID of the tool call.",
+					},
+				},
+			},
+			"CodeConfig": {
+				Name: "CodeConfig",
+				Doc:  "CodeConfig sets the interpreters and resource limits of RunCode.",
+				Fields: map[string]codoc.Field{
+					"CPUSeconds": {
+						Doc: "CPUSeconds is the maximum CPU time of a run. Defaults to 120.",
+					},
+					"MaxFileSizeMB": {
+						Doc: "MaxFileSizeMB is the maximum size of a file written by a run, in\nmegabytes. Defaults to 100.",
+					},
+					"MemoryMB": {
+						Doc: "MemoryMB is the maximum memory of a run, in megabytes. Defaults to 1024.",
+					},
+					"Node": {
+						Doc: "Node is the Node.js executable. Defaults to node.",
+					},
+					"Python": {
+						Doc: "Python is the Python interpreter. Defaults to python3.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"cpuSeconds": {
+						Name: "cpuSeconds",
+					},
+					"maxFileSizeMB": {
+						Name: "maxFileSizeMB",
+					},
+					"memoryMB": {
+						Name: "memoryMB",
+					},
+					"node": {
+						Name: "node",
+					},
+					"python": {
+						Name: "python",
+					},
+				},
+			},
+			"CodeResult": {
+				Name: "CodeResult",
+			},
+			"Config": {
+				Name: "Config",
+				Doc:  "Config holds the settings of the tool groups that need them, and is read\nfrom the JSON file given to the server with -config.",
+				Fields: map[string]codoc.Field{
+					"Code": {
+						Doc: "Code sets the interpreters and resource limits of the Code tools.",
+					},
+					"Databases": {
+						Doc: "Databases are the connections available to the Database tools, by name.",
+					},
+					"HTTP": {
+						Doc: "HTTP restricts the destinations of the HTTP tools.",
+					},
+					"Memory": {
+						Doc: "Memory sets where the Memory tools keep their entries.",
+					},
+					"Plugins": {
+						Doc: "Plugins are the WebAssembly modules loaded as tools.",
+					},
+					"Redaction": {
+						Doc: "Redaction sets how secrets are removed from the results of tools.",
+					},
+					"SSH": {
+						Doc: "SSH are the remote hosts available to the SSH tools, by name.",
+					},
+					"Search": {
+						Doc: "Search sets the folders indexed for the Search tool.",
+					},
+				},
+			},
 			"ContentTypeResponse": {
 				Name: "ContentTypeResponse",
+				Fields: map[string]codoc.Field{
+					"Name": {
+						Doc: "Name is the name of the file the content comes from, if any.",
+					},
+				},
+			},
+			"DatabaseColumn": {
+				Name: "DatabaseColumn",
+			},
+			"DatabaseConfig": {
+				Name: "DatabaseConfig",
+				Doc:  "DatabaseConfig describes a database connection.",
+				Fields: map[string]codoc.Field{
+					"DSN": {
+						Doc: "DSN is the data source name passed to the driver. For sqlite, this is\nthe path of the database file.",
+					},
+					"Description": {
+						Doc: "Description tells the model what the database contains.",
+					},
+					"Driver": {
+						Doc: "Driver is one of sqlite, postgres or mysql.",
+					},
+					"MaxRows": {
+						Doc: "MaxRows is the maximum number of rows returned by a query. Defaults to 500.",
+					},
+					"Timeout": {
+						Doc: "Timeout is the maximum time a query may run. Defaults to 30s.",
+					},
+					"Writable": {
+						Doc: "Writable allows statements that modify the database. Connections are\nread-only by default.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"maxRows": {
+						Name: "maxRows",
+					},
+					"timeout": {
+						Name: "timeout",
+					},
+				},
+			},
+			"DatabaseConnection": {
+				Name: "DatabaseConnection",
+			},
+			"DatabaseQueryResult": {
+				Name: "DatabaseQueryResult",
+				Methods: map[string]codoc.Function{
+					"markdown": {
+						Name: "markdown",
+					},
+				},
+			},
+			"DatabaseTable": {
+				Name: "DatabaseTable",
+			},
+			"Definition": {
+				Name: "Definition",
+				Doc:  "Definition describes a JSON Schema. It mirrors schema.Definition, with the\nadditional keywords that can be set through doc comment annotations.",
+				Fields: map[string]codoc.Field{
+					"optional": {
+						Doc: "optional is set when the definition is annotated with @optional or\n@default, and controls whether it is listed in its parent's Required.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"annotate": {
+						Name: "annotate",
+						Doc:  "annotate sets the description of d, and applies any annotations found in it.",
+						Args: []string{
+							"desc",
+						},
+					},
+					"apply": {
+						Name: "apply",
+						Args: []string{
+							"key",
+							"value",
+						},
+					},
+					"applyDefaults": {
+						Name: "applyDefaults",
+						Doc:  "applyDefaults fills in the defaults of properties omitted from nested objects.",
+						Args: []string{
+							"val",
+						},
+					},
+					"parseValue": {
+						Name: "parseValue",
+						Doc:  "parseValue parses an annotation value. Strings may be given bare or as a\nquoted JSON string, everything else must be valid JSON.",
+						Args: []string{
+							"s",
+						},
+					},
+					"validate": {
+						Name: "validate",
+						Args: []string{
+							"path",
+							"val",
+						},
+					},
+					"validateNumber": {
+						Name: "validateNumber",
+						Args: []string{
+							"path",
+							"n",
+						},
+					},
+				},
+			},
+			"Diagnostic": {
+				Name: "Diagnostic",
+				Fields: map[string]codoc.Field{
+					"Context": {
+						Doc: "Context holds the source lines around the diagnostic, with the line\nnumbers, and the diagnostic's line marked with '>'.",
+					},
+					"Severity": {
+						Comment: "\"error\", \"warning\" or \"info\"",
+					},
+					"Source": {
+						Doc: "Source is the checker that reported the diagnostic, and its rule or\nerror code if it has one.",
+					},
+				},
+			},
+			"DiagnosticsReport": {
+				Name: "DiagnosticsReport",
+				Fields: map[string]codoc.Field{
+					"Checkers": {
+						Doc: "Checkers are the checkers that were run.",
+					},
+					"Output": {
+						Doc: "Output is the end of the output of a checker that failed without any\ndiagnostic that could be parsed.",
+					},
+					"Truncated": {
+						Doc: "Truncated reports that there were more diagnostics than returned.",
+					},
+				},
+			},
+			"ExtractResult": {
+				Name: "ExtractResult",
+			},
+			"FileReference": {
+				Name: "FileReference",
+				Doc:  "FileReference points to a file in a workspace. The UI shows it as a link to\ndownload the file from the tool server, at URL.",
+				Fields: map[string]codoc.Field{
+					"Type": {
+						Doc: "Type is always \"file\", and tells the UI to show a download link.",
+					},
+					"URL": {
+						Doc: "URL is the path of the file on the tool server.",
+					},
+				},
+			},
+			"Function": {
+				Name: "Function",
+				Doc:  "Function is the schema of a single tool, as sent to the model.",
+				Methods: map[string]codoc.Function{
+					"MarshalJSON": {
+						Name: "MarshalJSON",
+					},
+				},
+			},
+			"GitBlameLine": {
+				Name: "GitBlameLine",
+			},
+			"GitBranchInfo": {
+				Name: "GitBranchInfo",
+			},
+			"GitCommitInfo": {
+				Name: "GitCommitInfo",
+			},
+			"GitFileDiff": {
+				Name: "GitFileDiff",
+			},
+			"GitFileStat": {
+				Name: "GitFileStat",
+			},
+			"GitFileStatus": {
+				Name: "GitFileStatus",
+			},
+			"GitHunk": {
+				Name: "GitHunk",
+			},
+			"GitShowResult": {
+				Name: "GitShowResult",
+			},
+			"GitSignature": {
+				Name: "GitSignature",
+			},
+			"GitStatusResult": {
+				Name: "GitStatusResult",
 			},
 			"Group": {
 				Name: "Group",
+				Fields: map[string]codoc.Field{
+					"plugin": {
+						Doc: "plugin runs the tools of groups loaded from WebAssembly modules, which\nhave no Repo.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"Has": {
+						Name: "Has",
+						Doc:  "Has reports whether the group contains the named tool.",
+						Args: []string{
+							"name",
+						},
+					},
+					"Invoke": {
+						Name: "Invoke",
+						Doc:  "Invoke calls the named tool through Repo, or the plugin of the group, after\nrunning it through the registered hooks, filling in the defaults of omitted\noptional arguments and validating the annotated constraints.",
+						Args: []string{
+							"ctx",
+							"ws",
+							"id",
+							"name",
+							"args",
+						},
+					},
+					"Mutates": {
+						Name: "Mutates",
+						Doc:  "Mutates reports whether the named tool may change the files of a workspace,\nand so needs a checkpoint before it runs.",
+						Args: []string{
+							"name",
+						},
+					},
+					"Use": {
+						Name: "Use",
+						Doc:  "Use registers hooks that wrap the tools of the group. They run in the order\nthey are registered, after the global hooks.",
+						Args: []string{
+							"h",
+						},
+					},
+					"chain": {
+						Name: "chain",
+						Doc:  "chain wraps invoke with the global hooks and those of g, so that the first\nglobal hook registered is the outermost.",
+						Args: []string{
+							"invoke",
+						},
+					},
+					"invoke": {
+						Name: "invoke",
+						Args: []string{
+							"call",
+						},
+					},
+				},
+			},
+			"HTTPConfig": {
+				Name: "HTTPConfig",
+				Doc:  "HTTPConfig restricts where the HTTP tool can send requests.",
+				Fields: map[string]codoc.Field{
+					"AllowedHosts": {
+						Doc: "AllowedHosts are the hosts requests can be sent to. A host may include a\nport, and \"*.example.com\" matches all subdomains of example.com.",
+					},
+					"MaxBodySize": {
+						Doc: "MaxBodySize is the maximum number of response body bytes returned.\nDefaults to 64KiB.",
+					},
+					"Secrets": {
+						Doc: "Secrets are headers added to requests server-side, by name, so that their\nvalues never reach the model or the browser.",
+					},
+					"Timeout": {
+						Doc: "Timeout is the maximum duration of a request. Defaults to 30s.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"checkURL": {
+						Name: "checkURL",
+						Args: []string{
+							"u",
+						},
+					},
+					"maxBodySize": {
+						Name: "maxBodySize",
+					},
+					"timeout": {
+						Name: "timeout",
+					},
+				},
+			},
+			"HTTPDestinationsResult": {
+				Name: "HTTPDestinationsResult",
+			},
+			"HTTPResponse": {
+				Name: "HTTPResponse",
+			},
+			"HTTPSecret": {
+				Name: "HTTPSecret",
+				Doc:  "HTTPSecret is a header injected into requests to the given hosts.",
+				Fields: map[string]codoc.Field{
+					"Header": {
+						Doc: "Header is the name of the header. Defaults to Authorization.",
+					},
+					"Hosts": {
+						Doc: "Hosts are the hosts the secret may be sent to, matched like AllowedHosts.",
+					},
+					"Value": {
+						Doc: "Value is the value of the header, such as \"Bearer ${API_TOKEN}\".\nEnvironment variables are expanded.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"header": {
+						Name: "header",
+					},
+				},
+			},
+			"HTTPSecretDescriptor": {
+				Name: "HTTPSecretDescriptor",
+			},
+			"ImageAnnotation": {
+				Name: "ImageAnnotation",
+				Fields: map[string]codoc.Field{
+					"Color": {
+						Doc: "A color name such as \"red\", or a hex color such as \"#ff0000\". @default red",
+					},
+					"Height": {
+						Doc: "Height of the box in pixels.",
+					},
+					"Size": {
+						Doc: "The text size, or the line width of the box, in pixels. @min 0 @max 500",
+					},
+					"Text": {
+						Doc: "The text, or the label of the box.",
+					},
+					"Type": {
+						Doc: "The kind of annotation. A box with text is labeled above its top left corner. @enum box, text",
+					},
+					"Width": {
+						Doc: "Width of the box in pixels.",
+					},
+					"X": {
+						Doc: "Left edge of the box, or of the text, in pixels.",
+					},
+					"Y": {
+						Doc: "Top edge of the box, or of the text, in pixels.",
+					},
+				},
+			},
+			"ImageInfoResult": {
+				Name: "ImageInfoResult",
+			},
+			"MemoryConfig": {
+				Name: "MemoryConfig",
+				Doc:  "MemoryConfig sets where the Memory tools keep their entries.",
+				Fields: map[string]codoc.Field{
+					"Path": {
+						Doc: "Path is the file the entries are stored in. Defaults to memory.json.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"path": {
+						Name: "path",
+					},
+				},
+			},
+			"MemoryEntry": {
+				Name: "MemoryEntry",
+				Methods: map[string]codoc.Function{
+					"Scope": {
+						Name: "Scope",
+						Doc:  "Scope returns \"global\" if the entry is visible to all chats, and \"chat\"\notherwise.",
+					},
+				},
+			},
+			"OutputPage": {
+				Name: "OutputPage",
+				Fields: map[string]codoc.Field{
+					"Lines": {
+						Doc: "Lines are the lines read, each prefixed with its number. Matching lines\nare numbered as \"12:\", and lines of context as \"12-\".",
+					},
+					"Matches": {
+						Doc: "Matches is the number of lines that matched the pattern, from offset.",
+					},
+					"NextOffset": {
+						Doc: "NextOffset is the line to continue reading from, or 0 at the end of\nthe output.",
+					},
+				},
+			},
+			"PluginConfig": {
+				Name: "PluginConfig",
+				Doc:  "PluginConfig describes a WebAssembly module and what it may access.",
+				Fields: map[string]codoc.Field{
+					"Dirs": {
+						Doc: "Dirs are the host directories the module can access, by the path it\nsees them at, such as {\"/data\": \"/srv/shared/data\"}. The module can't\naccess any other file.",
+					},
+					"Env": {
+						Doc: "Env are the environment variables of the module. Values are expanded.",
+					},
+					"MemoryMB": {
+						Doc: "MemoryMB is the maximum size of the module's memory, in megabytes.\nDefaults to 256.",
+					},
+					"Path": {
+						Doc: "Path is the path of the .wasm file.",
+					},
+					"Timeout": {
+						Doc: "Timeout is the maximum time a call may run. Defaults to 30s.",
+					},
+					"Workspace": {
+						Doc: "Workspace gives the module access to the chat's workspace, at\n/workspace.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"memoryMB": {
+						Name: "memoryMB",
+					},
+					"timeout": {
+						Name: "timeout",
+					},
+				},
+			},
+			"PluginsConfig": {
+				Name: "PluginsConfig",
+				Doc:  "PluginsConfig sets the WebAssembly modules that are loaded as tools.",
+				Fields: map[string]codoc.Field{
+					"Modules": {
+						Doc: "Modules are the plugins, by the name of the tool group they add.",
+					},
+					"Runtime": {
+						Doc: "Runtime is the WASI runtime that runs the modules. Defaults to\nwasmtime.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"runtime": {
+						Name: "runtime",
+					},
+				},
+			},
+			"ProcessInfo": {
+				Name: "ProcessInfo",
+				Fields: map[string]codoc.Field{
+					"LogSize": {
+						Doc: "LogSize is the offset just past the end of the log.",
+					},
+				},
+			},
+			"ProcessOutput": {
+				Name: "ProcessOutput",
+				Fields: map[string]codoc.Field{
+					"Dropped": {
+						Doc: "Dropped reports that the start of the requested log was discarded, as\nonly the most recent output is kept.",
+					},
+					"Output": {
+						Doc: "Output is the log from Offset to NextOffset.",
+					},
+				},
+			},
+			"Property": {
+				Name: "Property",
+			},
+			"Question": {
+				Name: "Question",
+				Doc:  "Question is a question asked by a tool, as sent to the UI.",
+				Fields: map[string]codoc.Field{
+					"CallID": {
+						Doc: "CallID is the ID of the tool call that asks the question.",
+					},
+					"Default": {
+						Doc: "Default is the text the answer to a prompt starts with.",
+					},
+					"Kind": {
+						Doc: "Kind is \"prompt\" for a free text answer, \"confirm\" for yes or no, or\n\"choice\" for one of Choices.",
+					},
+				},
+			},
+			"RedactionConfig": {
+				Name: "RedactionConfig",
+				Doc:  "RedactionConfig sets how secrets are removed from the results of tools.",
+				Fields: map[string]codoc.Field{
+					"Disabled": {
+						Doc: "Disabled turns redaction off.",
+					},
+					"Secrets": {
+						Doc: "Secrets are literal values to remove, such as \"${DEPLOY_TOKEN}\".\nEnvironment variables are expanded. The values of the HTTP secrets are\nalways removed.",
+					},
+				},
+			},
+			"SSHHost": {
+				Name: "SSHHost",
+			},
+			"SSHHostConfig": {
+				Name: "SSHHostConfig",
+				Doc:  "SSHHostConfig describes a remote host the SSH tools can connect to.",
+				Fields: map[string]codoc.Field{
+					"Address": {
+						Doc: "Address is the host name or IP address of the server, optionally\nfollowed by a port. The port defaults to 22.",
+					},
+					"AllowedCommands": {
+						Doc: "AllowedCommands are the commands that may be run on the host, where *\nmatches any text: \"systemctl status *\" allows checking any service,\nand \"*\" allows every command. Commands that use shell operators, such\nas ; | & $( or redirections, only match \"*\". No command is allowed by\ndefault.",
+					},
+					"AllowedPaths": {
+						Doc: "AllowedPaths are the remote directories that files may be uploaded to\nand downloaded from. No transfer is allowed by default.",
+					},
+					"Description": {
+						Doc: "Description tells the model what the host is for.",
+					},
+					"HostKey": {
+						Doc: "HostKey is the public key of the server, in authorized_keys format.\nWhen empty, the server is verified against KnownHosts instead.",
+					},
+					"KeyFile": {
+						Doc: "KeyFile is the path of the private key used to log in.",
+					},
+					"KnownHosts": {
+						Doc: "KnownHosts is the known_hosts file used to verify the server. Defaults\nto ~/.ssh/known_hosts.",
+					},
+					"Passphrase": {
+						Doc: "Passphrase decrypts the key, if it is encrypted. Environment variables\nare expanded.",
+					},
+					"User": {
+						Doc: "User is the user to log in as.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"address": {
+						Name: "address",
+					},
+					"clientConfig": {
+						Name: "clientConfig",
+					},
+				},
+			},
+			"SSHResult": {
+				Name: "SSHResult",
+			},
+			"SearchConfig": {
+				Name: "SearchConfig",
+				Doc:  "SearchConfig sets the folders indexed for the Search tool.",
+				Fields: map[string]codoc.Field{
+					"Extensions": {
+						Doc: "Extensions are the extensions of the files to index. Defaults to common\ntext, markup and source code extensions, and .pdf.",
+					},
+					"Folders": {
+						Doc: "Folders are the directories to index, recursively.",
+					},
+					"IndexPath": {
+						Doc: "IndexPath is the file the index is stored in. Defaults to search.index.",
+					},
+					"MaxFileSize": {
+						Doc: "MaxFileSize is the size above which files are skipped, in bytes.\nDefaults to 10MiB.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"contains": {
+						Name: "contains",
+						Doc:  "contains reports whether path is inside one of the folders of c.",
+						Args: []string{
+							"path",
+						},
+					},
+					"indexPath": {
+						Name: "indexPath",
+					},
+					"indexable": {
+						Name: "indexable",
+						Args: []string{
+							"path",
+							"info",
+						},
+					},
+					"maxFileSize": {
+						Name: "maxFileSize",
+					},
+				},
+			},
+			"SearchResult": {
+				Name: "SearchResult",
+			},
+			"Terminal": {
+				Name: "Terminal",
+				Doc:  "Terminal is a program running in a pseudo-terminal, whose output is kept\non an emulated screen.",
+				Fields: map[string]codoc.Field{
+					"output": {
+						Doc: "output counts the reads from the terminal, to tell when it changed.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"Changes": {
+						Name: "Changes",
+						Doc:  "Changes returns a channel that receives a value when the screen changes,\nand a function to stop watching. Changes that happen while a value is\npending are merged into it.",
+					},
+					"Done": {
+						Name: "Done",
+						Doc:  "Done returns a channel that is closed when the program exits.",
+					},
+					"Resize": {
+						Name: "Resize",
+						Doc:  "Resize changes the size of the terminal.",
+						Args: []string{
+							"cols",
+							"rows",
+						},
+					},
+					"Screen": {
+						Name: "Screen",
+						Doc:  "Screen returns what the terminal currently displays.",
+					},
+					"Write": {
+						Name: "Write",
+						Doc:  "Write types p into the terminal.",
+						Args: []string{
+							"p",
+						},
+					},
+					"close": {
+						Name: "close",
+						Doc:  "close hangs up the terminal, kills the program if it doesn't exit in time,\nand waits for it.",
+					},
+					"info": {
+						Name: "info",
+					},
+					"notify": {
+						Name: "notify",
+					},
+					"read": {
+						Name: "read",
+						Doc:  "read feeds the output of the program to the emulated screen, until it\nexits or the terminal is closed.",
+					},
+					"running": {
+						Name: "running",
+					},
+					"settle": {
+						Name: "settle",
+						Doc:  "settle waits until the terminal has produced output since version and\nthen stayed quiet, the program exits, or wait is over.",
+						Args: []string{
+							"since",
+							"wait",
+						},
+					},
+					"version": {
+						Name: "version",
+					},
+				},
+			},
+			"TerminalInfo": {
+				Name: "TerminalInfo",
+				Fields: map[string]codoc.Field{
+					"Path": {
+						Doc: "Path is where the session can be watched live over WebSocket.",
+					},
+				},
+			},
+			"TerminalScreen": {
+				Name: "TerminalScreen",
+				Doc:  "TerminalScreen is what a terminal session currently displays.",
+				Fields: map[string]codoc.Field{
+					"Screen": {
+						Doc: "Screen holds the lines of the screen, with trailing spaces and blank\nlines removed.",
+					},
+				},
+			},
+			"TestReport": {
+				Name: "TestReport",
+				Fields: map[string]codoc.Field{
+					"Failures": {
+						Doc: "Failures holds the failed tests, with their output.",
+					},
+					"Output": {
+						Doc: "Output is the end of the output of the run, when its results couldn't\nbe parsed, or it failed outside of any test.",
+					},
+					"Tests": {
+						Doc: "Tests holds the passed and skipped tests, if all of them were asked for.",
+					},
+				},
+			},
+			"TestResult": {
+				Name: "TestResult",
+				Fields: map[string]codoc.Field{
+					"File": {
+						Doc: "File and Line point at where the test failed, when known.",
+					},
+					"Package": {
+						Doc: "Package is the Go package, Python module or JavaScript file of the test.",
+					},
+					"Status": {
+						Comment: "\"pass\", \"fail\" or \"skip\"",
+					},
+				},
+			},
+			"UI": {
+				Name: "UI",
+				Doc:  "UI lets a tool ask the user questions while it runs, and wait for the\nanswers. Tools receive it by taking a *UI as a leading parameter, after the\n*Workspace. The questions are shown by the UI of the chat, which watches\nthem over a WebSocket.",
+				Fields: map[string]codoc.Field{
+					"ctx": {
+						Doc: "ctx is the context of the call, which stops the wait for an answer when\nthe client goes away.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"Choose": {
+						Name: "Choose",
+						Doc:  "Choose asks the user to pick one of choices, and returns the index of the\none picked.",
+						Args: []string{
+							"message",
+							"choices",
+						},
+					},
+					"Confirm": {
+						Name: "Confirm",
+						Doc:  "Confirm asks the user a yes or no question.",
+						Args: []string{
+							"message",
+						},
+					},
+					"Prompt": {
+						Name: "Prompt",
+						Doc:  "Prompt asks the user for some text, and returns what they typed.",
+						Args: []string{
+							"message",
+							"def",
+						},
+					},
+					"ask": {
+						Name: "ask",
+						Doc:  "ask shows q in the UI of the chat, and blocks until it is answered, the\ncall is canceled, or questionTimeout passes.",
+						Args: []string{
+							"q",
+						},
+					},
+				},
+			},
+			"Workspace": {
+				Name: "Workspace",
+				Doc:  "Workspace is the working directory of a single conversation. Tools receive\nit by taking a *Workspace as their first parameter.",
+				Methods: map[string]codoc.Function{
+					"FileReference": {
+						Name: "FileReference",
+						Doc:  "FileReference returns a reference to a file of the workspace, to return\nfrom tools that create files the user may want.",
+						Args: []string{
+							"rel",
+						},
+					},
+					"Path": {
+						Name: "Path",
+						Doc:  "Path resolves a path relative to the workspace, refusing paths that point\noutside of it.",
+						Args: []string{
+							"rel",
+						},
+					},
+				},
+			},
+			"WorkspaceInfo": {
+				Name: "WorkspaceInfo",
+				Doc:  "WorkspaceInfo describes a workspace on disk.",
+			},
+			"Workspaces": {
+				Name: "Workspaces",
+				Doc:  "Workspaces manages one workspace directory per conversation under Root.",
+				Methods: map[string]codoc.Function{
+					"Checkpoint": {
+						Name: "Checkpoint",
+						Doc:  "Checkpoint snapshots the files of a workspace before the tool call with the\ngiven ID.",
+						Args: []string{
+							"w",
+							"id",
+							"tool",
+						},
+					},
+					"Checkpoints": {
+						Name: "Checkpoints",
+						Doc:  "Checkpoints lists the checkpoints of a workspace, oldest first.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"Collect": {
+						Name: "Collect",
+						Doc:  "Collect removes the workspaces that haven't been used for longer than maxAge,\nalong with their checkpoints, background processes and terminals, and\nreturns the chat IDs of the removed workspaces.",
+						Args: []string{
+							"maxAge",
+						},
+					},
+					"Get": {
+						Name: "Get",
+						Doc:  "Get returns the workspace of the given conversation, creating it if needed.\nEach call marks the workspace as used, which keeps it from being collected.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"List": {
+						Name: "List",
+						Doc:  "List returns all workspaces, most recently used first.",
+					},
+					"Open": {
+						Name: "Open",
+						Doc:  "Open returns an existing workspace, without marking it as used.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"Remove": {
+						Name: "Remove",
+						Doc:  "Remove stops the background processes and terminals of the given\nconversation, dismisses the questions its tools are waiting on, and deletes\nits workspace and checkpoints.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"Restore": {
+						Name: "Restore",
+						Doc:  "Restore puts the files of a workspace back the way they were before the\ntool call with the given ID. Files created since are removed.",
+						Args: []string{
+							"chatID",
+							"id",
+						},
+					},
+					"checkpointDir": {
+						Name: "checkpointDir",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"dir": {
+						Name: "dir",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"loadCheckpoint": {
+						Name: "loadCheckpoint",
+						Args: []string{
+							"chatID",
+							"id",
+						},
+					},
+					"pruneCheckpoints": {
+						Name: "pruneCheckpoints",
+						Doc:  "pruneCheckpoints removes the oldest checkpoints, leaving checkpointPruneBatch\nfewer than maxCheckpoints, and the contents no longer used by any checkpoint.\nIt returns the checkpoints that are left.",
+						Args: []string{
+							"chatID",
+							"infos",
+						},
+					},
+					"readCheckpointIndex": {
+						Name: "readCheckpointIndex",
+						Doc:  "readCheckpointIndex returns the checkpoints of a workspace, oldest first.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"removeCheckpoints": {
+						Name: "removeCheckpoints",
+						Doc:  "removeCheckpoints deletes all checkpoints of a workspace.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"writeCheckpointIndex": {
+						Name: "writeCheckpointIndex",
+						Args: []string{
+							"chatID",
+							"infos",
+						},
+					},
+				},
+			},
+			"chart": {
+				Name: "chart",
+				Methods: map[string]codoc.Function{
+					"draw": {
+						Name: "draw",
+						Args: []string{
+							"canvas",
+						},
+					},
+					"drawBars": {
+						Name: "drawBars",
+						Args: []string{
+							"canvas",
+							"p",
+						},
+					},
+					"drawLegend": {
+						Name: "drawLegend",
+						Args: []string{
+							"canvas",
+							"y",
+						},
+					},
+					"drawPie": {
+						Name: "drawPie",
+						Args: []string{
+							"canvas",
+							"p",
+						},
+					},
+					"drawXY": {
+						Name: "drawXY",
+						Args: []string{
+							"canvas",
+							"p",
+						},
+					},
+					"drawYAxis": {
+						Name: "drawYAxis",
+						Args: []string{
+							"canvas",
+							"p",
+							"ticks",
+							"y",
+						},
+					},
+					"label": {
+						Name: "label",
+						Doc:  "label returns the label of the i-th category.",
+						Args: []string{
+							"i",
+						},
+					},
+					"legend": {
+						Name: "legend",
+					},
+					"sliceColor": {
+						Name: "sliceColor",
+						Args: []string{
+							"i",
+						},
+					},
+					"validate": {
+						Name: "validate",
+					},
+				},
+			},
+			"chartPoint": {
+				Name: "chartPoint",
+			},
+			"checkpoint": {
+				Name: "checkpoint",
+				Doc:  "checkpoint is the manifest of a snapshot. File contents are stored once per\nworkspace, by hash, and shared between checkpoints.",
+			},
+			"checkpointEntry": {
+				Name: "checkpointEntry",
+			},
+			"dialect": {
+				Name: "dialect",
+				Doc:  "dialect holds the driver specific parts of the Database tools.",
+			},
+			"document": {
+				Name: "document",
+				Doc:  "document is the text of a file, split into the units pages are selected by.",
+				Fields: map[string]codoc.Field{
+					"Pages": {
+						Doc: "Pages is the page count, if known.",
+					},
+					"Unit": {
+						Doc: "Unit is what the parts are called, such as \"page\" or \"sheet\". It is\nempty if the document can't be split.",
+					},
+				},
+			},
+			"function": {
+				Name: "function",
+				Doc:  "function holds what is needed to prepare the arguments of a tool call.",
+				Fields: map[string]codoc.Field{
+					"mutates": {
+						Doc: "mutates is set for tools that may change the files of a workspace,\nwhich are annotated with @mutates in their doc comment.",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"prepare": {
+						Name: "prepare",
+						Doc:  "prepare fills in omitted optional arguments, reports missing required ones,\nand checks the given ones against their annotated constraints. Unknown\narguments are left for llum-tools to report.",
+						Args: []string{
+							"args",
+						},
+					},
+				},
+			},
+			"goTestEvent": {
+				Name: "goTestEvent",
+				Doc:  "goTestEvent is a line of `go test -json`.",
+			},
+			"indexedChunk": {
+				Name: "indexedChunk",
+				Methods: map[string]codoc.Function{
+					"snippet": {
+						Name: "snippet",
+						Doc:  "snippet returns the lines of the chunk around the one matching the most\nterms, and the number of the first of them.",
+						Args: []string{
+							"terms",
+						},
+					},
+				},
+			},
+			"indexedFile": {
+				Name: "indexedFile",
+			},
+			"jestResults": {
+				Name: "jestResults",
+				Doc:  "jestResults are the results written by `jest --json`, and by Vitest's json\nreporter.",
+			},
+			"junitTestSuite": {
+				Name: "junitTestSuite",
+				Doc:  "junitTestSuite is a test suite in JUnit XML, as written by pytest and\nnode --test. Suites may be nested, or hold test cases directly.",
+			},
+			"legendEntry": {
+				Name: "legendEntry",
+			},
+			"limitedBuffer": {
+				Name: "limitedBuffer",
+				Doc:  "limitedBuffer keeps the first max bytes written to it, and discards the rest.",
+				Methods: map[string]codoc.Function{
+					"String": {
+						Name: "String",
+					},
+					"Write": {
+						Name: "Write",
+						Args: []string{
+							"p",
+						},
+					},
+				},
+			},
+			"limitedWriter": {
+				Name: "limitedWriter",
+				Doc:  "limitedWriter writes up to max bytes to w, and fails once more is written.",
+				Methods: map[string]codoc.Function{
+					"Write": {
+						Name: "Write",
+						Args: []string{
+							"p",
+						},
+					},
+				},
+			},
+			"markdownWriter": {
+				Name: "markdownWriter",
+				Methods: map[string]codoc.Function{
+					"block": {
+						Name: "block",
+						Doc:  "block writes s as a paragraph.",
+						Args: []string{
+							"s",
+						},
+					},
+					"blocks": {
+						Name: "blocks",
+						Doc:  "blocks writes the children of n, grouping runs of inline content into\nparagraphs.",
+						Args: []string{
+							"n",
+						},
+					},
+				},
+			},
+			"memoryStore": {
+				Name: "memoryStore",
+				Doc:  "memoryStore holds the entries of the Memory tools, and persists them to a\nJSON file.",
+				Methods: map[string]codoc.Function{
+					"load": {
+						Name: "load",
+						Doc:  "load reads the entries from disk the first time it is called. s.mu must be\nheld.",
+					},
+					"update": {
+						Name: "update",
+						Doc:  "update replaces the entries with the result of fn, and saves them.",
+						Args: []string{
+							"fn",
+						},
+					},
+					"visible": {
+						Name: "visible",
+						Doc:  "visible returns the entries of scope that the chat can see, and that have\nall of tags.",
+						Args: []string{
+							"chatID",
+							"scope",
+							"tags",
+						},
+					},
+				},
+			},
+			"outputStore": {
+				Name: "outputStore",
+				Doc:  "outputStore keeps the outputs replaced by TruncateOutput, per chat. The\noutputs are written to files in a temporary directory, as a chat can have\nhundreds of megabytes of them.",
+				Methods: map[string]codoc.Function{
+					"lines": {
+						Name: "lines",
+						Doc:  "lines returns the lines of the output of chatID stored under handle.",
+						Args: []string{
+							"chatID",
+							"handle",
+						},
+					},
+					"removeChat": {
+						Name: "removeChat",
+						Doc:  "removeChat forgets the outputs of a chat.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"store": {
+						Name: "store",
+						Doc:  "store keeps text for chatID, and returns its handle.",
+						Args: []string{
+							"chatID",
+							"text",
+						},
+					},
+				},
+			},
+			"param": {
+				Name: "param",
+				Doc:  "param is a single argument of a tool function.",
+				Fields: map[string]codoc.Field{
+					"typ": {
+						Doc: "typ is nil for the arguments of plugins, which are passed on as JSON.",
+					},
+				},
+			},
+			"pendingQuestion": {
+				Name: "pendingQuestion",
+			},
+			"plot": {
+				Name: "plot",
+				Doc:  "plot is the area of the chart inside the axes.",
+			},
+			"plugin": {
+				Name: "plugin",
+				Doc:  "plugin runs the functions of a WebAssembly module. A function is called by\nrunning the module with the arguments \"call\" and the name of the function,\nand the JSON arguments on stdin. The module prints its result to stdout,\nand exits with a non-zero status, after printing the error to stderr, when\nthe call fails.",
+				Methods: map[string]codoc.Function{
+					"call": {
+						Name: "call",
+						Doc:  "call runs the function fn of the plugin, with arguments already prepared by\nfn. Results that are valid JSON are returned as is, anything else as text.",
+						Args: []string{
+							"ws",
+							"fn",
+							"args",
+						},
+					},
+					"group": {
+						Name: "group",
+						Doc:  "group builds the tool group of the plugin from its manifest.",
+					},
+					"run": {
+						Name: "run",
+						Doc:  "run runs the module in the WASI runtime, with the directories it was\ngranted, and returns what it printed to stdout.",
+						Args: []string{
+							"ctx",
+							"ws",
+							"stdin",
+							"args",
+						},
+					},
+				},
+			},
+			"pluginFunction": {
+				Name: "pluginFunction",
+				Fields: map[string]codoc.Field{
+					"Mutates": {
+						Doc: "Mutates is set by functions that change the files of the workspace,\nso that it is checkpointed before they run.",
+					},
+				},
+			},
+			"pluginManifest": {
+				Name: "pluginManifest",
+				Doc:  "pluginManifest is printed by a module when run with the \"manifest\" argument.",
+			},
+			"pngCanvas": {
+				Name: "pngCanvas",
+				Methods: map[string]codoc.Function{
+					"polygon": {
+						Name: "polygon",
+						Args: []string{
+							"points",
+							"col",
+						},
+					},
+					"polyline": {
+						Name: "polyline",
+						Args: []string{
+							"points",
+							"width",
+							"col",
+						},
+					},
+					"text": {
+						Name: "text",
+						Args: []string{
+							"p",
+							"s",
+							"size",
+							"anchor",
+							"vertical",
+							"col",
+						},
+					},
+				},
+			},
+			"process": {
+				Name: "process",
+				Methods: map[string]codoc.Function{
+					"info": {
+						Name: "info",
+					},
+					"logs": {
+						Name: "logs",
+						Args: []string{
+							"offset",
+							"limit",
+						},
+					},
+					"running": {
+						Name: "running",
+					},
+					"stop": {
+						Name: "stop",
+						Doc:  "stop asks the process to exit, kills it if it doesn't exit in time, and\nwaits for it.",
+					},
+				},
+			},
+			"processLog": {
+				Name: "processLog",
+				Doc:  "processLog keeps the most recent output written to it, up to max bytes, and\nthe offset of its first byte in the whole output.",
+				Methods: map[string]codoc.Function{
+					"Write": {
+						Name: "Write",
+						Args: []string{
+							"b",
+						},
+					},
+					"read": {
+						Name: "read",
+						Doc:  "read returns up to limit bytes of the log from offset, the offset they\nactually start at, and whether output before it was dropped. Negative\noffsets count from the end.",
+						Args: []string{
+							"offset",
+							"limit",
+						},
+					},
+					"size": {
+						Name: "size",
+					},
+				},
+			},
+			"processManager": {
+				Name: "processManager",
+				Doc:  "processManager tracks the background processes of each chat.",
+				Methods: map[string]codoc.Function{
+					"get": {
+						Name: "get",
+						Args: []string{
+							"chatID",
+							"name",
+						},
+					},
+					"list": {
+						Name: "list",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"start": {
+						Name: "start",
+						Args: []string{
+							"ws",
+							"name",
+							"command",
+						},
+					},
+					"stopChat": {
+						Name: "stopChat",
+						Doc:  "stopChat stops the processes of a chat and forgets them.",
+						Args: []string{
+							"chatID",
+						},
+					},
+				},
+			},
+			"questionBroker": {
+				Name: "questionBroker",
+				Doc:  "questionBroker keeps the questions waiting for an answer, per chat.",
+				Methods: map[string]codoc.Function{
+					"add": {
+						Name: "add",
+						Args: []string{
+							"chatID",
+							"q",
+						},
+					},
+					"answer": {
+						Name: "answer",
+						Args: []string{
+							"chatID",
+							"a",
+						},
+					},
+					"cancelChat": {
+						Name: "cancelChat",
+						Doc:  "cancelChat dismisses the questions of a chat, whose workspace is gone.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"notify": {
+						Name: "notify",
+						Doc:  "notify tells the watchers of chatID that its questions changed. b.mu must\nbe held.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"pending": {
+						Name: "pending",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"remove": {
+						Name: "remove",
+						Args: []string{
+							"chatID",
+							"id",
+						},
+					},
+					"watch": {
+						Name: "watch",
+						Args: []string{
+							"chatID",
+						},
+					},
+				},
+			},
+			"searchIndex": {
+				Name: "searchIndex",
+				Doc:  "searchIndex is a BM25 inverted index over chunks of the indexed files.",
+				Fields: map[string]codoc.Field{
+					"Length": {
+						Comment: "total number of terms in all chunks",
+					},
+					"Postings": {
+						Comment: "term -> chunk -> term frequency",
+					},
+				},
+				Methods: map[string]codoc.Function{
+					"add": {
+						Name: "add",
+						Doc:  "add indexes chunk and returns its id. idx.mu must be held.",
+						Args: []string{
+							"chunk",
+						},
+					},
+					"load": {
+						Name: "load",
+						Args: []string{
+							"path",
+						},
+					},
+					"remove": {
+						Name: "remove",
+						Doc:  "remove drops the file at path from the index. idx.mu must be held.",
+						Args: []string{
+							"path",
+						},
+					},
+					"save": {
+						Name: "save",
+						Args: []string{
+							"path",
+						},
+					},
+					"search": {
+						Name: "search",
+						Args: []string{
+							"terms",
+							"limit",
+							"path",
+						},
+					},
+					"sync": {
+						Name: "sync",
+						Doc:  "sync indexes the files that changed since the index was saved, and drops the\nones that no longer exist. It returns the number of files indexed.",
+						Args: []string{
+							"c",
+						},
+					},
+					"update": {
+						Name: "update",
+						Doc:  "update reindexes the file at path if it changed, or drops it if it no longer\nexists. If path is a directory, the files under it are updated. It reports\nwhether the file was indexed.",
+						Args: []string{
+							"c",
+							"path",
+						},
+					},
+				},
+			},
+			"secretPattern": {
+				Name: "secretPattern",
+				Doc:  "secretPattern matches a kind of secret. When the pattern has a group named\n\"secret\", only that group is replaced.",
+			},
+			"storedOutput": {
+				Name: "storedOutput",
+			},
+			"svgCanvas": {
+				Name: "svgCanvas",
+				Methods: map[string]codoc.Function{
+					"bytes": {
+						Name: "bytes",
+					},
+					"polygon": {
+						Name: "polygon",
+						Args: []string{
+							"points",
+							"col",
+						},
+					},
+					"polyline": {
+						Name: "polyline",
+						Args: []string{
+							"points",
+							"width",
+							"col",
+						},
+					},
+					"text": {
+						Name: "text",
+						Args: []string{
+							"p",
+							"s",
+							"size",
+							"anchor",
+							"vertical",
+							"col",
+						},
+					},
+				},
+			},
+			"terminalManager": {
+				Name: "terminalManager",
+				Doc:  "terminalManager tracks the terminal sessions of each chat.",
+				Methods: map[string]codoc.Function{
+					"closeChat": {
+						Name: "closeChat",
+						Doc:  "closeChat closes the terminal sessions of a chat and forgets them.",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"get": {
+						Name: "get",
+						Args: []string{
+							"chatID",
+							"name",
+						},
+					},
+					"list": {
+						Name: "list",
+						Args: []string{
+							"chatID",
+						},
+					},
+					"open": {
+						Name: "open",
+						Args: []string{
+							"ws",
+							"name",
+							"command",
+							"cols",
+							"rows",
+						},
+					},
+					"remove": {
+						Name: "remove",
+						Args: []string{
+							"chatID",
+							"name",
+						},
+					},
+				},
 			},
 		},
 	})
@@ -0,0 +1,638 @@
+package toolfns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GitStatusResult struct {
+	Branch   string          `json:"branch"`
+	Commit   string          `json:"commit"`
+	Upstream string          `json:"upstream,omitempty"`
+	Ahead    int             `json:"ahead"`
+	Behind   int             `json:"behind"`
+	Files    []GitFileStatus `json:"files"`
+}
+
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	Staged   string `json:"staged,omitempty"`
+	Unstaged string `json:"unstaged,omitempty"`
+}
+
+type GitFileDiff struct {
+	Path      string    `json:"path"`
+	OldPath   string    `json:"old_path,omitempty"`
+	Status    string    `json:"status"`
+	Binary    bool      `json:"binary,omitempty"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Hunks     []GitHunk `json:"hunks,omitempty"`
+}
+
+type GitHunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Header   string   `json:"header,omitempty"`
+	Lines    []string `json:"lines"`
+}
+
+type GitCommitInfo struct {
+	Hash      string        `json:"hash"`
+	Parents   []string      `json:"parents"`
+	Author    GitSignature  `json:"author"`
+	Committer GitSignature  `json:"committer"`
+	Subject   string        `json:"subject"`
+	Body      string        `json:"body,omitempty"`
+	Files     []GitFileStat `json:"files,omitempty"`
+}
+
+type GitSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type GitFileStat struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+type GitShowResult struct {
+	Commit GitCommitInfo `json:"commit"`
+	Files  []GitFileDiff `json:"files"`
+}
+
+type GitBlameLine struct {
+	Line    int       `json:"line"`
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	Content string    `json:"content"`
+}
+
+type GitBranchInfo struct {
+	Name     string `json:"name"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Current  bool   `json:"current"`
+}
+
+// Returns the current branch and the status of the changed files in a git repository.
+// repo: Path of the repository, relative to the workspace. @default .
+func GitStatus(ws *Workspace, repo string) (*GitStatusResult, error) {
+	out, err := git(ws, repo, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseGitStatus(out), nil
+}
+
+// Returns the changes in a git repository as a list of files with their hunks.
+// repo: Path of the repository, relative to the workspace. @default .
+// rev: Revision or range to compare against, such as HEAD~1 or main..feature. Compares the working tree to the index if empty. @optional
+// staged: Show the staged changes instead of the unstaged ones. @optional
+// paths: Only show changes to these paths. @optional
+// context: Number of context lines around each change. @min 0 @max 100 @default 3
+func GitDiff(ws *Workspace, repo string, rev string, staged bool, paths []string, context int) ([]GitFileDiff, error) {
+	if err := checkGitArg("rev", rev); err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", "-M", fmt.Sprintf("-U%d", context)}
+	if staged {
+		args = append(args, "--staged")
+	}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+
+	out, err := git(ws, repo, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitDiff(out), nil
+}
+
+// Returns the commit history of a git repository, with the files changed by each commit.
+// repo: Path of the repository, relative to the workspace. @default .
+// rev: Revision or range to list, such as HEAD or main..feature. @default HEAD
+// paths: Only list commits that change these paths. @optional
+// limit: Maximum number of commits to return. @min 1 @max 500 @default 20
+func GitLog(ws *Workspace, repo string, rev string, paths []string, limit int) ([]GitCommitInfo, error) {
+	if err := checkGitArg("rev", rev); err != nil {
+		return nil, err
+	}
+	args := []string{"log", "--numstat", "-M", "--format=" + gitCommitFormat, "-n", strconv.Itoa(limit), rev, "--"}
+	args = append(args, paths...)
+
+	out, err := git(ws, repo, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitLog(out), nil
+}
+
+// Returns the metadata and changes of a single commit.
+// repo: Path of the repository, relative to the workspace. @default .
+// rev: The commit to show. @default HEAD
+// paths: Only show changes to these paths. @optional
+func GitShow(ws *Workspace, repo string, rev string, paths []string) (*GitShowResult, error) {
+	commits, err := GitLog(ws, repo, rev, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit not found: %s", rev)
+	}
+
+	args := []string{"show", "--format=", "--no-color", "--no-ext-diff", "-M", "--diff-merges=first-parent", rev, "--"}
+	args = append(args, paths...)
+	out, err := git(ws, repo, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitShowResult{
+		Commit: commits[0],
+		Files:  parseGitDiff(out),
+	}, nil
+}
+
+// Returns the commit that last changed each line of a file.
+// repo: Path of the repository, relative to the workspace. @default .
+// path: Path of the file, relative to the repository.
+// rev: Revision to blame at. Uses the working tree if empty. @optional
+// start: First line to blame. @min 1 @optional
+// end: Last line to blame. @min 1 @optional
+func GitBlame(ws *Workspace, repo string, path string, rev string, start int, end int) ([]GitBlameLine, error) {
+	if err := checkGitArg("rev", rev); err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--porcelain"}
+	if start > 0 || end > 0 {
+		lines := "1,"
+		if start > 0 {
+			lines = strconv.Itoa(start) + ","
+		}
+		if end > 0 {
+			lines += strconv.Itoa(end)
+		}
+		args = append(args, "-L", lines)
+	}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--", path)
+
+	out, err := git(ws, repo, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitBlame(out), nil
+}
+
+// Lists the local branches of a git repository, optionally creating a new one first.
+// @mutates
+// repo: Path of the repository, relative to the workspace. @default .
+// create: Name of a branch to create. @optional
+// start: Revision the created branch starts at. Defaults to HEAD. @optional
+func GitBranch(ws *Workspace, repo string, create string, start string) ([]GitBranchInfo, error) {
+	if err := checkGitArg("create", create); err != nil {
+		return nil, err
+	}
+	if err := checkGitArg("start", start); err != nil {
+		return nil, err
+	}
+	if create != "" {
+		args := []string{"branch", create}
+		if start != "" {
+			args = append(args, start)
+		}
+		if _, err := git(ws, repo, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := git(ws, repo, "for-each-ref", "--format=%(refname:short)%00%(objectname)%00%(upstream:short)%00%(HEAD)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []GitBranchInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		branches = append(branches, GitBranchInfo{
+			Name:     fields[0],
+			Commit:   fields[1],
+			Upstream: fields[2],
+			Current:  fields[3] == "*",
+		})
+	}
+	return branches, nil
+}
+
+// Records changes to a git repository, and returns the created commit.
+// @mutates
+// repo: Path of the repository, relative to the workspace. @default .
+// message: The commit message.
+// paths: Paths to stage before committing. @optional
+// all: Stage all modified and deleted files before committing. @optional
+func GitCommit(ws *Workspace, repo string, message string, paths []string, all bool) (*GitCommitInfo, error) {
+	if len(paths) > 0 {
+		args := append([]string{"add", "--"}, paths...)
+		if _, err := git(ws, repo, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"commit", "-m", message}
+	if all {
+		args = append(args, "--all")
+	}
+	if _, err := git(ws, repo, args...); err != nil {
+		return nil, err
+	}
+
+	commits, err := GitLog(ws, repo, "HEAD", nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &commits[0], nil
+}
+
+// Switches to a branch or commit, or restores files from it, and returns the resulting status.
+// @mutates
+// repo: Path of the repository, relative to the workspace. @default .
+// ref: The branch, tag or commit to check out.
+// create: Create ref as a new branch before switching to it. @optional
+// paths: Only restore these paths from ref, without switching. @optional
+func GitCheckout(ws *Workspace, repo string, ref string, create bool, paths []string) (*GitStatusResult, error) {
+	if err := checkGitArg("ref", ref); err != nil {
+		return nil, err
+	}
+	args := []string{"checkout"}
+	switch {
+	case len(paths) > 0:
+		args = append(args, ref, "--")
+		args = append(args, paths...)
+	case create:
+		args = append(args, "-b", ref)
+	default:
+		args = append(args, ref)
+	}
+	if _, err := git(ws, repo, args...); err != nil {
+		return nil, err
+	}
+
+	return GitStatus(ws, repo)
+}
+
+// checkGitArg refuses revisions and branch names that git would read as
+// options, such as --output=<file>, since they are passed before "--".
+func checkGitArg(name, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("invalid %s %q: must not start with \"-\"", name, value)
+	}
+	return nil
+}
+
+// git runs a git command in a repository inside the workspace. Repository
+// discovery is stopped at the workspace, so that a workspace which isn't a
+// repository is never mistaken for a repository it is contained in.
+func git(ws *Workspace, repo string, args ...string) (string, error) {
+	dir, err := ws.Path(repo)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", append([]string{"-c", "core.quotePath=false"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CEILING_DIRECTORIES="+filepath.Dir(ws.Dir),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_PAGER=cat",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// gitCommitFormat separates the commit fields with \x1f, and starts each
+// commit with \x1e. Anything after the last field is the --numstat output.
+const gitCommitFormat = "%x1e%H%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%cn%x1f%ce%x1f%cI%x1f%s%x1f%b%x1f"
+
+func parseGitLog(out string) []GitCommitInfo {
+	commits := []GitCommitInfo{}
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 11 {
+			continue
+		}
+
+		authorDate, _ := time.Parse(time.RFC3339, fields[4])
+		committerDate, _ := time.Parse(time.RFC3339, fields[7])
+		commit := GitCommitInfo{
+			Hash:      fields[0],
+			Parents:   strings.Fields(fields[1]),
+			Author:    GitSignature{Name: fields[2], Email: fields[3], Date: authorDate},
+			Committer: GitSignature{Name: fields[5], Email: fields[6], Date: committerDate},
+			Subject:   fields[8],
+			Body:      strings.TrimSpace(fields[9]),
+		}
+
+		for _, line := range strings.Split(fields[10], "\n") {
+			stat := strings.SplitN(line, "\t", 3)
+			if len(stat) != 3 {
+				continue
+			}
+			fs := GitFileStat{}
+			fs.OldPath, fs.Path = parseGitRename(stat[2])
+			if stat[0] == "-" {
+				fs.Binary = true
+			} else {
+				fs.Additions, _ = strconv.Atoi(stat[0])
+				fs.Deletions, _ = strconv.Atoi(stat[1])
+			}
+			commit.Files = append(commit.Files, fs)
+		}
+
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// parseGitRename splits a path of the --numstat output, which is written as
+// "old => new" or "dir/{old => new}/file" for renamed files, into the old and
+// new paths. The old path is empty for files that weren't renamed.
+func parseGitRename(path string) (oldPath, newPath string) {
+	start, end := strings.Index(path, "{"), strings.LastIndex(path, "}")
+	if start >= 0 && end > start {
+		if from, to, ok := strings.Cut(path[start+1:end], " => "); ok {
+			prefix, suffix := path[:start], path[end+1:]
+			// An empty side, as in "{ => dir}/file", leaves a double slash.
+			oldPath = strings.Replace(prefix+from+suffix, "//", "/", 1)
+			newPath = strings.Replace(prefix+to+suffix, "//", "/", 1)
+			return strings.TrimPrefix(oldPath, "/"), strings.TrimPrefix(newPath, "/")
+		}
+	}
+	if from, to, ok := strings.Cut(path, " => "); ok {
+		return from, to
+	}
+	return "", path
+}
+
+func parseGitStatus(out string) *GitStatusResult {
+	status := &GitStatusResult{Files: []GitFileStatus{}}
+
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+
+		switch entry[0] {
+		case '#':
+			fields := strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				status.Commit = fields[2]
+			case "branch.head":
+				status.Branch = fields[2]
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+
+		case '1', '2', 'u':
+			// 1 XY sub mH mI mW hH hI path
+			// 2 XY sub mH mI mW hH hI Xscore path, followed by the original path
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			n := map[byte]int{'1': 9, '2': 10, 'u': 11}[entry[0]]
+			fields := strings.SplitN(entry, " ", n)
+			if len(fields) != n {
+				continue
+			}
+			fs := GitFileStatus{
+				Path:     fields[n-1],
+				Staged:   gitStatusCode(fields[1][0]),
+				Unstaged: gitStatusCode(fields[1][1]),
+			}
+			if entry[0] == '2' && i+1 < len(entries) {
+				i++
+				fs.OrigPath = entries[i]
+			}
+			status.Files = append(status.Files, fs)
+
+		case '?':
+			status.Files = append(status.Files, GitFileStatus{Path: entry[2:], Unstaged: "untracked"})
+		}
+	}
+
+	return status
+}
+
+func gitStatusCode(c byte) string {
+	switch c {
+	case 'M':
+		return "modified"
+	case 'T':
+		return "type changed"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'U':
+		return "unmerged"
+	}
+	return ""
+}
+
+func parseGitDiff(out string) []GitFileDiff {
+	files := []GitFileDiff{}
+	var file *GitFileDiff
+	var hunk *GitHunk
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(nil, 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, GitFileDiff{Status: "modified"})
+			file = &files[len(files)-1]
+			hunk = nil
+
+			// Best effort, for diffs without ---/+++ lines, such as binary files.
+			names := strings.TrimPrefix(line, "diff --git ")
+			if idx := strings.Index(names, " b/"); strings.HasPrefix(names, "a/") && idx >= 0 {
+				file.OldPath = names[2:idx]
+				file.Path = names[idx+3:]
+			}
+
+		case file == nil:
+			continue
+
+		case hunk != nil && len(line) > 0 && strings.ContainsRune(" +-\\", rune(line[0])):
+			hunk.Lines = append(hunk.Lines, line)
+			switch line[0] {
+			case '+':
+				file.Additions++
+			case '-':
+				file.Deletions++
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			file.Hunks = append(file.Hunks, parseGitHunkHeader(line))
+			hunk = &file.Hunks[len(file.Hunks)-1]
+
+		case strings.HasPrefix(line, "new file mode"):
+			file.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = "renamed"
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			file.Path = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		case strings.HasPrefix(line, "--- "):
+			if name := strings.TrimPrefix(line, "--- "); name != "/dev/null" {
+				file.OldPath = strings.TrimPrefix(name, "a/")
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if name := strings.TrimPrefix(line, "+++ "); name != "/dev/null" {
+				file.Path = strings.TrimPrefix(name, "b/")
+			}
+		}
+	}
+
+	for i := range files {
+		f := &files[i]
+		if f.Path == "" || f.Status == "deleted" {
+			f.Path = f.OldPath
+		}
+		if f.OldPath == f.Path || f.Status == "added" {
+			f.OldPath = ""
+		}
+	}
+	return files
+}
+
+// parseGitHunkHeader parses a line such as "@@ -1,5 +1,6 @@ func main() {".
+func parseGitHunkHeader(line string) GitHunk {
+	var hunk GitHunk
+	rest := strings.TrimPrefix(line, "@@ ")
+	ranges, header, _ := strings.Cut(rest, " @@")
+	hunk.Header = strings.TrimSpace(header)
+
+	for _, r := range strings.Fields(ranges) {
+		start, count, ok := strings.Cut(r[1:], ",")
+		s, _ := strconv.Atoi(start)
+		c := 1
+		if ok {
+			c, _ = strconv.Atoi(count)
+		}
+		if r[0] == '-' {
+			hunk.OldStart, hunk.OldLines = s, c
+		} else {
+			hunk.NewStart, hunk.NewLines = s, c
+		}
+	}
+	return hunk
+}
+
+func parseGitBlame(out string) []GitBlameLine {
+	type commitInfo struct {
+		author  string
+		date    time.Time
+		summary string
+	}
+	commits := map[string]*commitInfo{}
+
+	lines := []GitBlameLine{}
+	var current GitBlameLine
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(nil, 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+
+		if strings.HasPrefix(line, "\t") {
+			ci := commits[current.Commit]
+			current.Author, current.Date, current.Summary = ci.author, ci.date, ci.summary
+			current.Content = line[1:]
+			lines = append(lines, current)
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if (len(key) == 40 || len(key) == 64) && !strings.ContainsFunc(key, func(r rune) bool { return !strings.ContainsRune("0123456789abcdef", r) }) {
+			// <hash> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(value)
+			current = GitBlameLine{Commit: key}
+			if len(fields) >= 2 {
+				current.Line, _ = strconv.Atoi(fields[1])
+			}
+			if commits[key] == nil {
+				commits[key] = &commitInfo{}
+			}
+			continue
+		}
+
+		ci := commits[current.Commit]
+		if ci == nil {
+			continue
+		}
+		switch key {
+		case "author":
+			ci.author = value
+		case "author-time":
+			sec, _ := strconv.ParseInt(value, 10, 64)
+			ci.date = time.Unix(sec, 0).UTC()
+		case "summary":
+			ci.summary = value
+		}
+	}
+	return lines
+}
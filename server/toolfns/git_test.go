@@ -0,0 +1,159 @@
+package toolfns
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGitWorkspace returns a workspace holding a repository with a single
+// commit of a.txt.
+func newGitWorkspace(t *testing.T) *Workspace {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	ws := &Workspace{ChatID: "test", Dir: t.TempDir()}
+	if _, err := git(ws, ".", "init", "-q", "-b", "main"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "a.txt", "one\ntwo\n")
+	if _, err := GitCommit(ws, ".", "first", []string{"a.txt"}, false); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func writeFile(t *testing.T, ws *Workspace, rel, content string) {
+	t.Helper()
+	path := filepath.Join(ws.Dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitStatusAndDiff(t *testing.T) {
+	ws := newGitWorkspace(t)
+	writeFile(t, ws, "a.txt", "one\n2\n")
+	writeFile(t, ws, "b.txt", "new\n")
+
+	status, err := GitStatus(ws, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "main" || len(status.Files) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	diff, err := GitDiff(ws, ".", "", false, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 || diff[0].Path != "a.txt" || diff[0].Additions != 1 || diff[0].Deletions != 1 {
+		t.Fatalf("diff = %+v", diff)
+	}
+}
+
+func TestGitLogRenames(t *testing.T) {
+	ws := newGitWorkspace(t)
+	if _, err := git(ws, ".", "mv", "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ws, "dir/old/x.txt", "x\n")
+	if _, err := GitCommit(ws, ".", "rename", []string{"dir"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(ws, ".", "mv", "dir/old", "dir/new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GitCommit(ws, ".", "move dir", nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	commits, err := GitLog(ws, ".", "HEAD", nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("got %d commits", len(commits))
+	}
+	want := map[string]string{"b.txt": "a.txt", "dir/new/x.txt": "dir/old/x.txt"}
+	for _, c := range commits[:2] {
+		for _, f := range c.Files {
+			if old, ok := want[f.Path]; ok && f.OldPath != old {
+				t.Errorf("%s: old path = %q, want %q", f.Path, f.OldPath, old)
+			}
+			delete(want, f.Path)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("renames not found: %v", want)
+	}
+}
+
+func TestParseGitRename(t *testing.T) {
+	tests := []struct{ in, old, new string }{
+		{"a.txt", "", "a.txt"},
+		{"a.txt => b.txt", "a.txt", "b.txt"},
+		{"src/{old => new}/x.go", "src/old/x.go", "src/new/x.go"},
+		{"{ => dir}/x.go", "x.go", "dir/x.go"},
+		{"dir/{sub => }/x.go", "dir/sub/x.go", "dir/x.go"},
+	}
+	for _, tt := range tests {
+		old, new := parseGitRename(tt.in)
+		if old != tt.old || new != tt.new {
+			t.Errorf("parseGitRename(%q) = %q, %q, want %q, %q", tt.in, old, new, tt.old, tt.new)
+		}
+	}
+}
+
+func TestGitRejectsOptions(t *testing.T) {
+	ws := newGitWorkspace(t)
+	outside := filepath.Join(t.TempDir(), "out")
+
+	calls := map[string]func() error{
+		"GitDiff": func() error {
+			_, err := GitDiff(ws, ".", "--output="+outside, false, nil, 3)
+			return err
+		},
+		"GitLog": func() error {
+			_, err := GitLog(ws, ".", "--output="+outside, nil, 1)
+			return err
+		},
+		"GitShow": func() error {
+			_, err := GitShow(ws, ".", "--output="+outside, nil)
+			return err
+		},
+		"GitBlame": func() error {
+			_, err := GitBlame(ws, ".", "a.txt", "--output="+outside, 0, 0)
+			return err
+		},
+		"GitBranch": func() error {
+			_, err := GitBranch(ws, ".", "x", "--output="+outside)
+			return err
+		},
+		"GitCheckout": func() error {
+			_, err := GitCheckout(ws, ".", "--orphan=x", false, nil)
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err == nil || !strings.Contains(err.Error(), "must not start with") {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Error("a file was written outside of the workspace")
+	}
+}
@@ -0,0 +1,96 @@
+package toolfns
+
+import "context"
+
+// Call is a tool call, as seen by hooks.
+type Call struct {
+	// Context is canceled when the client that made the call goes away.
+	Context context.Context
+	// ID is the ID of the tool call, as sent by the client.
+	ID string
+	// ChatID is the chat the call was made in.
+	ChatID string
+	// Group is the name of the group of the tool.
+	Group string
+	// Name is the name of the tool.
+	Name string
+	// Args are the arguments of the call, as sent by the model. Hooks may
+	// change them before calling the next invoker; defaults are filled in and
+	// constraints checked after all hooks have run.
+	Args map[string]any
+	// Workspace is the workspace of the chat.
+	Workspace *Workspace
+	// Redactions is the number of secrets removed from the result by
+	// RedactSecrets.
+	Redactions int
+}
+
+// Invoker runs a tool call and returns its result.
+type Invoker func(call *Call) (any, error)
+
+// Hook wraps the invocation of tools, in the same way as HTTP middleware. It
+// returns an Invoker that can inspect or rewrite the call before passing it
+// on to next, change the result next returns, or return a result of its own
+// without calling next at all:
+//
+//	func denyDrop(next toolfns.Invoker) toolfns.Invoker {
+//		return func(call *toolfns.Call) (any, error) {
+//			if q, _ := call.Args["query"].(string); strings.Contains(strings.ToUpper(q), "DROP") {
+//				return nil, errors.New("DROP statements are not allowed")
+//			}
+//			return next(call)
+//		}
+//	}
+type Hook func(next Invoker) Invoker
+
+// hooks are the hooks that wrap the tools of every group.
+var hooks []Hook
+
+// Use registers hooks that wrap the tools of every group. They run in the
+// order they are registered, before the hooks of the group. Hooks must be
+// registered before the server starts handling calls.
+func Use(h ...Hook) {
+	hooks = append(hooks, h...)
+}
+
+// Use registers hooks that wrap the tools of the group. They run in the order
+// they are registered, after the global hooks.
+func (g *Group) Use(h ...Hook) {
+	g.hooks = append(g.hooks, h...)
+}
+
+// Before returns a hook that calls fn before each tool call. If fn returns an
+// error, the call fails with it and the tool isn't run.
+func Before(fn func(call *Call) error) Hook {
+	return func(next Invoker) Invoker {
+		return func(call *Call) (any, error) {
+			if err := fn(call); err != nil {
+				return nil, err
+			}
+			return next(call)
+		}
+	}
+}
+
+// After returns a hook that calls fn with the result of each tool call, and
+// returns what fn returns instead.
+func After(fn func(call *Call, out any, err error) (any, error)) Hook {
+	return func(next Invoker) Invoker {
+		return func(call *Call) (any, error) {
+			out, err := next(call)
+			return fn(call, out, err)
+		}
+	}
+}
+
+// chain wraps invoke with the global hooks and those of g, so that the first
+// global hook registered is the outermost.
+func (g *Group) chain(invoke Invoker) Invoker {
+	for i := len(g.hooks) - 1; i >= 0; i-- {
+		invoke = g.hooks[i](invoke)
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		invoke = hooks[i](invoke)
+	}
+	return invoke
+}
@@ -0,0 +1,230 @@
+package toolfns
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HTTPConfig restricts where the HTTP tool can send requests.
+type HTTPConfig struct {
+	// AllowedHosts are the hosts requests can be sent to. A host may include a
+	// port, and "*.example.com" matches all subdomains of example.com.
+	AllowedHosts []string `json:"allowed_hosts"`
+	// Secrets are headers added to requests server-side, by name, so that their
+	// values never reach the model or the browser.
+	Secrets map[string]HTTPSecret `json:"secrets,omitempty"`
+	// MaxBodySize is the maximum number of response body bytes returned.
+	// Defaults to 64KiB.
+	MaxBodySize int `json:"max_body_size,omitempty"`
+	// Timeout is the maximum duration of a request. Defaults to 30s.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// HTTPSecret is a header injected into requests to the given hosts.
+type HTTPSecret struct {
+	// Header is the name of the header. Defaults to Authorization.
+	Header string `json:"header,omitempty"`
+	// Value is the value of the header, such as "Bearer ${API_TOKEN}".
+	// Environment variables are expanded.
+	Value string `json:"value"`
+	// Hosts are the hosts the secret may be sent to, matched like AllowedHosts.
+	Hosts []string `json:"hosts"`
+}
+
+type HTTPDestinationsResult struct {
+	AllowedHosts []string               `json:"allowed_hosts"`
+	Secrets      []HTTPSecretDescriptor `json:"secrets"`
+}
+
+type HTTPSecretDescriptor struct {
+	Name   string   `json:"name"`
+	Header string   `json:"header"`
+	Hosts  []string `json:"hosts"`
+}
+
+type HTTPResponse struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// Lists the hosts that HTTP requests can be sent to, and the names of the secrets that can be attached to them.
+func HTTPDestinations() HTTPDestinationsResult {
+	result := HTTPDestinationsResult{
+		AllowedHosts: config.HTTP.AllowedHosts,
+		Secrets:      []HTTPSecretDescriptor{},
+	}
+	for name, secret := range config.HTTP.Secrets {
+		result.Secrets = append(result.Secrets, HTTPSecretDescriptor{
+			Name:   name,
+			Header: secret.header(),
+			Hosts:  secret.Hosts,
+		})
+	}
+	sort.Slice(result.Secrets, func(i, j int) bool { return result.Secrets[i].Name < result.Secrets[j].Name })
+	return result
+}
+
+// Sends an HTTP request and returns the status, headers and body of the response. Only allowed hosts can be reached.
+// method: The HTTP method. @enum GET, POST, PUT, PATCH, DELETE, HEAD @default GET
+// url: The URL to send the request to. @format uri
+// headers: Headers to send with the request. @optional
+// body: The request body. @optional
+// secret: Name of a configured secret to attach to the request. See HTTPDestinations. @optional
+func HTTPRequest(method string, url string, headers map[string]string, body string, secret string) (*HTTPResponse, error) {
+	c := config.HTTP
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	var s *HTTPSecret
+	if secret != "" {
+		sec, ok := c.Secrets[secret]
+		if !ok {
+			return nil, fmt.Errorf("unknown secret: %s", secret)
+		}
+		if !matchHost(sec.Hosts, req.URL) {
+			return nil, fmt.Errorf("secret %s cannot be sent to %s", secret, req.URL.Host)
+		}
+		s = &sec
+		req.Header.Set(s.header(), os.ExpandEnv(s.Value))
+	}
+
+	client := &http.Client{
+		Timeout: c.timeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if err := c.checkURL(req.URL); err != nil {
+				return fmt.Errorf("redirect: %w", err)
+			}
+			if s != nil && !matchHost(s.Hosts, req.URL) {
+				req.Header.Del(s.header())
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	max := c.maxBodySize()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HTTPResponse{
+		Status:  resp.StatusCode,
+		Headers: make(map[string]string, len(resp.Header)),
+	}
+	for name := range resp.Header {
+		result.Headers[name] = resp.Header.Get(name)
+	}
+	if len(data) > max {
+		data = data[:max]
+		result.Truncated = true
+	}
+	if result.Truncated {
+		// The cut may have split the last character.
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if utf8.Valid(data) {
+		result.Body = string(data)
+	} else {
+		result.Body = fmt.Sprintf("<%d bytes of binary data>", len(data))
+	}
+	return result, nil
+}
+
+func (c HTTPConfig) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if !matchHost(c.AllowedHosts, u) {
+		return fmt.Errorf("host %s is not allowed", u.Host)
+	}
+	return nil
+}
+
+// defaultPorts are the ports of URLs that don't have one, by scheme.
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// matchHost reports whether the host of u matches one of patterns. Patterns
+// with a port only match URLs with that port, which for URLs without one is
+// the default port of their scheme.
+func matchHost(patterns []string, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		patternHost, patternPort, err := net.SplitHostPort(pattern)
+		if err != nil {
+			patternHost, patternPort = strings.Trim(pattern, "[]"), ""
+		}
+		if patternPort != "" && patternPort != port {
+			continue
+		}
+
+		switch {
+		case patternHost == "*":
+			return true
+		case strings.HasPrefix(patternHost, "*."):
+			if strings.HasSuffix(host, patternHost[1:]) {
+				return true
+			}
+		case host == patternHost:
+			return true
+		}
+	}
+	return false
+}
+
+func (s HTTPSecret) header() string {
+	if s.Header == "" {
+		return "Authorization"
+	}
+	return s.Header
+}
+
+func (c HTTPConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Timeout)
+}
+
+func (c HTTPConfig) maxBodySize() int {
+	if c.MaxBodySize <= 0 {
+		return 64 * 1024
+	}
+	return c.MaxBodySize
+}
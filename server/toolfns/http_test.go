@@ -0,0 +1,48 @@
+package toolfns
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		url      string
+		want     bool
+	}{
+		{nil, "https://example.com/", false},
+		{[]string{"*"}, "http://localhost:8080/", true},
+		{[]string{"example.com"}, "https://example.com/", true},
+		{[]string{"example.com"}, "https://EXAMPLE.com/", true},
+		{[]string{"Example.com"}, "https://example.com:8443/", true},
+		{[]string{"example.com"}, "https://api.example.com/", false},
+		{[]string{"example.com"}, "https://example.com.evil.org/", false},
+		{[]string{"example.com:443"}, "https://example.com/", true},
+		{[]string{"example.com:443"}, "https://example.com:443/", true},
+		{[]string{"example.com:443"}, "http://example.com/", false},
+		{[]string{"example.com:80"}, "http://example.com/", true},
+		{[]string{"example.com:8443"}, "https://example.com/", false},
+		{[]string{"example.com:8443"}, "https://example.com:8443/", true},
+		{[]string{"*.example.com"}, "https://api.example.com/", true},
+		{[]string{"*.example.com"}, "https://example.com/", false},
+		{[]string{"*.example.com"}, "https://notexample.com/", false},
+		{[]string{"*.example.com:443"}, "https://api.example.com/", true},
+		{[]string{"*.example.com:443"}, "https://api.example.com:8443/", false},
+		{[]string{"*:8080"}, "http://localhost:8080/", true},
+		{[]string{"*:8080"}, "http://localhost/", false},
+		{[]string{"[::1]"}, "http://[::1]:3000/", true},
+		{[]string{"[::1]:3000"}, "http://[::1]:3000/", true},
+		{[]string{"[::1]:3000"}, "http://[::1]/", false},
+		{[]string{"other.org", "example.com:443"}, "https://example.com/", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchHost(tt.patterns, u); got != tt.want {
+			t.Errorf("matchHost(%q, %s) = %v, want %v", tt.patterns, tt.url, got, tt.want)
+		}
+	}
+}
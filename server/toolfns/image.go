@@ -0,0 +1,488 @@
+package toolfns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+type ImageInfoResult struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+}
+
+type ImageAnnotation struct {
+	// The kind of annotation. A box with text is labeled above its top left corner. @enum box, text
+	Type string `json:"type"`
+	// Left edge of the box, or of the text, in pixels.
+	X int `json:"x"`
+	// Top edge of the box, or of the text, in pixels.
+	Y int `json:"y"`
+	// Width of the box in pixels.
+	Width int `json:"width,omitempty"`
+	// Height of the box in pixels.
+	Height int `json:"height,omitempty"`
+	// The text, or the label of the box.
+	Text string `json:"text,omitempty"`
+	// A color name such as "red", or a hex color such as "#ff0000". @default red
+	Color string `json:"color,omitempty"`
+	// The text size, or the line width of the box, in pixels. @min 0 @max 500
+	Size float64 `json:"size,omitempty"`
+}
+
+const (
+	maxImagePixels = 50_000_000
+	maxImageSide   = 8192
+	// maxAnnotationSize bounds the text size and line width of annotations,
+	// as glyphs are rasterized whole.
+	maxAnnotationSize = 500
+)
+
+// Returns the size and format of an image.
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+func ImageInfo(ws *Workspace, source string) (*ImageInfoResult, error) {
+	data, err := imageData(ws, source)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := imageDecodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageInfoResult{Width: cfg.Width, Height: cfg.Height, Format: format}, nil
+}
+
+// Resizes an image, and shows it to the user. If only one of width and height is given, the aspect ratio is kept.
+// @mutates
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+// width: The new width in pixels. @min 1 @max 8192 @optional
+// height: The new height in pixels. @min 1 @max 8192 @optional
+// format: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional
+// output: Path in the workspace to save the result to. @optional
+func ImageResize(ws *Workspace, source string, width int, height int, format string, output string) (*ContentTypeResponse, error) {
+	img, srcFormat, err := loadImage(ws, source)
+	if err != nil {
+		return nil, err
+	}
+	if width == 0 && height == 0 {
+		return nil, errors.New("width or height is required")
+	}
+
+	b := img.Bounds()
+	if width == 0 {
+		width = max(1, int(math.Round(float64(b.Dx())*float64(height)/float64(b.Dy()))))
+	}
+	if height == 0 {
+		height = max(1, int(math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+	}
+	if width > maxImageSide || height > maxImageSide {
+		return nil, fmt.Errorf("the result would be larger than %dx%d", maxImageSide, maxImageSide)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return saveImage(ws, dst, outputFormat(format, srcFormat), output)
+}
+
+// Crops an image to a rectangle, and shows it to the user.
+// @mutates
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+// x: Left edge of the rectangle in pixels. @min 0
+// y: Top edge of the rectangle in pixels. @min 0
+// width: Width of the rectangle in pixels. @min 1
+// height: Height of the rectangle in pixels. @min 1
+// format: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional
+// output: Path in the workspace to save the result to. @optional
+func ImageCrop(ws *Workspace, source string, x int, y int, width int, height int, format string, output string) (*ContentTypeResponse, error) {
+	img, srcFormat, err := loadImage(ws, source)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	r := image.Rect(x, y, x+width, y+height).Add(b.Min).Intersect(b)
+	if r.Empty() {
+		return nil, fmt.Errorf("the rectangle is outside of the %dx%d image", b.Dx(), b.Dy())
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Copy(dst, image.Point{}, img, r, draw.Src, nil)
+	return saveImage(ws, dst, outputFormat(format, srcFormat), output)
+}
+
+// Rotates an image clockwise, and shows it to the user. Angles that aren't multiples of 90 enlarge the image to fit, with transparent corners, or white ones in JPEGs.
+// @mutates
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+// degrees: The angle to rotate by, clockwise. @min -360 @max 360
+// format: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional
+// output: Path in the workspace to save the result to. @optional
+func ImageRotate(ws *Workspace, source string, degrees float64, format string, output string) (*ContentTypeResponse, error) {
+	img, srcFormat, err := loadImage(ws, source)
+	if err != nil {
+		return nil, err
+	}
+	format = outputFormat(format, srcFormat)
+	return saveImage(ws, rotateImage(img, degrees, format == "jpeg"), format, output)
+}
+
+// Converts an image to PNG or JPEG, and shows it to the user.
+// @mutates
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+// format: Format of the result. @enum png, jpeg
+// output: Path in the workspace to save the result to. @optional
+func ImageConvert(ws *Workspace, source string, format string, output string) (*ContentTypeResponse, error) {
+	img, _, err := loadImage(ws, source)
+	if err != nil {
+		return nil, err
+	}
+	return saveImage(ws, img, format, output)
+}
+
+// Draws boxes and text on an image, and shows it to the user. Use it to point out parts of an image.
+// @mutates
+// source: Path of the image in the workspace, or the image data as base64 or a data URL.
+// annotations: The boxes and text to draw, in order. @min 1
+// format: Format of the result. Defaults to the format of the image if it is PNG or JPEG, and PNG otherwise. @enum png, jpeg @optional
+// output: Path in the workspace to save the result to. @optional
+func ImageAnnotate(ws *Workspace, source string, annotations []ImageAnnotation, format string, output string) (*ContentTypeResponse, error) {
+	img, srcFormat, err := loadImage(ws, source)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Copy(dst, image.Point{}, img, b, draw.Src, nil)
+
+	for i, a := range annotations {
+		c, err := parseColor(a.Color)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %d: %w", i+1, err)
+		}
+		switch a.Type {
+		case "box":
+			if a.Width <= 0 || a.Height <= 0 {
+				return nil, fmt.Errorf("annotation %d: a box needs a width and a height", i+1)
+			}
+			lineWidth := int(min(a.Size, maxAnnotationSize))
+			if lineWidth <= 0 {
+				lineWidth = max(2, min(b.Dx(), b.Dy())/200)
+			}
+			drawBox(dst, image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height), lineWidth, c)
+			if a.Text != "" {
+				size := max(14, float64(min(b.Dx(), b.Dy()))/40)
+				if err := drawLabel(dst, image.Pt(a.X, a.Y), a.Text, size, c); err != nil {
+					return nil, fmt.Errorf("annotation %d: %w", i+1, err)
+				}
+			}
+		case "text":
+			if a.Text == "" {
+				return nil, fmt.Errorf("annotation %d: text is empty", i+1)
+			}
+			size := min(a.Size, maxAnnotationSize)
+			if size <= 0 {
+				size = max(16, float64(min(b.Dx(), b.Dy()))/30)
+			}
+			if err := drawText(dst, image.Pt(a.X, a.Y), a.Text, size, c); err != nil {
+				return nil, fmt.Errorf("annotation %d: %w", i+1, err)
+			}
+		default:
+			return nil, fmt.Errorf("annotation %d: unknown type %q", i+1, a.Type)
+		}
+	}
+	return saveImage(ws, dst, outputFormat(format, srcFormat), output)
+}
+
+// imageData returns the bytes of an image given as a workspace path, a data
+// URL or base64.
+func imageData(ws *Workspace, src string) ([]byte, error) {
+	if strings.HasPrefix(src, "data:") {
+		_, payload, ok := strings.Cut(src, ",")
+		if !ok {
+			return nil, errors.New("invalid data URL")
+		}
+		return base64.StdEncoding.DecodeString(payload)
+	}
+
+	if p, err := ws.Path(src); err == nil {
+		if data, err := os.ReadFile(p); err == nil {
+			return data, nil
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(src), ""))
+	if err != nil {
+		return nil, fmt.Errorf("image %q is neither a file in the workspace nor base64", truncateString(src, 64))
+	}
+	return data, nil
+}
+
+func imageDecodeConfig(data []byte) (image.Config, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, "", fmt.Errorf("decoding image: %w", err)
+	}
+	return cfg, format, nil
+}
+
+func loadImage(ws *Workspace, src string) (image.Image, string, error) {
+	data, err := imageData(ws, src)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, _, err := imageDecodeConfig(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", fmt.Errorf("image is too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding image: %w", err)
+	}
+	return img, format, nil
+}
+
+// saveImage encodes img, writes it to output in the workspace if it is set,
+// and returns it as a data URL.
+func saveImage(ws *Workspace, img image.Image, format string, output string) (*ContentTypeResponse, error) {
+	var buf bytes.Buffer
+	contentType := "image/png"
+	var err error
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if output != "" {
+		p, err := ws.Path(output)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ContentTypeResponse{
+		Name:        output,
+		ContentType: contentType,
+		Content:     "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+func outputFormat(format, srcFormat string) string {
+	if format != "" {
+		return format
+	}
+	if srcFormat == "jpeg" {
+		return "jpeg"
+	}
+	return "png"
+}
+
+// flatten draws img over a white background, since JPEGs have no
+// transparency.
+func flatten(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	stddraw.Draw(dst, b, image.White, image.Point{}, stddraw.Src)
+	stddraw.Draw(dst, b, img, b.Min, stddraw.Over)
+	return dst
+}
+
+func rotateImage(img image.Image, degrees float64, opaque bool) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	turns := math.Mod(degrees, 360)
+	if turns < 0 {
+		turns += 360
+	}
+	if math.Mod(turns, 90) == 0 {
+		var dst *image.RGBA
+		if turns == 90 || turns == 270 {
+			dst = image.NewRGBA(image.Rect(0, 0, h, w))
+		} else {
+			dst = image.NewRGBA(image.Rect(0, 0, w, h))
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := img.At(b.Min.X+x, b.Min.Y+y)
+				switch turns {
+				case 0:
+					dst.Set(x, y, c)
+				case 90:
+					dst.Set(h-1-y, x, c)
+				case 180:
+					dst.Set(w-1-x, h-1-y, c)
+				case 270:
+					dst.Set(y, w-1-x, c)
+				}
+			}
+		}
+		return dst
+	}
+
+	rad := turns * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	dw := int(math.Ceil(math.Abs(float64(w)*cos) + math.Abs(float64(h)*sin)))
+	dh := int(math.Ceil(math.Abs(float64(w)*sin) + math.Abs(float64(h)*cos)))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	if opaque {
+		stddraw.Draw(dst, dst.Bounds(), image.White, image.Point{}, stddraw.Src)
+	}
+
+	// Maps source to destination coordinates: rotate around the center of
+	// the source, then move it to the center of the destination.
+	cx, cy := float64(b.Min.X)+float64(w)/2, float64(b.Min.Y)+float64(h)/2
+	dx, dy := float64(dw)/2, float64(dh)/2
+	m := f64.Aff3{
+		cos, -sin, dx - cos*cx + sin*cy,
+		sin, cos, dy - sin*cx - cos*cy,
+	}
+	draw.CatmullRom.Transform(dst, m, img, b, draw.Over, nil)
+	return dst
+}
+
+func drawBox(dst *image.RGBA, r image.Rectangle, width int, c color.Color) {
+	src := image.NewUniform(c)
+	for _, edge := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width),
+		image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y),
+		image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		stddraw.Draw(dst, edge, src, image.Point{}, stddraw.Over)
+	}
+}
+
+// drawLabel draws text in white on a background of color c, just above pt, or
+// just below it if there is no room above.
+func drawLabel(dst *image.RGBA, pt image.Point, text string, size float64, c color.Color) error {
+	face, err := fontFace(size)
+	if err != nil {
+		return err
+	}
+	metrics := face.Metrics()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	padding := int(size / 4)
+	width := font.MeasureString(face, text).Ceil()
+
+	r := image.Rect(pt.X, pt.Y-height-2*padding, pt.X+width+2*padding, pt.Y)
+	if r.Min.Y < dst.Bounds().Min.Y {
+		r = r.Add(image.Pt(0, height+2*padding))
+	}
+	stddraw.Draw(dst, r, image.NewUniform(c), image.Point{}, stddraw.Over)
+	return drawText(dst, r.Min.Add(image.Pt(padding, padding)), text, size, color.White)
+}
+
+// drawText draws text with its top left corner at pt.
+func drawText(dst *image.RGBA, pt image.Point, text string, size float64, c color.Color) error {
+	face, err := fontFace(size)
+	if err != nil {
+		return err
+	}
+	metrics := face.Metrics()
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face}
+	for i, line := range strings.Split(text, "\n") {
+		d.Dot = fixed.P(pt.X, pt.Y+metrics.Ascent.Ceil()+i*metrics.Height.Ceil())
+		d.DrawString(line)
+	}
+	return nil
+}
+
+var (
+	goRegular     *opentype.Font
+	goRegularErr  error
+	goRegularOnce sync.Once
+)
+
+// fontFace returns the Go font at size.
+func fontFace(size float64) (font.Face, error) {
+	goRegularOnce.Do(func() {
+		goRegular, goRegularErr = opentype.Parse(goregular.TTF)
+	})
+	if goRegularErr != nil {
+		return nil, goRegularErr
+	}
+	return opentype.NewFace(goRegular, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+var namedColors = map[string]color.RGBA{
+	"red":    {0xe5, 0x39, 0x35, 0xff},
+	"green":  {0x43, 0xa0, 0x47, 0xff},
+	"blue":   {0x1e, 0x88, 0xe5, 0xff},
+	"yellow": {0xfd, 0xd8, 0x35, 0xff},
+	"orange": {0xfb, 0x8c, 0x00, 0xff},
+	"purple": {0x8e, 0x24, 0xaa, 0xff},
+	"pink":   {0xd8, 0x1b, 0x60, 0xff},
+	"cyan":   {0x00, 0xac, 0xc1, 0xff},
+	"gray":   {0x75, 0x75, 0x75, 0xff},
+	"black":  {0x00, 0x00, 0x00, 0xff},
+	"white":  {0xff, 0xff, 0xff, 0xff},
+}
+
+// parseColor parses a color name, or a hex color as #rgb, #rrggbb or
+// #rrggbbaa. An empty string is red.
+func parseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		s = "red"
+	}
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "…"
+}
@@ -0,0 +1,37 @@
+package toolfns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestImageAnnotateSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	ws := &Workspace{ChatID: "test", Dir: t.TempDir()}
+	source := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	// Sizes beyond the schema are clamped when the tool is called directly.
+	_, err := ImageAnnotate(ws, source, []ImageAnnotation{
+		{Type: "text", Text: "huge", Size: 1e6},
+		{Type: "box", X: 10, Y: 10, Width: 50, Height: 50, Text: "box", Size: 1e6},
+	}, "png", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// And rejected when they come from the model.
+	_, err = toolFunction(t, "ImageAnnotate").prepare(map[string]any{
+		"source":      source,
+		"annotations": []any{map[string]any{"type": "text", "text": "huge", "size": 1e6}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "annotations[0].size") {
+		t.Errorf("prepare with a size of 1e6: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package toolfns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryConfig sets where the Memory tools keep their entries.
+type MemoryConfig struct {
+	// Path is the file the entries are stored in. Defaults to memory.json.
+	Path string `json:"path,omitempty"`
+}
+
+type MemoryEntry struct {
+	ID      string    `json:"id"`
+	ChatID  string    `json:"chat_id,omitempty"`
+	Content string    `json:"content"`
+	Tags    []string  `json:"tags,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Scope returns "global" if the entry is visible to all chats, and "chat"
+// otherwise.
+func (e *MemoryEntry) Scope() string {
+	if e.ChatID == "" {
+		return "global"
+	}
+	return "chat"
+}
+
+// Remembers a fact, preference or piece of information for later conversations. Only remember what the user asks you to, or what will clearly be useful again.
+// content: What to remember, written so that it makes sense on its own.
+// tags: Short labels to group the entry by, such as "infra" or "preferences". @optional
+// scope: Whether the entry is only recalled in this chat, or in all chats. @enum chat, global @default global
+func Remember(ws *Workspace, content string, tags []string, scope string) (*MemoryEntry, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("content is empty")
+	}
+
+	entry := &MemoryEntry{
+		ID:      newMemoryID(),
+		Content: content,
+		Tags:    normalizeTags(tags),
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	if scope == "chat" {
+		entry.ChatID = ws.ChatID
+	}
+
+	err := memories.update(func(entries []*MemoryEntry) []*MemoryEntry {
+		return append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Searches remembered entries by keywords, and returns the best matches, most relevant first.
+// query: Keywords to search for.
+// tags: Only return entries with all of these tags. @optional
+// limit: Maximum number of entries to return. @min 1 @max 100 @default 10
+func Recall(ws *Workspace, query string, tags []string, limit int) ([]*MemoryEntry, error) {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil, errors.New("query has no searchable words")
+	}
+
+	entries, err := memories.visible(ws.ChatID, "all", normalizeTags(tags))
+	if err != nil {
+		return nil, err
+	}
+
+	scores := map[*MemoryEntry]int{}
+	var matches []*MemoryEntry
+	for _, entry := range entries {
+		words := map[string]bool{}
+		for _, word := range tokenize(entry.Content + " " + strings.Join(entry.Tags, " ")) {
+			words[word] = true
+		}
+		score := 0
+		for _, term := range terms {
+			if words[term] {
+				score++
+			}
+		}
+		if score > 0 {
+			scores[entry] = score
+			matches = append(matches, entry)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if scores[matches[i]] != scores[matches[j]] {
+			return scores[matches[i]] > scores[matches[j]]
+		}
+		return matches[i].Created.After(matches[j].Created)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []*MemoryEntry{}
+	}
+	return matches, nil
+}
+
+// Lists remembered entries, newest first.
+// scope: Which entries to list. @enum chat, global, all @default all
+// tag: Only list entries with this tag. @optional
+func ListMemories(ws *Workspace, scope string, tag string) ([]*MemoryEntry, error) {
+	var tags []string
+	if tag != "" {
+		tags = normalizeTags([]string{tag})
+	}
+	entries, err := memories.visible(ws.ChatID, scope, tags)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Created.After(entries[j].Created) })
+	return entries, nil
+}
+
+// Forgets a remembered entry.
+// id: The ID of the entry, as returned by Remember, Recall or ListMemories.
+func Forget(ws *Workspace, id string) (string, error) {
+	found := false
+	err := memories.update(func(entries []*MemoryEntry) []*MemoryEntry {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.ID == id && (entry.ChatID == "" || entry.ChatID == ws.ChatID) {
+				found = true
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("no memory with id %s", id)
+	}
+	return "Forgotten.", nil
+}
+
+// memoryStore holds the entries of the Memory tools, and persists them to a
+// JSON file.
+type memoryStore struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries []*MemoryEntry
+}
+
+var memories = &memoryStore{}
+
+// visible returns the entries of scope that the chat can see, and that have
+// all of tags.
+func (s *memoryStore) visible(chatID, scope string, tags []string) ([]*MemoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	entries := []*MemoryEntry{}
+	for _, entry := range s.entries {
+		if entry.ChatID != "" && entry.ChatID != chatID {
+			continue
+		}
+		if scope != "all" && entry.Scope() != scope {
+			continue
+		}
+		if !hasTags(entry, tags) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// update replaces the entries with the result of fn, and saves them.
+func (s *memoryStore) update(fn func([]*MemoryEntry) []*MemoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	entries := fn(append([]*MemoryEntry(nil), s.entries...))
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := config.Memory.path()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+// load reads the entries from disk the first time it is called. s.mu must be
+// held.
+func (s *memoryStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(config.Memory.path())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.entries); err != nil {
+			return fmt.Errorf("reading memories: %w", err)
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+func hasTags(entry *MemoryEntry, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range entry.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+func newMemoryID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c MemoryConfig) path() string {
+	if c.Path == "" {
+		return "memory.json"
+	}
+	return c.Path
+}
@@ -0,0 +1,353 @@
+package toolfns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type OutputPage struct {
+	Handle string `json:"handle"`
+	// Lines are the lines read, each prefixed with its number. Matching lines
+	// are numbered as "12:", and lines of context as "12-".
+	Lines      string `json:"lines"`
+	TotalLines int    `json:"total_lines"`
+	// Matches is the number of lines that matched the pattern, from offset.
+	Matches int `json:"matches,omitempty"`
+	// NextOffset is the line to continue reading from, or 0 at the end of
+	// the output.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+const (
+	// maxResultSize is the size above which the text of a tool result is
+	// stored on the server, and replaced by excerpts.
+	maxResultSize = 32 * 1024
+	// outputExcerptSize is the size of the head and tail excerpts of a stored
+	// output.
+	outputExcerptSize = 6 * 1024
+	// maxStoredOutput is the number of bytes kept of each stored output.
+	maxStoredOutput = 32 * 1024 * 1024
+	// maxStoredOutputs is the number of outputs kept per chat. Older outputs
+	// are forgotten.
+	maxStoredOutputs = 20
+	// maxOutputLine is the number of characters of a line returned by
+	// ReadOutput.
+	maxOutputLine = 2000
+)
+
+// Reads an output that was too large to be returned whole, by the handle it was stored under. Returns a range of lines, or the lines matching a pattern.
+// handle: The handle of the output. @example out-3
+// offset: Line to start from, starting at 1. @min 1 @default 1
+// limit: Maximum number of lines to return. @min 1 @max 2000 @default 200
+// pattern: Regular expression to search for. When set, only the matching lines are returned, from offset on. @optional
+// around: Number of lines of context to return around each match. @min 0 @max 10 @default 0
+func ReadOutput(ws *Workspace, handle string, offset int, limit int, pattern string, around int) (*OutputPage, error) {
+	lines, err := outputs.lines(ws.ChatID, handle)
+	if err != nil {
+		return nil, err
+	}
+	page := &OutputPage{Handle: handle, TotalLines: len(lines)}
+	if offset > len(lines) {
+		return page, nil
+	}
+
+	var re *regexp.Regexp
+	if pattern != "" {
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	var b strings.Builder
+	written, last, full := 0, -1, false
+	write := func(i int, sep string) bool {
+		if full {
+			return false
+		}
+		line := lines[i]
+		if utf8.RuneCountInString(line) > maxOutputLine {
+			runes := []rune(line)
+			line = string(runes[:maxOutputLine]) + fmt.Sprintf("[... %d more characters]", len(runes)-maxOutputLine)
+		}
+		entry := strconv.Itoa(i+1) + sep + " " + line + "\n"
+		if written > 0 && b.Len()+len(entry) > maxResultSize {
+			full = true
+			return false
+		}
+		if re != nil && last >= 0 && i > last+1 {
+			b.WriteString("--\n")
+		}
+		b.WriteString(entry)
+		last = i
+		return true
+	}
+
+	for i := offset - 1; i < len(lines); i++ {
+		if written == limit {
+			page.NextOffset = i + 1
+			break
+		}
+		if re == nil {
+			if !write(i, ":") {
+				page.NextOffset = i + 1
+				break
+			}
+			written++
+			continue
+		}
+
+		if !re.MatchString(lines[i]) {
+			continue
+		}
+		start := max(i-around, last+1, offset-1)
+		ok := true
+		for j := start; j < i && ok; j++ {
+			ok = write(j, "-")
+		}
+		if ok {
+			ok = write(i, ":")
+		}
+		if !ok {
+			page.NextOffset = i + 1
+			break
+		}
+		written++
+		page.Matches++
+		for j := i + 1; j <= i+around && j < len(lines) && !re.MatchString(lines[j]); j++ {
+			write(j, "-")
+		}
+	}
+	page.Lines = b.String()
+	return page, nil
+}
+
+// TruncateOutput is a hook that replaces the text of tool results larger than
+// maxResultSize by its head and tail, and keeps the whole text on the server
+// under a handle, for ReadOutput. Strings that are too large are replaced
+// wherever they are in a result; other results that are still too large are
+// replaced whole.
+func TruncateOutput(next Invoker) Invoker {
+	return func(call *Call) (any, error) {
+		out, err := next(call)
+		if err != nil || call.Name == "ReadOutput" {
+			return out, err
+		}
+		return truncateResult(call.ChatID, out), nil
+	}
+}
+
+var base64Regex = regexp.MustCompile(`^[A-Za-z0-9+/]*={0,2}$`)
+
+func truncateResult(chatID string, out any) any {
+	if s, ok := out.(string); ok {
+		if len(s) <= maxResultSize {
+			return s
+		}
+		return storeOutput(chatID, s)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil || len(data) <= maxResultSize {
+		return out
+	}
+	data = jsonStringRegex.ReplaceAllFunc(data, func(lit []byte) []byte {
+		if len(lit) <= maxResultSize {
+			return lit
+		}
+		// Escapes such as \u0001 make the literal much larger than the
+		// string itself.
+		var s string
+		if err := json.Unmarshal(lit, &s); err != nil || len(s) <= maxResultSize {
+			return lit
+		}
+		// Base64, as in the content of images, is left for the client to
+		// decode and is never read as text.
+		if base64Regex.MatchString(s) {
+			return lit
+		}
+		quoted, _ := json.Marshal(storeOutput(chatID, s))
+		return quoted
+	})
+	if textSize(data) <= maxResultSize {
+		return json.RawMessage(data)
+	}
+
+	indented, err := json.MarshalIndent(json.RawMessage(data), "", "  ")
+	if err != nil {
+		return json.RawMessage(data)
+	}
+	s := string(indented)
+	return storeOutput(chatID, s)
+}
+
+// textSize returns the size of a JSON encoded result, without its large
+// base64 strings.
+func textSize(data []byte) int {
+	size := len(data)
+	for _, lit := range jsonStringRegex.FindAll(data, -1) {
+		if len(lit) > outputExcerptSize && base64Regex.Match(lit[1:len(lit)-1]) {
+			size -= len(lit)
+		}
+	}
+	return size
+}
+
+// storeOutput stores s for chatID, and returns its excerpt.
+func storeOutput(chatID, s string) string {
+	handle, err := outputs.store(chatID, s)
+	if err != nil {
+		return outputExcerpt(s, fmt.Sprintf("The whole output has %d lines, but could not be stored: %v", countLines(s), err))
+	}
+	note := fmt.Sprintf("The whole output has %d lines, and is stored as %q: read it with ReadOutput, by line or by searching for a pattern", countLines(s), handle)
+	if len(s) > maxStoredOutput {
+		note += fmt.Sprintf(". Only the first %d MB are stored", maxStoredOutput>>20)
+	}
+	return outputExcerpt(s, note)
+}
+
+// outputExcerpt returns the head and tail of s, cut at line ends where
+// possible, with a note about the rest. Strings too short to leave anything
+// out are returned whole.
+func outputExcerpt(s string, note string) string {
+	if len(s) <= 2*outputExcerptSize {
+		return s
+	}
+	// Cut at rune boundaries, so that no character is split.
+	headEnd := outputExcerptSize
+	for headEnd > 0 && !utf8.RuneStart(s[headEnd]) {
+		headEnd--
+	}
+	if i := strings.LastIndexByte(s[:headEnd], '\n'); i > 0 {
+		headEnd = i + 1
+	}
+	tailStart := len(s) - outputExcerptSize
+	for tailStart < len(s) && !utf8.RuneStart(s[tailStart]) {
+		tailStart++
+	}
+	if i := strings.IndexByte(s[tailStart:], '\n'); i >= 0 && tailStart+i < len(s)-1 {
+		tailStart += i + 1
+	}
+
+	omitted := s[headEnd:tailStart]
+	note = fmt.Sprintf("[... %d lines (%d bytes) omitted. %s ...]", strings.Count(omitted, "\n"), len(omitted), note)
+	return s[:headEnd] + "\n" + note + "\n\n" + s[tailStart:]
+}
+
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+// outputStore keeps the outputs replaced by TruncateOutput, per chat. The
+// outputs are written to files in a temporary directory, as a chat can have
+// hundreds of megabytes of them.
+type outputStore struct {
+	mu     sync.Mutex
+	next   int
+	dir    string
+	byChat map[string][]*storedOutput
+}
+
+type storedOutput struct {
+	handle string
+	path   string
+}
+
+var outputs = &outputStore{byChat: map[string][]*storedOutput{}}
+
+// store keeps text for chatID, and returns its handle.
+func (o *outputStore) store(chatID, text string) (string, error) {
+	if len(text) > maxStoredOutput {
+		text = strings.ToValidUTF8(text[:maxStoredOutput], "")
+	}
+
+	o.mu.Lock()
+	if o.dir == "" {
+		dir, err := os.MkdirTemp("", "llum-outputs-")
+		if err != nil {
+			o.mu.Unlock()
+			return "", err
+		}
+		o.dir = dir
+	}
+	o.next++
+	out := &storedOutput{handle: "out-" + strconv.Itoa(o.next)}
+	out.path = filepath.Join(o.dir, out.handle+".txt")
+	o.mu.Unlock()
+
+	if err := os.WriteFile(out.path, []byte(text), 0o600); err != nil {
+		os.Remove(out.path)
+		return "", err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	stored := append(o.byChat[chatID], out)
+	if len(stored) > maxStoredOutputs {
+		for _, old := range stored[:len(stored)-maxStoredOutputs] {
+			os.Remove(old.path)
+		}
+		stored = stored[len(stored)-maxStoredOutputs:]
+	}
+	o.byChat[chatID] = stored
+	return out.handle, nil
+}
+
+// lines returns the lines of the output of chatID stored under handle.
+func (o *outputStore) lines(chatID, handle string) ([]string, error) {
+	var path string
+	o.mu.Lock()
+	for _, out := range o.byChat[chatID] {
+		if out.handle == handle {
+			path = out.path
+		}
+	}
+	o.mu.Unlock()
+
+	var data []byte
+	err := fs.ErrNotExist
+	if path != "" {
+		data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		// Unknown, or replaced since it was looked up.
+		return nil, fmt.Errorf("unknown output %q: it may have been replaced by newer outputs", handle)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// removeChat forgets the outputs of a chat.
+func (o *outputStore) removeChat(chatID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, out := range o.byChat[chatID] {
+		os.Remove(out.path)
+	}
+	delete(o.byChat, chatID)
+}
+
+// CloseOutputs deletes the outputs stored for ReadOutput.
+func CloseOutputs() {
+	outputs.mu.Lock()
+	defer outputs.mu.Unlock()
+	if outputs.dir != "" {
+		os.RemoveAll(outputs.dir)
+		outputs.dir = ""
+	}
+	outputs.byChat = map[string][]*storedOutput{}
+}
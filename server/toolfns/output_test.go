@@ -0,0 +1,115 @@
+package toolfns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateAndReadOutput(t *testing.T) {
+	t.Cleanup(CloseOutputs)
+	ws := &Workspace{ChatID: "output-test", Dir: t.TempDir()}
+
+	var b strings.Builder
+	for i := 1; b.Len() <= maxResultSize; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	text := b.String()
+	excerpt := truncateResult(ws.ChatID, text).(string)
+	if len(excerpt) >= len(text) || !strings.Contains(excerpt, `stored as "out-`) {
+		t.Fatalf("excerpt of %d bytes:\n%s", len(text), excerpt)
+	}
+
+	// Only the handle and path are kept in memory.
+	out := outputs.byChat[ws.ChatID][0]
+	if data, err := os.ReadFile(out.path); err != nil || string(data) != text {
+		t.Fatalf("stored output: %v", err)
+	}
+
+	page, err := ReadOutput(ws, out.handle, 2, 2, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Lines != "2: line 2\n3: line 3\n" || page.NextOffset != 4 || page.TotalLines != countLines(text) {
+		t.Errorf("ReadOutput = %+v", page)
+	}
+	page, err = ReadOutput(ws, out.handle, 1, 10, `^line 10\d$`, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Matches != 10 || !strings.HasPrefix(page.Lines, "99- line 99\n100: line 100\n") {
+		t.Errorf("ReadOutput with a pattern = %+v", page)
+	}
+	if _, err := ReadOutput(&Workspace{ChatID: "other"}, out.handle, 1, 10, "", 0); err == nil {
+		t.Error("read the output of another chat")
+	}
+}
+
+func TestOutputStoreEviction(t *testing.T) {
+	t.Cleanup(CloseOutputs)
+	chatID := "eviction-test"
+
+	var handles []string
+	for i := 0; i < maxStoredOutputs+2; i++ {
+		handle, err := outputs.store(chatID, fmt.Sprintf("output %d\n", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handles = append(handles, handle)
+	}
+	if _, err := outputs.lines(chatID, handles[0]); err == nil {
+		t.Error("the oldest output was kept")
+	}
+	if lines, err := outputs.lines(chatID, handles[len(handles)-1]); err != nil || lines[0] != fmt.Sprintf("output %d", maxStoredOutputs+1) {
+		t.Errorf("newest output = %q, %v", lines, err)
+	}
+	files, _ := filepath.Glob(filepath.Join(outputs.dir, "*.txt"))
+	if len(files) != maxStoredOutputs {
+		t.Errorf("%d files stored, want %d", len(files), maxStoredOutputs)
+	}
+
+	outputs.removeChat(chatID)
+	files, _ = filepath.Glob(filepath.Join(outputs.dir, "*.txt"))
+	if len(files) != 0 {
+		t.Errorf("files left after removing the chat: %v", files)
+	}
+}
+
+func TestTruncateEscapedString(t *testing.T) {
+	t.Cleanup(CloseOutputs)
+
+	// The JSON literal is larger than maxResultSize, but the string isn't,
+	// and is shorter than the excerpts.
+	s := strings.Repeat("\x01", outputExcerptSize-500)
+	out := truncateResult("escaped-test", map[string]string{"a": s, "b": s})
+	if data, ok := out.(json.RawMessage); ok && strings.Contains(string(data), "omitted") {
+		t.Errorf("short strings were truncated: %.200s", data)
+	}
+}
+
+func TestOutputExcerptRunes(t *testing.T) {
+	// Without line ends, and with the cuts in the middle of runes.
+	s := "x" + strings.Repeat("€", maxResultSize)
+	excerpt := outputExcerpt(s, "note")
+	if !utf8.ValidString(excerpt) {
+		t.Fatal("excerpt is not valid UTF-8")
+	}
+
+	head, rest, _ := strings.Cut(excerpt, "\n[... ")
+	_, tail, _ := strings.Cut(rest, "...]\n\n")
+	var lines, omitted int
+	if _, err := fmt.Sscanf(rest, "%d lines (%d bytes) omitted", &lines, &omitted); err != nil {
+		t.Fatal(err)
+	}
+	if len(head)+omitted+len(tail) != len(s) || !strings.HasPrefix(s, head) || !strings.HasSuffix(s, tail) {
+		t.Errorf("head of %d bytes, %d omitted and tail of %d don't add up to %d", len(head), omitted, len(tail), len(s))
+	}
+
+	if short := strings.Repeat("a", outputExcerptSize); outputExcerpt(short, "note") != short {
+		t.Error("a string shorter than the excerpts was cut")
+	}
+}
@@ -0,0 +1,257 @@
+package toolfns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PluginsConfig sets the WebAssembly modules that are loaded as tools.
+type PluginsConfig struct {
+	// Runtime is the WASI runtime that runs the modules. Defaults to
+	// wasmtime.
+	Runtime string `json:"runtime,omitempty"`
+	// Modules are the plugins, by the name of the tool group they add.
+	Modules map[string]PluginConfig `json:"modules,omitempty"`
+}
+
+// PluginConfig describes a WebAssembly module and what it may access.
+type PluginConfig struct {
+	// Path is the path of the .wasm file.
+	Path string `json:"path"`
+	// MemoryMB is the maximum size of the module's memory, in megabytes.
+	// Defaults to 256.
+	MemoryMB int `json:"memory_mb,omitempty"`
+	// Timeout is the maximum time a call may run. Defaults to 30s.
+	Timeout Duration `json:"timeout,omitempty"`
+	// Dirs are the host directories the module can access, by the path it
+	// sees them at, such as {"/data": "/srv/shared/data"}. The module can't
+	// access any other file.
+	Dirs map[string]string `json:"dirs,omitempty"`
+	// Workspace gives the module access to the chat's workspace, at
+	// /workspace.
+	Workspace bool `json:"workspace,omitempty"`
+	// Env are the environment variables of the module. Values are expanded.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// pluginManifest is printed by a module when run with the "manifest" argument.
+type pluginManifest struct {
+	Functions []pluginFunction `json:"functions"`
+}
+
+type pluginFunction struct {
+	Function
+	// Mutates is set by functions that change the files of the workspace,
+	// so that it is checkpointed before they run.
+	Mutates bool `json:"mutates,omitempty"`
+}
+
+// plugin runs the functions of a WebAssembly module. A function is called by
+// running the module with the arguments "call" and the name of the function,
+// and the JSON arguments on stdin. The module prints its result to stdout,
+// and exits with a non-zero status, after printing the error to stderr, when
+// the call fails.
+type plugin struct {
+	name   string
+	config PluginConfig
+}
+
+const (
+	maxPluginOutput    = 1024 * 1024
+	pluginWorkspaceDir = "/workspace"
+)
+
+var toolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// LoadPlugins reads the manifests of the configured WebAssembly modules, and
+// adds a tool group for each of them to ToolGroups.
+func LoadPlugins() error {
+	names := make([]string, 0, len(config.Plugins.Modules))
+	for name := range config.Plugins.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := &plugin{name: name, config: config.Plugins.Modules[name]}
+		g, err := p.group()
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", name, err)
+		}
+		for _, fn := range g.Schema {
+			for _, other := range ToolGroups {
+				if other.Has(fn.Name) {
+					return fmt.Errorf("plugin %s: %s is already a tool of the %s group", name, fn.Name, other.Name)
+				}
+			}
+		}
+		ToolGroups = append(ToolGroups, g)
+	}
+	return nil
+}
+
+// group builds the tool group of the plugin from its manifest.
+func (p *plugin) group() (*Group, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.timeout())
+	defer cancel()
+	out, err := p.run(ctx, nil, nil, "manifest")
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	var manifest pluginManifest
+	if err := json.Unmarshal(out, &manifest); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if len(manifest.Functions) == 0 {
+		return nil, errors.New("manifest: no functions")
+	}
+
+	g := &Group{
+		Name:      p.name,
+		functions: make(map[string]*function, len(manifest.Functions)),
+		plugin:    p,
+	}
+	for _, pfn := range manifest.Functions {
+		fn := pfn.Function
+		if !toolNameRegex.MatchString(fn.Name) {
+			return nil, fmt.Errorf("manifest: invalid function name %q", fn.Name)
+		}
+		if _, ok := g.functions[fn.Name]; ok {
+			return nil, fmt.Errorf("manifest: duplicate function %s", fn.Name)
+		}
+
+		f := &function{schema: fn, mutates: pfn.Mutates}
+		if fn.Parameters.Type != "" && fn.Parameters.Type != "object" {
+			return nil, fmt.Errorf("manifest: %s: parameters must be an object", fn.Name)
+		}
+		required := map[string]bool{}
+		for _, name := range fn.Parameters.Required {
+			required[name] = true
+		}
+		for _, prop := range fn.Parameters.Properties {
+			prop.optional = !required[prop.Name]
+			f.params = append(f.params, param{name: prop.Name, def: prop.Definition})
+		}
+
+		g.Schema = append(g.Schema, fn)
+		g.functions[fn.Name] = f
+	}
+	return g, nil
+}
+
+// call runs the function fn of the plugin, with arguments already prepared by
+// fn. Results that are valid JSON are returned as is, anything else as text.
+func (p *plugin) call(ws *Workspace, fn *function, args map[string]any) (any, error) {
+	input, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.timeout())
+	defer cancel()
+	out, err := p.run(ctx, ws, input, "call", fn.schema.Name)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s timed out after %s", fn.schema.Name, p.config.timeout())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if json.Valid(out) {
+		return json.RawMessage(out), nil
+	}
+	return string(out), nil
+}
+
+// run runs the module in the WASI runtime, with the directories it was
+// granted, and returns what it printed to stdout.
+func (p *plugin) run(ctx context.Context, ws *Workspace, stdin []byte, args ...string) ([]byte, error) {
+	runtime := config.Plugins.runtime()
+	runtimeArgs := []string{
+		"run",
+		"-W", "max-memory-size=" + strconv.Itoa(p.config.memoryMB()<<20),
+	}
+
+	guests := make([]string, 0, len(p.config.Dirs))
+	for guest := range p.config.Dirs {
+		guests = append(guests, guest)
+	}
+	sort.Strings(guests)
+	for _, guest := range guests {
+		host, err := filepath.Abs(p.config.Dirs[guest])
+		if err != nil {
+			return nil, err
+		}
+		runtimeArgs = append(runtimeArgs, "--dir", host+"::"+guest)
+	}
+	if p.config.Workspace && ws != nil {
+		runtimeArgs = append(runtimeArgs, "--dir", ws.Dir+"::"+pluginWorkspaceDir)
+	}
+
+	keys := make([]string, 0, len(p.config.Env))
+	for key := range p.config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		runtimeArgs = append(runtimeArgs, "--env", key+"="+os.ExpandEnv(p.config.Env[key]))
+	}
+	runtimeArgs = append(runtimeArgs, p.config.Path)
+	runtimeArgs = append(runtimeArgs, args...)
+
+	cmd := exec.CommandContext(ctx, runtime, runtimeArgs...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{max: maxPluginOutput}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("output is larger than %d KB", maxPluginOutput>>10)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (c PluginsConfig) runtime() string {
+	if c.Runtime == "" {
+		return "wasmtime"
+	}
+	return c.Runtime
+}
+
+func (c PluginConfig) memoryMB() int {
+	if c.MemoryMB <= 0 {
+		return 256
+	}
+	return c.MemoryMB
+}
+
+func (c PluginConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Timeout)
+}
@@ -0,0 +1,228 @@
+package toolfns
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRuntime stands in for wasmtime. It logs its arguments, skips the options
+// up to the module, and then prints the manifest next to it, or answers calls:
+// Fail fails, Text prints text, and any other function echoes its name and
+// arguments as JSON.
+const fakeRuntime = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/runtime.log"
+while [ "$1" != "$dir/plugin.wasm" ]; do shift; done
+shift
+case "$1 $2" in
+manifest*) cat "$dir/manifest.json" ;;
+"call Fail") echo "something broke" >&2; exit 1 ;;
+"call Text") echo "plain text" ;;
+call*) printf '{"tool":"%s","args":' "$2"; cat; printf '}' ;;
+esac
+`
+
+// newFakePlugin returns a plugin run by fakeRuntime, with the given manifest,
+// and the path of the log of the runtime.
+func newFakePlugin(t *testing.T, manifest string, pc PluginConfig) (*plugin, string) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	dir := t.TempDir()
+	runtime := filepath.Join(dir, "wasmtime")
+	if err := os.WriteFile(runtime, []byte(fakeRuntime), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := config.Plugins
+	config.Plugins = PluginsConfig{Runtime: runtime}
+	t.Cleanup(func() { config.Plugins = old })
+
+	pc.Path = filepath.Join(dir, "plugin.wasm")
+	return &plugin{name: "fake", config: pc}, filepath.Join(dir, "runtime.log")
+}
+
+func TestPropertiesUnmarshalJSON(t *testing.T) {
+	var def Definition
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"zeta": {"type": "string", "enum": ["a", "b"]},
+			"alpha": {"type": "object", "properties": {"y": {"type": "integer"}, "x": {"type": "number", "maximum": 3}}},
+			"mid": {"type": "array", "items": {"type": "string"}, "minItems": 1}
+		},
+		"required": ["zeta"]
+	}`), &def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range def.Properties {
+		names = append(names, p.Name)
+	}
+	if want := []string{"zeta", "alpha", "mid"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(def.Properties[0].Enum, []any{"a", "b"}) {
+		t.Errorf("zeta = %+v", def.Properties[0].Definition)
+	}
+	nested := def.Properties[1].Properties
+	if len(nested) != 2 || nested[0].Name != "y" || nested[1].Name != "x" || *nested[1].Maximum != 3 {
+		t.Errorf("alpha properties = %+v", nested)
+	}
+	if mid := def.Properties[2]; mid.Items == nil || mid.Items.Type != "string" || *mid.MinItems != 1 {
+		t.Errorf("mid = %+v", mid.Definition)
+	}
+
+	// The order is kept when the schema is sent to the model.
+	data, err := json.Marshal(def.Properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Index(s, `"zeta"`) > strings.Index(s, `"alpha"`) || strings.Index(s, `"alpha"`) > strings.Index(s, `"mid"`) {
+		t.Errorf("marshaled properties = %s", s)
+	}
+
+	if err := json.Unmarshal([]byte(`{"properties": ["a"]}`), &def); err == nil {
+		t.Error("properties that aren't an object were accepted")
+	}
+}
+
+func TestPluginGroup(t *testing.T) {
+	p, log := newFakePlugin(t, `{"functions": [
+		{
+			"name": "Echo",
+			"description": "Echoes its arguments.",
+			"parameters": {
+				"type": "object",
+				"properties": {
+					"text": {"type": "string"},
+					"times": {"type": "integer", "minimum": 1, "maximum": 3, "default": 1}
+				},
+				"required": ["text"]
+			}
+		},
+		{"name": "Write", "description": "Writes a file.", "mutates": true},
+		{"name": "Text", "description": "Prints text."},
+		{"name": "Fail", "description": "Fails."}
+	]}`, PluginConfig{
+		Workspace: true,
+		Dirs:      map[string]string{"/data": "/srv/data"},
+		Env:       map[string]string{"GREETING": "hello"},
+	})
+
+	g, err := p.group()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fn := range g.Schema {
+		names = append(names, fn.Name)
+	}
+	if want := []string{"Echo", "Write", "Text", "Fail"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Schema = %v, want %v", names, want)
+	}
+	if g.Mutates("Echo") || !g.Mutates("Write") {
+		t.Errorf("Mutates(Echo) = %v, Mutates(Write) = %v", g.Mutates("Echo"), g.Mutates("Write"))
+	}
+
+	ws := &Workspace{ChatID: "test", Dir: t.TempDir()}
+	invoke := func(name string, args map[string]any) (any, error) {
+		return g.Invoke(context.Background(), ws, "call1", name, args)
+	}
+
+	out, err := invoke("Echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echo struct {
+		Tool string
+		Args map[string]any
+	}
+	if raw, ok := out.(json.RawMessage); !ok {
+		t.Fatalf("Echo returned %T", out)
+	} else if err := json.Unmarshal(raw, &echo); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"text": "hi", "times": 1.0}; echo.Tool != "Echo" || !reflect.DeepEqual(echo.Args, want) {
+		t.Errorf("Echo = %+v, want the defaults filled in", echo)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	call := lines[len(lines)-1]
+	for _, arg := range []string{"--dir /srv/data::/data", "--dir " + ws.Dir + "::/workspace", "--env GREETING=hello", "call Echo"} {
+		if !strings.Contains(call, arg) {
+			t.Errorf("runtime arguments %q don't contain %q", call, arg)
+		}
+	}
+
+	if _, err := invoke("Echo", map[string]any{"times": 2}); err == nil || err.Error() != "missing argument: text" {
+		t.Errorf("Echo without text: %v", err)
+	}
+	if _, err := invoke("Echo", map[string]any{"text": "hi", "times": 5}); err == nil || !strings.Contains(err.Error(), "must be at most 3") {
+		t.Errorf("Echo with too many times: %v", err)
+	}
+	if data, _ := os.ReadFile(log); strings.Count(string(data), "\n") != len(lines) {
+		t.Error("the module was run with invalid arguments")
+	}
+
+	if out, err := invoke("Text", nil); err != nil || out != "plain text\n" {
+		t.Errorf("Text = %#v, %v", out, err)
+	}
+	if _, err := invoke("Fail", nil); err == nil || err.Error() != "something broke" {
+		t.Errorf("Fail: %v", err)
+	}
+	if _, err := invoke("Missing", nil); err == nil || err.Error() != "tool not found: Missing" {
+		t.Errorf("Missing: %v", err)
+	}
+}
+
+func TestPluginManifestErrors(t *testing.T) {
+	tests := []struct {
+		manifest string
+		err      string
+	}{
+		{`not json`, "manifest: invalid character"},
+		{`{"functions": []}`, "manifest: no functions"},
+		{`{"functions": [{"name": "Echo"}, {"name": "Echo"}]}`, "manifest: duplicate function Echo"},
+		{`{"functions": [{"name": "has space"}]}`, `manifest: invalid function name "has space"`},
+		{`{"functions": [{"name": ""}]}`, `manifest: invalid function name ""`},
+		{`{"functions": [{"name": "Echo", "parameters": {"type": "string"}}]}`, "manifest: Echo: parameters must be an object"},
+		{`{"functions": [{"name": "Echo", "parameters": {"properties": []}}]}`, "properties must be an object"},
+	}
+	for _, tt := range tests {
+		p, _ := newFakePlugin(t, tt.manifest, PluginConfig{})
+		if _, err := p.group(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("manifest %s: %v, want an error containing %q", tt.manifest, err, tt.err)
+		}
+	}
+}
+
+func TestLoadPluginsConflict(t *testing.T) {
+	p, _ := newFakePlugin(t, `{"functions": [{"name": "Shell"}]}`, PluginConfig{})
+	config.Plugins.Modules = map[string]PluginConfig{"fake": p.config}
+
+	groups := len(ToolGroups)
+	err := LoadPlugins()
+	if err == nil || err.Error() != "plugin fake: Shell is already a tool of the System group" {
+		t.Errorf("LoadPlugins with a taken name: %v", err)
+	}
+	if len(ToolGroups) != groups {
+		t.Error("the plugin was added")
+	}
+}
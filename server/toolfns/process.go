@@ -0,0 +1,331 @@
+package toolfns
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type ProcessInfo struct {
+	Name     string     `json:"name"`
+	Command  string     `json:"command"`
+	PID      int        `json:"pid"`
+	Running  bool       `json:"running"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	Started  time.Time  `json:"started"`
+	Stopped  *time.Time `json:"stopped,omitempty"`
+	// LogSize is the offset just past the end of the log.
+	LogSize int64 `json:"log_size"`
+}
+
+type ProcessOutput struct {
+	// Output is the log from Offset to NextOffset.
+	Output     string `json:"output"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	// Dropped reports that the start of the requested log was discarded, as
+	// only the most recent output is kept.
+	Dropped bool `json:"dropped,omitempty"`
+	Running bool `json:"running"`
+}
+
+const (
+	// maxProcessLog is the number of bytes of output kept per process.
+	maxProcessLog = 1024 * 1024
+	// processStopTimeout is how long a process is given to exit after being
+	// asked to, before it is killed.
+	processStopTimeout = 5 * time.Second
+)
+
+var processNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// Starts a command in the background in the workspace, such as a dev server or a file watcher, and returns immediately. Use ProcessLogs to read its output.
+// @mutates
+// name: A name for the process, used to refer to it later. @example dev-server
+// command: The bash command to run.
+func StartProcess(ws *Workspace, name string, command string) (*ProcessInfo, error) {
+	if !processNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid process name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return processes.start(ws, name, command)
+}
+
+// Returns the output of a background process, starting from an offset. Pass the next_offset of the previous call to only get new output.
+// name: The name of the process.
+// offset: The offset to start from. Negative offsets count from the end of the log. @default -4096
+// limit: Maximum number of bytes to return. @min 1 @max 65536 @default 16384
+func ProcessLogs(ws *Workspace, name string, offset int, limit int) (*ProcessOutput, error) {
+	p, err := processes.get(ws.ChatID, name)
+	if err != nil {
+		return nil, err
+	}
+	return p.logs(int64(offset), limit), nil
+}
+
+// Lists the background processes of this chat, or returns the status of one of them.
+// name: The name of the process. Lists all processes if empty. @optional
+func ProcessStatus(ws *Workspace, name string) ([]*ProcessInfo, error) {
+	if name != "" {
+		p, err := processes.get(ws.ChatID, name)
+		if err != nil {
+			return nil, err
+		}
+		return []*ProcessInfo{p.info()}, nil
+	}
+	return processes.list(ws.ChatID), nil
+}
+
+// Stops a background process, killing it if it doesn't exit within a few seconds.
+// name: The name of the process.
+func StopProcess(ws *Workspace, name string) (*ProcessInfo, error) {
+	p, err := processes.get(ws.ChatID, name)
+	if err != nil {
+		return nil, err
+	}
+	p.stop()
+	return p.info(), nil
+}
+
+// StopProcesses stops the background processes of all chats, and waits for
+// them to exit.
+func StopProcesses() {
+	processes.mu.Lock()
+	chatIDs := make([]string, 0, len(processes.chats))
+	for chatID := range processes.chats {
+		chatIDs = append(chatIDs, chatID)
+	}
+	processes.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, chatID := range chatIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			processes.stopChat(chatID)
+		}()
+	}
+	wg.Wait()
+}
+
+// processManager tracks the background processes of each chat.
+type processManager struct {
+	mu    sync.Mutex
+	chats map[string]map[string]*process
+}
+
+var processes = &processManager{chats: map[string]map[string]*process{}}
+
+func (m *processManager) start(ws *Workspace, name, command string) (*ProcessInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	procs := m.chats[ws.ChatID]
+	if procs == nil {
+		procs = map[string]*process{}
+		m.chats[ws.ChatID] = procs
+	}
+	if p, ok := procs[name]; ok && p.running() {
+		return nil, fmt.Errorf("process %s is already running", name)
+	}
+
+	p := &process{
+		name:    name,
+		command: command,
+		log:     &processLog{max: maxProcessLog},
+		done:    make(chan struct{}),
+	}
+	p.cmd = exec.Command("bash", "-c", command)
+	p.cmd.Dir = ws.Dir
+	p.cmd.Stdout = p.log
+	p.cmd.Stderr = p.log
+	setProcessGroup(p.cmd)
+	if err := p.cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.started = time.Now()
+	procs[name] = p
+
+	go func() {
+		err := p.cmd.Wait()
+		p.mu.Lock()
+		now := time.Now()
+		p.stopped = &now
+		code := p.cmd.ProcessState.ExitCode()
+		p.exitCode = &code
+		p.mu.Unlock()
+		if err != nil && !errors.As(err, new(*exec.ExitError)) {
+			p.log.Write([]byte("\n" + err.Error() + "\n"))
+		}
+		close(p.done)
+	}()
+
+	return p.info(), nil
+}
+
+func (m *processManager) get(chatID, name string) (*process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.chats[chatID][name]
+	if !ok {
+		return nil, fmt.Errorf("no process named %s", name)
+	}
+	return p, nil
+}
+
+func (m *processManager) list(chatID string) []*ProcessInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := []*ProcessInfo{}
+	for _, p := range m.chats[chatID] {
+		infos = append(infos, p.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Started.Before(infos[j].Started) })
+	return infos
+}
+
+// stopChat stops the processes of a chat and forgets them.
+func (m *processManager) stopChat(chatID string) {
+	m.mu.Lock()
+	procs := m.chats[chatID]
+	delete(m.chats, chatID)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.stop()
+		}()
+	}
+	wg.Wait()
+}
+
+type process struct {
+	name    string
+	command string
+	cmd     *exec.Cmd
+	log     *processLog
+	started time.Time
+	done    chan struct{}
+
+	mu       sync.Mutex
+	stopped  *time.Time
+	exitCode *int
+}
+
+func (p *process) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop asks the process to exit, kills it if it doesn't exit in time, and
+// waits for it.
+func (p *process) stop() {
+	if !p.running() {
+		return
+	}
+	terminateProcess(p.cmd, false)
+	select {
+	case <-p.done:
+	case <-time.After(processStopTimeout):
+		terminateProcess(p.cmd, true)
+		<-p.done
+	}
+}
+
+func (p *process) info() *ProcessInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &ProcessInfo{
+		Name:     p.name,
+		Command:  p.command,
+		PID:      p.cmd.Process.Pid,
+		Running:  p.running(),
+		ExitCode: p.exitCode,
+		Started:  p.started,
+		Stopped:  p.stopped,
+		LogSize:  p.log.size(),
+	}
+}
+
+func (p *process) logs(offset int64, limit int) *ProcessOutput {
+	data, start, dropped := p.log.read(offset, limit)
+	return &ProcessOutput{
+		Output:     strings.ToValidUTF8(string(data), "\uFFFD"),
+		Offset:     start,
+		NextOffset: start + int64(len(data)),
+		Dropped:    dropped,
+		Running:    p.running(),
+	}
+}
+
+// processLog keeps the most recent output written to it, up to max bytes, and
+// the offset of its first byte in the whole output.
+type processLog struct {
+	mu    sync.Mutex
+	max   int
+	data  []byte
+	start int64
+}
+
+func (l *processLog) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data = append(l.data, b...)
+	if len(l.data) > l.max {
+		// Drop a quarter more than needed, so that the log isn't copied on
+		// every write.
+		drop := len(l.data) - l.max*3/4
+		l.data = append(l.data[:0:0], l.data[drop:]...)
+		l.start += int64(drop)
+	}
+	return len(b), nil
+}
+
+func (l *processLog) size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.start + int64(len(l.data))
+}
+
+// read returns up to limit bytes of the log from offset, the offset they
+// actually start at, and whether output before it was dropped. Negative
+// offsets count from the end.
+func (l *processLog) read(offset int64, limit int) ([]byte, int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	end := l.start + int64(len(l.data))
+	if offset < 0 {
+		offset = max(0, end+offset)
+	}
+	dropped := offset < l.start
+	offset = min(max(offset, l.start), end)
+
+	data := l.data[offset-l.start:]
+	if len(data) > limit {
+		data = data[:limit]
+	}
+	// Don't split characters at either end.
+	for len(data) > 0 && !utf8.RuneStart(data[0]) {
+		data = data[1:]
+		offset++
+	}
+	if len(data) == limit {
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	return append([]byte(nil), data...), offset, dropped
+}
@@ -0,0 +1,11 @@
+package toolfns
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills cmd. Windows has no way to ask a process to exit, so
+// force is ignored.
+func terminateProcess(cmd *exec.Cmd, force bool) {
+	cmd.Process.Kill()
+}
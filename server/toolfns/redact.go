@@ -0,0 +1,175 @@
+package toolfns
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactionConfig sets how secrets are removed from the results of tools.
+type RedactionConfig struct {
+	// Disabled turns redaction off.
+	Disabled bool `json:"disabled,omitempty"`
+	// Secrets are literal values to remove, such as "${DEPLOY_TOKEN}".
+	// Environment variables are expanded. The values of the HTTP secrets are
+	// always removed.
+	Secrets []string `json:"secrets,omitempty"`
+}
+
+// secretPattern matches a kind of secret. When the pattern has a group named
+// "secret", only that group is replaced.
+type secretPattern struct {
+	kind  string
+	regex *regexp.Regexp
+}
+
+// envSecretName matches the start of a line that assigns a variable whose name
+// says it holds a secret.
+const envSecretName = `(?m)^[ \t]*(?:export[ \t]+)?[A-Z0-9_]*(?:KEY|TOKEN|SECRET|PASSWORD|PASSWD)[A-Z0-9_]*=`
+
+var secretPatterns = []secretPattern{
+	// Keys cut short, as by head, are removed up to the last line of base64.
+	{"private key", regexp.MustCompile(`(?m)-----BEGIN[A-Z ]* PRIVATE KEY-----(?:[A-Za-z0-9+/=:,\s-]*?-----END[A-Z ]* PRIVATE KEY-----|(?:\r?\n[A-Za-z0-9+/=]+\r?$)*)`)},
+	{"anthropic key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openrouter key", regexp.MustCompile(`\bsk-or-[A-Za-z0-9_-]{20,}`)},
+	{"openai key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"groq key", regexp.MustCompile(`\bgsk_[A-Za-z0-9]{20,}`)},
+	{"aws access key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	// Keys without a recognizable format, such as Mistral's or AWS secret
+	// keys, are caught by the name they are assigned to, in lines such as
+	// those of the output of env or of .env files. Values that look like code,
+	// such as process.env.API_KEY or hash(password);, are left alone.
+	{"secret", regexp.MustCompile(envSecretName + `(?P<secret>[^\s"'\\$.(][^\s"'\\.(]{6,}[^\s"'\\.(;])[ \t]*\r?$`)},
+	// Quoted values may have spaces.
+	{"secret", regexp.MustCompile(envSecretName + `["'](?P<secret>[^\s"'\\$.(][^"'\r\n\\.(]{6,}[^\s"'\\.(;])["'][ \t]*\r?$`)},
+}
+
+// RedactSecrets is a hook that removes secrets from the results and errors of
+// tools, and counts them in Call.Redactions.
+func RedactSecrets(next Invoker) Invoker {
+	return func(call *Call) (any, error) {
+		out, err := next(call)
+		if config.Redaction.Disabled {
+			return out, err
+		}
+
+		out, n := Redact(out)
+		call.Redactions += n
+		if err != nil {
+			msg, n := redactString(err.Error())
+			if n > 0 {
+				call.Redactions += n
+				err = errors.New(msg)
+			}
+		}
+		return out, err
+	}
+}
+
+// Redact removes secrets from v, and returns the result along with the
+// number of secrets removed. Strings are redacted as is; other values are
+// redacted in their JSON encoding, and returned as json.RawMessage when
+// anything was removed.
+func Redact(v any) (any, int) {
+	switch v := v.(type) {
+	case nil:
+		return nil, 0
+	case string:
+		return redactString(v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v, 0
+	}
+	total := 0
+	redacted := jsonStringRegex.ReplaceAllFunc(data, func(lit []byte) []byte {
+		var s string
+		if err := json.Unmarshal(lit, &s); err != nil {
+			return lit
+		}
+		s, n := redactString(s)
+		if n == 0 {
+			return lit
+		}
+		total += n
+		quoted, _ := json.Marshal(s)
+		return quoted
+	})
+	if total == 0 {
+		return v, 0
+	}
+	return json.RawMessage(redacted), total
+}
+
+var jsonStringRegex = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// redactString replaces the secrets in s with a placeholder naming their kind.
+func redactString(s string) (string, int) {
+	n := 0
+	for _, literal := range literalSecrets() {
+		if c := strings.Count(s, literal); c > 0 {
+			n += c
+			s = strings.ReplaceAll(s, literal, "[REDACTED secret]")
+		}
+	}
+
+	for _, p := range secretPatterns {
+		group := p.regex.SubexpIndex("secret")
+		s = replaceAllSubmatchFunc(p.regex, s, func(s string, m []int) string {
+			n++
+			placeholder := "[REDACTED " + p.kind + "]"
+			if group < 0 || m[2*group] < 0 {
+				return placeholder
+			}
+			return s[m[0]:m[2*group]] + placeholder + s[m[2*group+1]:m[1]]
+		})
+	}
+	return s, n
+}
+
+// replaceAllSubmatchFunc is regexp.ReplaceAllStringFunc, with s and the
+// indexes of the submatches in it passed to repl.
+func replaceAllSubmatchFunc(re *regexp.Regexp, s string, repl func(s string, m []int) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		b.WriteString(repl(s, m))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// literalSecrets returns the configured secret values, longest first, so
+// that a secret containing another is replaced whole.
+func literalSecrets() []string {
+	var secrets []string
+	for _, s := range config.Redaction.Secrets {
+		if s = os.ExpandEnv(s); s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+	for _, secret := range config.HTTP.Secrets {
+		value := os.ExpandEnv(secret.Value)
+		if value == "" {
+			continue
+		}
+		secrets = append(secrets, value)
+		// Also remove the token of values such as "Bearer <token>".
+		if _, token, ok := strings.Cut(value, " "); ok && len(token) >= 8 {
+			secrets = append(secrets, token)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
@@ -0,0 +1,52 @@
+package toolfns
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactString(t *testing.T) {
+	redacted := []struct {
+		in   string
+		want string
+	}{
+		{"MISTRAL_API_KEY=abcdef1234567890\n", "MISTRAL_API_KEY=[REDACTED secret]\n"},
+		{"HOME=/root\nGITHUB_TOKEN=abcdef12345678\nSHELL=/bin/sh", "HOME=/root\nGITHUB_TOKEN=[REDACTED secret]\nSHELL=/bin/sh"},
+		{`export DB_PASSWORD="correct horse battery"`, `export DB_PASSWORD="[REDACTED secret]"`},
+		{"AWS_SECRET_ACCESS_KEY='wJalrXUtnFEMI/K7MDENG/bPxRfiCY'\r\n", "AWS_SECRET_ACCESS_KEY='[REDACTED secret]'\r\n"},
+		{"key: sk-ant-REDACTED", "key: [REDACTED anthropic key]"},
+	}
+	for _, tt := range redacted {
+		got, n := redactString(tt.in)
+		if got != tt.want || n != 1 {
+			t.Errorf("redactString(%q) = %q, %d, want %q, 1", tt.in, got, n, tt.want)
+		}
+	}
+
+	kept := []string{
+		"const apiKey = process.env.OPENAI_API_KEY;",
+		"  password: hashPassword(input),",
+		"OPENAI_API_KEY=process.env.OPENAI_API_KEY",
+		"API_KEY=readSecret(\"api\")",
+		"DEFAULT_TOKEN=tokenFromEnvironment;",
+		"GITHUB_TOKEN=${GITHUB_TOKEN}",
+		"GITHUB_TOKEN=short",
+		"github_token=abcdef1234567890",
+		"secret = os.environ['SECRET_KEY']",
+		`if token == "" { return errMissingToken }`,
+		"MAX_TOKENS=4096 # and more",
+	}
+	for _, in := range kept {
+		if got, n := redactString(in); n != 0 {
+			t.Errorf("redactString(%q) = %q, want it unchanged", in, got)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	out, n := Redact(map[string]any{"stdout": "USER=me\nAPI_TOKEN=abcdef1234567890\n", "exit_code": 0})
+	if n != 1 || strings.Contains(string(out.(json.RawMessage)), "abcdef") {
+		t.Errorf("Redact = %s, %d", out, n)
+	}
+}
@@ -0,0 +1,540 @@
+package toolfns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/byte-sat/llum-tools/schema"
+	"github.com/noonien/codoc"
+)
+
+// Function is the schema of a single tool, as sent to the model.
+type Function struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Parameters  Definition `json:"parameters,omitempty"`
+}
+
+func (f Function) MarshalJSON() ([]byte, error) {
+	type alias Function
+	tool := struct {
+		Type     string `json:"type"`
+		Function alias  `json:"function"`
+	}{Type: "function", Function: alias(f)}
+	return json.Marshal(tool)
+}
+
+// Definition describes a JSON Schema. It mirrors schema.Definition, with the
+// additional keywords that can be set through doc comment annotations.
+type Definition struct {
+	Type                 schema.Type `json:"type,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Enum                 []any       `json:"enum,omitempty"`
+	Default              any         `json:"default,omitempty"`
+	Format               string      `json:"format,omitempty"`
+	Examples             []any       `json:"examples,omitempty"`
+	Minimum              *float64    `json:"minimum,omitempty"`
+	Maximum              *float64    `json:"maximum,omitempty"`
+	MinLength            *int        `json:"minLength,omitempty"`
+	MaxLength            *int        `json:"maxLength,omitempty"`
+	MinItems             *int        `json:"minItems,omitempty"`
+	MaxItems             *int        `json:"maxItems,omitempty"`
+	Properties           Properties  `json:"properties,omitempty"`
+	Required             []string    `json:"required,omitempty"`
+	Items                *Definition `json:"items,omitempty"`
+	AdditionalProperties *Definition `json:"additionalProperties,omitempty"`
+
+	// optional is set when the definition is annotated with @optional or
+	// @default, and controls whether it is listed in its parent's Required.
+	optional bool
+}
+
+// Properties are the properties of an object, encoded in declaration order.
+type Properties []Property
+
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteString(":")
+		def, err := json.Marshal(prop.Definition)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(def)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads the properties of an object in the order they are
+// written, such as those of plugin manifests.
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("properties must be an object")
+	}
+
+	*p = Properties{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		def := &Definition{}
+		if err := dec.Decode(def); err != nil {
+			return fmt.Errorf("%s: %w", tok, err)
+		}
+		*p = append(*p, Property{Name: tok.(string), Definition: def})
+	}
+	return nil
+}
+
+type Property struct {
+	Name string
+	*Definition
+}
+
+// param is a single argument of a tool function.
+type param struct {
+	name string
+	// typ is nil for the arguments of plugins, which are passed on as JSON.
+	typ reflect.Type
+	def *Definition
+}
+
+// function holds what is needed to prepare the arguments of a tool call.
+type function struct {
+	schema Function
+	params []param
+	// mutates is set for tools that may change the files of a workspace,
+	// which are annotated with @mutates in their doc comment.
+	mutates bool
+}
+
+var (
+	argRegex        = regexp.MustCompile(`(?m)^([a-zA-Z_][a-zA-Z0-9_]*): (.+)$`)
+	mutatesRegex    = regexp.MustCompile(`(?m)\s*^@mutates$`)
+	annotationRegex = regexp.MustCompile(`(?:^|\s)@(enum|default|optional|min|max|format|example)\b`)
+)
+
+// newFunction builds the schema of fn. The arguments are taken from the schema
+// generated by llum-tools, which already skips injected parameters; their
+// descriptions are then parsed for annotations, such as:
+//
+//	// format: Output format. @enum json, markdown @default markdown
+//	// limit: Maximum number of results. @min 1 @max 100 @optional
+//	// url: Address of the page. @format uri @example https://example.com
+//
+// A line with only @mutates, before the arguments, marks a tool that may
+// change the files of the workspace.
+func newFunction(fn any, generated schema.Function) (*function, error) {
+	fnt := reflect.TypeOf(fn)
+	fullName := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	doc := codoc.GetFunction(fullName)
+	if doc == nil {
+		return nil, fmt.Errorf("%s: missing codoc documentation", fullName)
+	}
+
+	argDescs := make(map[string]string)
+	for _, match := range argRegex.FindAllStringSubmatch(doc.Doc, -1) {
+		argDescs[match[1]] = match[2]
+	}
+
+	f := &function{
+		schema: Function{
+			Name:        generated.Name,
+			Description: mutatesRegex.ReplaceAllString(generated.Description, ""),
+		},
+		mutates: mutatesRegex.MatchString(generated.Description),
+	}
+
+	props := generated.Parameters.Properties
+	if len(props) == 0 {
+		return f, nil
+	}
+
+	params := Definition{Type: schema.Object}
+	offset := fnt.NumIn() - len(props)
+	for i, prop := range props {
+		typ := fnt.In(offset + i)
+		def, err := typeDefinition(typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", generated.Name, prop.Name, err)
+		}
+		if err := def.annotate(argDescs[prop.Name]); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", generated.Name, prop.Name, err)
+		}
+
+		params.Properties = append(params.Properties, Property{Name: prop.Name, Definition: def})
+		if !def.optional {
+			params.Required = append(params.Required, prop.Name)
+		}
+		f.params = append(f.params, param{name: prop.Name, typ: typ, def: def})
+	}
+	f.schema.Parameters = params
+
+	return f, nil
+}
+
+// prepare fills in omitted optional arguments, reports missing required ones,
+// and checks the given ones against their annotated constraints. Unknown
+// arguments are left for llum-tools to report.
+func (f *function) prepare(args map[string]any) (map[string]any, error) {
+	prepared := make(map[string]any, len(args))
+	for name, val := range args {
+		prepared[name] = val
+	}
+
+	for _, p := range f.params {
+		val, ok := prepared[p.name]
+		if !ok {
+			if !p.def.optional {
+				return nil, fmt.Errorf("missing argument: %s", p.name)
+			}
+			if p.def.Default != nil {
+				prepared[p.name] = p.def.Default
+			} else if p.typ != nil {
+				prepared[p.name] = reflect.Zero(p.typ).Interface()
+			}
+			continue
+		}
+
+		val = p.def.applyDefaults(val)
+		if err := p.def.validate(p.name, val); err != nil {
+			return nil, err
+		}
+		prepared[p.name] = val
+	}
+
+	return prepared, nil
+}
+
+func typeDefinition(t reflect.Type) (*Definition, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeDefinition(t.Elem())
+
+	case reflect.Bool:
+		return &Definition{Type: schema.Boolean}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Definition{Type: schema.Integer}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &Definition{Type: schema.Number}, nil
+
+	case reflect.String:
+		return &Definition{Type: schema.String}, nil
+
+	case reflect.Struct:
+		def := &Definition{Type: schema.Object}
+		cs := codoc.GetStruct(t.PkgPath() + "." + t.Name())
+		if cs != nil {
+			def.Description = cs.Doc
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, omitempty := fieldName(f)
+			if name == "" {
+				continue
+			}
+
+			fdef, err := typeDefinition(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+
+			if cs != nil {
+				doc := cs.Fields[f.Name].Doc
+				if doc == "" {
+					doc = cs.Fields[f.Name].Comment
+				}
+				if err := fdef.annotate(strings.Join(strings.Fields(doc), " ")); err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+				}
+			}
+			if omitempty {
+				fdef.optional = true
+			}
+
+			def.Properties = append(def.Properties, Property{Name: name, Definition: fdef})
+			if !fdef.optional {
+				def.Required = append(def.Required, name)
+			}
+		}
+		return def, nil
+
+	case reflect.Array, reflect.Slice:
+		items, err := typeDefinition(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Definition{Type: schema.Array, Items: items}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys must be strings")
+		}
+		values, err := typeDefinition(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Definition{Type: schema.Object, AdditionalProperties: values}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported argument type %s", t.Kind())
+	}
+}
+
+// annotate sets the description of d, and applies any annotations found in it.
+func (d *Definition) annotate(desc string) error {
+	if desc == "" {
+		return nil
+	}
+
+	matches := annotationRegex.FindAllStringSubmatchIndex(desc, -1)
+	if len(matches) == 0 {
+		d.Description = desc
+		return nil
+	}
+
+	d.Description = strings.TrimSpace(desc[:matches[0][0]])
+	for i, m := range matches {
+		end := len(desc)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		key := desc[m[2]:m[3]]
+		value := strings.TrimSpace(desc[m[1]:end])
+
+		if err := d.apply(key, value); err != nil {
+			return fmt.Errorf("@%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (d *Definition) apply(key, value string) error {
+	switch key {
+	case "optional":
+		d.optional = true
+
+	case "default":
+		v, err := d.parseValue(value)
+		if err != nil {
+			return err
+		}
+		d.Default = v
+		d.optional = true
+
+	case "enum":
+		for _, s := range strings.Split(value, ",") {
+			v, err := d.parseValue(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			d.Enum = append(d.Enum, v)
+		}
+
+	case "example":
+		v, err := d.parseValue(value)
+		if err != nil {
+			return err
+		}
+		d.Examples = append(d.Examples, v)
+
+	case "format":
+		d.Format = value
+
+	case "min", "max":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		switch d.Type {
+		case schema.Integer, schema.Number:
+			if key == "min" {
+				d.Minimum = &n
+			} else {
+				d.Maximum = &n
+			}
+		case schema.String:
+			if key == "min" {
+				d.MinLength = ptr(int(n))
+			} else {
+				d.MaxLength = ptr(int(n))
+			}
+		case schema.Array:
+			if key == "min" {
+				d.MinItems = ptr(int(n))
+			} else {
+				d.MaxItems = ptr(int(n))
+			}
+		default:
+			return fmt.Errorf("not supported for type %s", d.Type)
+		}
+	}
+
+	return nil
+}
+
+// parseValue parses an annotation value. Strings may be given bare or as a
+// quoted JSON string, everything else must be valid JSON.
+func (d *Definition) parseValue(s string) (any, error) {
+	if d.Type == schema.String {
+		if strings.HasPrefix(s, `"`) {
+			var v string
+			err := json.Unmarshal([]byte(s), &v)
+			return v, err
+		}
+		return s, nil
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("invalid value %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// applyDefaults fills in the defaults of properties omitted from nested objects.
+func (d *Definition) applyDefaults(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		for _, prop := range d.Properties {
+			pv, ok := v[prop.Name]
+			if !ok {
+				if prop.Default != nil {
+					v[prop.Name] = prop.Default
+				}
+				continue
+			}
+			v[prop.Name] = prop.applyDefaults(pv)
+		}
+	case []any:
+		if d.Items != nil {
+			for i := range v {
+				v[i] = d.Items.applyDefaults(v[i])
+			}
+		}
+	}
+	return val
+}
+
+func (d *Definition) validate(path string, val any) error {
+	if len(d.Enum) > 0 {
+		found := false
+		for _, e := range d.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid argument %s: must be one of %v", path, d.Enum)
+		}
+	}
+
+	switch v := val.(type) {
+	case string:
+		if d.Type == schema.Integer || d.Type == schema.Number {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil
+			}
+			return d.validateNumber(path, n)
+		}
+		n := utf8.RuneCountInString(v)
+		if d.MinLength != nil && n < *d.MinLength {
+			return fmt.Errorf("invalid argument %s: must be at least %d characters long", path, *d.MinLength)
+		}
+		if d.MaxLength != nil && n > *d.MaxLength {
+			return fmt.Errorf("invalid argument %s: must be at most %d characters long", path, *d.MaxLength)
+		}
+
+	case float64:
+		return d.validateNumber(path, v)
+	case int:
+		return d.validateNumber(path, float64(v))
+
+	case []any:
+		if d.MinItems != nil && len(v) < *d.MinItems {
+			return fmt.Errorf("invalid argument %s: must have at least %d items", path, *d.MinItems)
+		}
+		if d.MaxItems != nil && len(v) > *d.MaxItems {
+			return fmt.Errorf("invalid argument %s: must have at most %d items", path, *d.MaxItems)
+		}
+		if d.Items != nil {
+			for i, item := range v {
+				if err := d.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+
+	case map[string]any:
+		for _, prop := range d.Properties {
+			if pv, ok := v[prop.Name]; ok {
+				if err := prop.validate(path+"."+prop.Name, pv); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Definition) validateNumber(path string, n float64) error {
+	if d.Minimum != nil && n < *d.Minimum {
+		return fmt.Errorf("invalid argument %s: must be at least %v", path, *d.Minimum)
+	}
+	if d.Maximum != nil && n > *d.Maximum {
+		return fmt.Errorf("invalid argument %s: must be at most %v", path, *d.Maximum)
+	}
+	return nil
+}
+
+// fieldName returns the argument name of a struct field, following the same
+// rules as llum-tools.
+func fieldName(f reflect.StructField) (name string, omitempty bool) {
+	tag := f.Tag.Get("llm")
+	if tag == "" {
+		tag = f.Tag.Get("json")
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(opts, "omitempty")
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,226 @@
+package toolfns
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// toolFunction returns the named tool of ToolGroups.
+func toolFunction(t *testing.T, name string) *function {
+	t.Helper()
+	for _, g := range ToolGroups {
+		if f, ok := g.functions[name]; ok {
+			return f
+		}
+	}
+	t.Fatalf("%s not found", name)
+	return nil
+}
+
+func TestAnnotate(t *testing.T) {
+	tests := []struct {
+		typ  schema.Type
+		desc string
+		want Definition
+	}{
+		{schema.String, "The name.", Definition{Description: "The name."}},
+		{schema.String, "The name. @optional", Definition{Description: "The name.", optional: true}},
+		{schema.String, "Output format. @enum json, markdown @default markdown", Definition{
+			Description: "Output format.",
+			Enum:        []any{"json", "markdown"},
+			Default:     "markdown",
+			optional:    true,
+		}},
+		{schema.String, `Separator. @default ", "`, Definition{Description: "Separator.", Default: ", ", optional: true}},
+		{schema.Integer, "Mode. @enum 1, 2, 3", Definition{Description: "Mode.", Enum: []any{1.0, 2.0, 3.0}}},
+		{schema.Integer, "Limit. @min 1 @max 100 @default 10", Definition{
+			Description: "Limit.",
+			Minimum:     ptr(1.0),
+			Maximum:     ptr(100.0),
+			Default:     10.0,
+			optional:    true,
+		}},
+		{schema.Number, "Angle. @min -360 @max 360", Definition{Description: "Angle.", Minimum: ptr(-360.0), Maximum: ptr(360.0)}},
+		{schema.String, "Query. @min 3 @max 200", Definition{Description: "Query.", MinLength: ptr(3), MaxLength: ptr(200)}},
+		{schema.Array, "Paths. @min 1 @max 5 @optional", Definition{Description: "Paths.", MinItems: ptr(1), MaxItems: ptr(5), optional: true}},
+		{schema.String, "Address. @format uri @example https://example.com @example https://example.org/a", Definition{
+			Description: "Address.",
+			Format:      "uri",
+			Examples:    []any{"https://example.com", "https://example.org/a"},
+		}},
+		{schema.Boolean, "Force. @default true", Definition{Description: "Force.", Default: true, optional: true}},
+		// Words starting with @ that aren't annotations are kept.
+		{schema.String, "Email, like user@example.com or @handle.", Definition{Description: "Email, like user@example.com or @handle."}},
+	}
+	for _, tt := range tests {
+		d := &Definition{Type: tt.typ}
+		if err := d.annotate(tt.desc); err != nil {
+			t.Errorf("annotate(%q): %v", tt.desc, err)
+			continue
+		}
+		tt.want.Type = tt.typ
+		if !reflect.DeepEqual(*d, tt.want) {
+			t.Errorf("annotate(%q) = %+v, want %+v", tt.desc, *d, tt.want)
+		}
+	}
+}
+
+func TestAnnotateErrors(t *testing.T) {
+	tests := []struct {
+		typ  schema.Type
+		desc string
+		err  string
+	}{
+		{schema.Boolean, "Force. @min 1", "@min: not supported for type boolean"},
+		{schema.Integer, "Limit. @max many", "@max:"},
+		{schema.Integer, "Limit. @default ten", "@default: invalid value"},
+		{schema.Integer, "Mode. @enum 1, two", "@enum: invalid value"},
+		{schema.String, `Name. @default "unterminated`, "@default:"},
+	}
+	for _, tt := range tests {
+		d := &Definition{Type: tt.typ}
+		if err := d.annotate(tt.desc); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("annotate(%q) = %v, want an error containing %q", tt.desc, err, tt.err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	def := &Definition{Type: schema.Object, Properties: Properties{
+		{Name: "format", Definition: &Definition{Type: schema.String, Enum: []any{"json", "markdown"}}},
+		{Name: "limit", Definition: &Definition{Type: schema.Integer, Minimum: ptr(1.0), Maximum: ptr(100.0)}},
+		{Name: "query", Definition: &Definition{Type: schema.String, MinLength: ptr(3), MaxLength: ptr(5)}},
+		{Name: "items", Definition: &Definition{
+			Type:     schema.Array,
+			MinItems: ptr(1),
+			MaxItems: ptr(2),
+			Items: &Definition{Type: schema.Object, Properties: Properties{
+				{Name: "size", Definition: &Definition{Type: schema.Number, Maximum: ptr(10.0)}},
+			}},
+		}},
+	}}
+
+	tests := []struct {
+		val map[string]any
+		err string
+	}{
+		{map[string]any{}, ""},
+		{map[string]any{"format": "json", "limit": 100.0, "query": "héllo", "items": []any{map[string]any{"size": 10.0}}}, ""},
+		{map[string]any{"format": "xml"}, "invalid argument args.format: must be one of [json markdown]"},
+		{map[string]any{"limit": 0.0}, "invalid argument args.limit: must be at least 1"},
+		{map[string]any{"limit": 101}, "invalid argument args.limit: must be at most 100"},
+		// Numbers the model sends as strings are checked too.
+		{map[string]any{"limit": "500"}, "invalid argument args.limit: must be at most 100"},
+		{map[string]any{"query": "ab"}, "invalid argument args.query: must be at least 3 characters long"},
+		{map[string]any{"query": "abcdef"}, "invalid argument args.query: must be at most 5 characters long"},
+		{map[string]any{"items": []any{}}, "invalid argument args.items: must have at least 1 items"},
+		{map[string]any{"items": []any{1, 2, 3}}, "invalid argument args.items: must have at most 2 items"},
+		{map[string]any{"items": []any{map[string]any{"size": 1.0}, map[string]any{"size": 11.0}}}, "invalid argument args.items[1].size: must be at most 10"},
+	}
+	for _, tt := range tests {
+		err := def.validate("args", tt.val)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("validate(%v): %v", tt.val, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("validate(%v) = %v, want %q", tt.val, err, tt.err)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	nested := &Definition{Type: schema.Object, Properties: Properties{
+		{Name: "color", Definition: &Definition{Type: schema.String, Default: "red", optional: true}},
+		{Name: "size", Definition: &Definition{Type: schema.Number, Maximum: ptr(10.0)}},
+	}}
+	f := &function{params: []param{
+		{name: "query", typ: reflect.TypeFor[string](), def: &Definition{Type: schema.String}},
+		{name: "limit", typ: reflect.TypeFor[int](), def: &Definition{Type: schema.Integer, Default: 10.0, optional: true}},
+		{name: "all", typ: reflect.TypeFor[bool](), def: &Definition{Type: schema.Boolean, optional: true}},
+		{name: "items", typ: reflect.TypeFor[[]map[string]any](), def: &Definition{Type: schema.Array, Items: nested, optional: true}},
+		// Arguments of plugins have no Go type.
+		{name: "mode", def: &Definition{Type: schema.String, Default: "fast", optional: true}},
+		{name: "tag", def: &Definition{Type: schema.String, optional: true}},
+	}}
+
+	args := map[string]any{"query": "q", "items": []any{map[string]any{"size": 1.0}}}
+	got, err := f.prepare(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"query": "q",
+		"limit": 10.0,
+		"all":   false,
+		"items": []any{map[string]any{"size": 1.0, "color": "red"}},
+		"mode":  "fast",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("prepare = %#v, want %#v", got, want)
+	}
+	if _, ok := args["limit"]; ok {
+		t.Error("prepare changed its arguments")
+	}
+
+	got, err = f.prepare(map[string]any{"query": "q", "limit": 5.0, "all": true, "mode": "slow", "tag": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["limit"] != 5.0 || got["all"] != true || got["mode"] != "slow" || got["tag"] != "x" {
+		t.Errorf("prepare replaced given arguments: %v", got)
+	}
+
+	if _, err := f.prepare(map[string]any{"limit": 5.0}); err == nil || err.Error() != "missing argument: query" {
+		t.Errorf("prepare without a required argument: %v", err)
+	}
+
+	_, err = f.prepare(map[string]any{"query": "q", "items": []any{map[string]any{"size": 20.0}}})
+	if err == nil || err.Error() != "invalid argument items[0].size: must be at most 10" {
+		t.Errorf("prepare with an invalid nested argument: %v", err)
+	}
+}
+
+func TestFunctionSchema(t *testing.T) {
+	chart := toolFunction(t, "Chart").schema
+	if strings.Contains(chart.Description, "kind:") {
+		t.Errorf("description has the arguments: %q", chart.Description)
+	}
+	params := chart.Parameters
+	if !reflect.DeepEqual(params.Required, []string{"kind", "series"}) {
+		t.Errorf("Required = %v", params.Required)
+	}
+	var names []string
+	props := map[string]*Definition{}
+	for _, p := range params.Properties {
+		names = append(names, p.Name)
+		props[p.Name] = p.Definition
+	}
+	if want := []string{"kind", "series", "labels", "title", "xlabel", "ylabel", "width", "height", "format", "output"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if width := props["width"]; width.Default != 800.0 || *width.Minimum != 200 || *width.Maximum != 4000 {
+		t.Errorf("width = %+v", width)
+	}
+	if !reflect.DeepEqual(props["kind"].Enum, []any{"line", "bar", "scatter", "pie"}) {
+		t.Errorf("kind = %+v", props["kind"])
+	}
+	if *props["series"].MinItems != 1 {
+		t.Errorf("series = %+v", props["series"])
+	}
+
+	// Struct fields are described by their comments, and those with
+	// omitempty are optional.
+	series := props["series"].Items
+	if !reflect.DeepEqual(series.Required, []string{"values"}) {
+		t.Errorf("series items Required = %v", series.Required)
+	}
+	if series.Properties[0].Name != "name" || series.Properties[0].Description != "Name of the series, shown in the legend." {
+		t.Errorf("series items properties = %+v", series.Properties[0])
+	}
+}
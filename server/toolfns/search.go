@@ -0,0 +1,619 @@
+package toolfns
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ledongthuc/pdf"
+)
+
+// SearchConfig sets the folders indexed for the Search tool.
+type SearchConfig struct {
+	// Folders are the directories to index, recursively.
+	Folders []string `json:"folders"`
+	// IndexPath is the file the index is stored in. Defaults to search.index.
+	IndexPath string `json:"index_path,omitempty"`
+	// Extensions are the extensions of the files to index. Defaults to common
+	// text, markup and source code extensions, and .pdf.
+	Extensions []string `json:"extensions,omitempty"`
+	// MaxFileSize is the size above which files are skipped, in bytes.
+	// Defaults to 10MiB.
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+}
+
+type SearchResult struct {
+	Path    string  `json:"path"`
+	Page    int     `json:"page,omitempty"`
+	Line    int     `json:"line"`
+	EndLine int     `json:"end_line"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+var defaultSearchExtensions = []string{
+	".md", ".markdown", ".txt", ".rst", ".org", ".adoc",
+	".go", ".js", ".ts", ".jsx", ".tsx", ".svelte", ".vue", ".py", ".rb", ".java", ".kt",
+	".c", ".h", ".cpp", ".hpp", ".cs", ".rs", ".swift", ".php", ".sh", ".sql",
+	".html", ".css", ".json", ".yaml", ".yml", ".toml", ".xml",
+	".pdf",
+}
+
+const (
+	// chunkLines is the number of lines indexed together as one document.
+	chunkLines = 20
+	// snippetLines is the number of lines returned around the best match.
+	snippetLines = 5
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Searches the configured document folders for the given keywords, and returns the best matching snippets with their file path and line numbers.
+// query: Keywords to search for.
+// limit: Maximum number of results. @min 1 @max 50 @default 10
+// path: Only return results from files whose path contains this text. @optional
+func Search(query string, limit int, path string) ([]SearchResult, error) {
+	if len(config.Search.Folders) == 0 {
+		return nil, errors.New("no folders are configured for search")
+	}
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, errors.New("query has no searchable words")
+	}
+	return index.search(terms, limit, path), nil
+}
+
+// StartIndexing loads the search index, brings it up to date with the
+// configured folders, and keeps it updated as files change. It does nothing if
+// no folders are configured.
+func StartIndexing() error {
+	c := config.Search
+	if len(c.Folders) == 0 {
+		return nil
+	}
+	for i, folder := range c.Folders {
+		c.Folders[i] = filepath.Clean(folder)
+	}
+
+	if err := index.load(c.indexPath()); err != nil {
+		log.Printf("search: starting a new index: %v", err)
+	}
+	// Drop the files of folders that are no longer configured.
+	index.mu.Lock()
+	for path := range index.Files {
+		if !c.contains(path) {
+			index.remove(path)
+		}
+	}
+	index.mu.Unlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, folder := range c.Folders {
+		if err := watchTree(watcher, folder); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		start := time.Now()
+		n := index.sync(c)
+		log.Printf("search: indexed %d files in %s", n, time.Since(start).Round(time.Millisecond))
+		index.save(c.indexPath())
+		watchIndex(watcher, index, c)
+	}()
+	return nil
+}
+
+// watchIndex reindexes changed files, batching changes that happen together.
+func watchIndex(watcher *fsnotify.Watcher, idx *searchIndex, c SearchConfig) {
+	changed := map[string]bool{}
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if skipDir(filepath.Base(event.Name)) {
+						continue
+					}
+					watchTree(watcher, event.Name)
+				}
+			}
+			changed[event.Name] = true
+			timer.Reset(time.Second)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("search: watching files: %v", err)
+
+		case <-timer.C:
+			for path := range changed {
+				idx.update(c, path)
+			}
+			changed = map[string]bool{}
+			idx.save(c.indexPath())
+		}
+	}
+}
+
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "node_modules" || name == "__pycache__" || name == "vendor"
+}
+
+// searchIndex is a BM25 inverted index over chunks of the indexed files.
+type searchIndex struct {
+	mu sync.RWMutex
+
+	Files    map[string]*indexedFile
+	Chunks   map[int]*indexedChunk
+	Postings map[string]map[int]int // term -> chunk -> term frequency
+	Length   int                    // total number of terms in all chunks
+	NextID   int
+}
+
+type indexedFile struct {
+	ModTime time.Time
+	Size    int64
+	Chunks  []int
+}
+
+type indexedChunk struct {
+	Path   string
+	Page   int
+	Line   int
+	Text   string
+	Length int
+}
+
+var index = newSearchIndex()
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		Files:    map[string]*indexedFile{},
+		Chunks:   map[int]*indexedChunk{},
+		Postings: map[string]map[int]int{},
+	}
+}
+
+func (idx *searchIndex) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	loaded := newSearchIndex()
+	if err := gob.NewDecoder(f).Decode(loaded); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.Files, idx.Chunks, idx.Postings = loaded.Files, loaded.Chunks, loaded.Postings
+	idx.Length, idx.NextID = loaded.Length, loaded.NextID
+	return nil
+}
+
+func (idx *searchIndex) save(path string) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Printf("search: saving index: %v", err)
+		return
+	}
+	err = gob.NewEncoder(f).Encode(idx)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		log.Printf("search: saving index: %v", err)
+	}
+}
+
+// sync indexes the files that changed since the index was saved, and drops the
+// ones that no longer exist. It returns the number of files indexed.
+func (idx *searchIndex) sync(c SearchConfig) int {
+	seen := map[string]bool{}
+	n := 0
+	for _, folder := range c.Folders {
+		filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != folder && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			seen[path] = true
+			if idx.update(c, path) {
+				n++
+			}
+			return nil
+		})
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for path := range idx.Files {
+		if !seen[path] && c.contains(path) {
+			idx.remove(path)
+		}
+	}
+	return n
+}
+
+// update reindexes the file at path if it changed, or drops it if it no longer
+// exists. If path is a directory, the files under it are updated. It reports
+// whether the file was indexed.
+func (idx *searchIndex) update(c SearchConfig, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		idx.mu.Lock()
+		defer idx.mu.Unlock()
+		// The path may have been a directory.
+		prefix := path + string(filepath.Separator)
+		for p := range idx.Files {
+			if p == path || strings.HasPrefix(p, prefix) {
+				idx.remove(p)
+			}
+		}
+		return false
+	}
+	if info.IsDir() {
+		if skipDir(filepath.Base(path)) {
+			return false
+		}
+		idx.sync(SearchConfig{Folders: []string{path}, Extensions: c.Extensions, MaxFileSize: c.MaxFileSize})
+		return false
+	}
+	if !c.indexable(path, info) {
+		return false
+	}
+
+	idx.mu.RLock()
+	f, ok := idx.Files[path]
+	upToDate := ok && f.ModTime.Equal(info.ModTime()) && f.Size == info.Size()
+	idx.mu.RUnlock()
+	if upToDate {
+		return false
+	}
+
+	pages, err := readDocument(path)
+	if err != nil {
+		log.Printf("search: indexing %s: %v", path, err)
+		return false
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(path)
+	file := &indexedFile{ModTime: info.ModTime(), Size: info.Size()}
+	for i, text := range pages {
+		page := 0
+		if isPDF(path) {
+			page = i + 1
+		}
+		lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+		for start := 0; start < len(lines); start += chunkLines {
+			end := min(start+chunkLines, len(lines))
+			chunk := &indexedChunk{
+				Path: path,
+				Page: page,
+				Line: start + 1,
+				Text: strings.Join(lines[start:end], "\n"),
+			}
+			file.Chunks = append(file.Chunks, idx.add(chunk))
+		}
+	}
+	idx.Files[path] = file
+	return true
+}
+
+// add indexes chunk and returns its id. idx.mu must be held.
+func (idx *searchIndex) add(chunk *indexedChunk) int {
+	id := idx.NextID
+	idx.NextID++
+
+	terms := tokenize(chunk.Text)
+	chunk.Length = len(terms)
+	idx.Chunks[id] = chunk
+	idx.Length += chunk.Length
+	for _, term := range terms {
+		postings := idx.Postings[term]
+		if postings == nil {
+			postings = map[int]int{}
+			idx.Postings[term] = postings
+		}
+		postings[id]++
+	}
+	return id
+}
+
+// remove drops the file at path from the index. idx.mu must be held.
+func (idx *searchIndex) remove(path string) {
+	f, ok := idx.Files[path]
+	if !ok {
+		return
+	}
+	for _, id := range f.Chunks {
+		chunk := idx.Chunks[id]
+		for _, term := range tokenize(chunk.Text) {
+			postings := idx.Postings[term]
+			delete(postings, id)
+			if len(postings) == 0 {
+				delete(idx.Postings, term)
+			}
+		}
+		idx.Length -= chunk.Length
+		delete(idx.Chunks, id)
+	}
+	delete(idx.Files, path)
+}
+
+func (idx *searchIndex) search(terms []string, limit int, path string) []SearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.Chunks))
+	if n == 0 {
+		return []SearchResult{}
+	}
+	avgLength := float64(idx.Length) / n
+
+	scores := map[int]float64{}
+	for _, term := range uniqueTerms(terms) {
+		postings := idx.Postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			length := float64(idx.Chunks[id].Length)
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/avgLength))
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		if path == "" || strings.Contains(idx.Chunks[id].Path, path) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	results := make([]SearchResult, 0, len(ids))
+	for _, id := range ids {
+		chunk := idx.Chunks[id]
+		line, snippet := chunk.snippet(terms)
+		results = append(results, SearchResult{
+			Path:    chunk.Path,
+			Page:    chunk.Page,
+			Line:    line,
+			EndLine: line + strings.Count(snippet, "\n"),
+			Score:   math.Round(scores[id]*1000) / 1000,
+			Snippet: snippet,
+		})
+	}
+	return results
+}
+
+// snippet returns the lines of the chunk around the one matching the most
+// terms, and the number of the first of them.
+func (c *indexedChunk) snippet(terms []string) (int, string) {
+	want := map[string]bool{}
+	for _, term := range terms {
+		want[term] = true
+	}
+
+	lines := strings.Split(c.Text, "\n")
+	best, bestHits := 0, -1
+	for i, line := range lines {
+		hits := 0
+		for _, term := range tokenize(line) {
+			if want[term] {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = i, hits
+		}
+	}
+
+	start := max(0, best-snippetLines/2)
+	end := min(len(lines), start+snippetLines)
+	start = max(0, end-snippetLines)
+	return c.Line + start, strings.Join(lines[start:end], "\n")
+}
+
+// tokenize splits text into lowercase words. Identifiers written in camelCase
+// or snake_case are also split into their parts.
+func tokenize(text string) []string {
+	var terms []string
+	word := func(w []rune) {
+		if len(w) < 2 {
+			return
+		}
+		terms = append(terms, strings.ToLower(string(w)))
+	}
+
+	var current []rune
+	partStart := 0
+	flush := func() {
+		if partStart > 0 {
+			word(current[partStart:])
+		}
+		word(current)
+		current = current[:0]
+		partStart = 0
+	}
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if n := len(current); n > 0 && unicode.IsUpper(r) && unicode.IsLower(current[n-1]) {
+			word(current[partStart:])
+			partStart = n
+		}
+		current = append(current, r)
+	}
+	flush()
+	return terms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	unique := terms[:0:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// readDocument returns the text of the file at path, one string per page.
+func readDocument(path string) ([]string, error) {
+	if !isPDF(path) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strings.ToValidUTF8(string(data), "")}, nil
+	}
+
+	_, pages, err := readPDF(path)
+	return pages, err
+}
+
+// readPDF returns the title of a PDF and the text of each of its pages. The
+// pdf package panics on some malformed files instead of failing, which is
+// turned into an error: documents come from anywhere, and a bad one must not
+// bring down the server.
+func readPDF(path string) (title string, pages []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			title, pages, err = "", nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	title = r.Trailer().Key("Info").Key("Title").Text()
+	pages = make([]string, r.NumPage())
+	for i := range pages {
+		page := r.Page(i + 1)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		pages[i] = text
+	}
+	return title, pages, nil
+}
+
+func (c SearchConfig) indexable(path string, info fs.FileInfo) bool {
+	if !info.Mode().IsRegular() || info.Size() > c.maxFileSize() {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	extensions := c.Extensions
+	if len(extensions) == 0 {
+		extensions = defaultSearchExtensions
+	}
+	for _, e := range extensions {
+		if ext == strings.ToLower(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPDF(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// contains reports whether path is inside one of the folders of c.
+func (c SearchConfig) contains(path string) bool {
+	for _, folder := range c.Folders {
+		if path == folder || strings.HasPrefix(path, folder+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c SearchConfig) indexPath() string {
+	if c.IndexPath == "" {
+		return "search.index"
+	}
+	return c.IndexPath
+}
+
+func (c SearchConfig) maxFileSize() int64 {
+	if c.MaxFileSize <= 0 {
+		return 10 * 1024 * 1024
+	}
+	return c.MaxFileSize
+}
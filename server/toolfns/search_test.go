@@ -0,0 +1,125 @@
+package toolfns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// writePDF writes a single page PDF showing text, with catalog as the
+// dictionary of its catalog.
+func writePDF(t *testing.T, catalog, text string) string {
+	t.Helper()
+	content := "BT /F1 12 Tf (" + text + ") Tj ET"
+	objects := []string{
+		catalog,
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer << /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadPDF(t *testing.T) {
+	path := writePDF(t, "<< /Type /Catalog /Pages 2 0 R >>", "hello")
+	_, pages, err := readPDF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || !strings.Contains(pages[0], "hello") {
+		t.Fatalf("pages = %q", pages)
+	}
+}
+
+func TestReadPDFMalformed(t *testing.T) {
+	// The pdf package panics on the stray delimiter.
+	path := writePDF(t, "<< /Type /Catalog /Pages 2 0 R ) >>", "hello")
+	if _, err := readDocument(path); err == nil || !strings.Contains(err.Error(), "malformed PDF") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWatchIndexSkipsCreatedDirs(t *testing.T) {
+	root := t.TempDir()
+	c := SearchConfig{Folders: []string{root}, IndexPath: filepath.Join(t.TempDir(), "search.index")}
+	idx := newSearchIndex()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watchTree(watcher, root); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		watchIndex(watcher, idx, c)
+		close(done)
+	}()
+	defer func() {
+		watcher.Close()
+		<-done
+	}()
+
+	for _, rel := range []string{"node_modules/dep/README.md", ".cache/notes.md", "docs/guide.md"} {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("installation steps\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	guide := filepath.Join(root, "docs", "guide.md")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		idx.mu.RLock()
+		_, ok := idx.Files[guide]
+		idx.mu.RUnlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("docs/guide.md wasn't indexed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	idx.mu.RLock()
+	for path := range idx.Files {
+		if path != guide {
+			t.Errorf("%s was indexed", path)
+		}
+	}
+	idx.mu.RUnlock()
+	for _, path := range watcher.WatchList() {
+		if strings.Contains(path, "node_modules") || strings.Contains(path, ".cache") {
+			t.Errorf("%s is watched", path)
+		}
+	}
+}
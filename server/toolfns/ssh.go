@@ -0,0 +1,467 @@
+package toolfns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHHostConfig describes a remote host the SSH tools can connect to.
+type SSHHostConfig struct {
+	// Address is the host name or IP address of the server, optionally
+	// followed by a port. The port defaults to 22.
+	Address string `json:"address"`
+	// User is the user to log in as.
+	User string `json:"user"`
+	// KeyFile is the path of the private key used to log in.
+	KeyFile string `json:"key_file"`
+	// Passphrase decrypts the key, if it is encrypted. Environment variables
+	// are expanded.
+	Passphrase string `json:"passphrase,omitempty"`
+	// HostKey is the public key of the server, in authorized_keys format.
+	// When empty, the server is verified against KnownHosts instead.
+	HostKey string `json:"host_key,omitempty"`
+	// KnownHosts is the known_hosts file used to verify the server. Defaults
+	// to ~/.ssh/known_hosts.
+	KnownHosts string `json:"known_hosts,omitempty"`
+	// Description tells the model what the host is for.
+	Description string `json:"description,omitempty"`
+	// AllowedCommands are the commands that may be run on the host, where *
+	// matches any text: "systemctl status *" allows checking any service,
+	// and "*" allows every command. Commands that use shell operators, such
+	// as ; | & $( or redirections, only match "*". No command is allowed by
+	// default.
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+	// AllowedPaths are the remote directories that files may be uploaded to
+	// and downloaded from. No transfer is allowed by default.
+	AllowedPaths []string `json:"allowed_paths,omitempty"`
+}
+
+type SSHHost struct {
+	Name            string   `json:"name"`
+	Address         string   `json:"address"`
+	User            string   `json:"user"`
+	Description     string   `json:"description,omitempty"`
+	AllowedCommands []string `json:"allowed_commands"`
+	AllowedPaths    []string `json:"allowed_paths"`
+}
+
+type SSHResult struct {
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+const (
+	maxSSHOutput   = 64 * 1024
+	maxSSHTransfer = 100 * 1024 * 1024
+	sshDialTimeout = 10 * time.Second
+)
+
+// Lists the hosts that the SSH tools can connect to, with the commands and directories allowed on each.
+func SSHHosts() []SSHHost {
+	hosts := []SSHHost{}
+	for name, c := range config.SSH {
+		hosts = append(hosts, SSHHost{
+			Name:            name,
+			Address:         c.address(),
+			User:            c.User,
+			Description:     c.Description,
+			AllowedCommands: append([]string{}, c.AllowedCommands...),
+			AllowedPaths:    append([]string{}, c.AllowedPaths...),
+		})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts
+}
+
+// Runs a command on a remote host over SSH and returns its output. Only the commands allowed for the host can be run, see SSHHosts.
+// host: Name of the host.
+// command: The command to run, interpreted by the remote user's shell.
+// timeout: Maximum time the command may run, in seconds. @min 1 @max 3600 @default 60
+func SSHRun(host string, command string, timeout int) (*SSHResult, error) {
+	c, ok := config.SSH[host]
+	if !ok {
+		return nil, fmt.Errorf("unknown SSH host: %s", host)
+	}
+	if !matchCommand(c.AllowedCommands, command) {
+		return nil, fmt.Errorf("command is not allowed on %s, see SSHHosts for the allowed commands", host)
+	}
+
+	session, err := sshSession(host)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	stdout := &limitedBuffer{max: maxSSHOutput}
+	stderr := &limitedBuffer{max: maxSSHOutput}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	result := &SSHResult{}
+	err = runSession(session, command, time.Duration(timeout)*time.Second)
+	var exitErr *ssh.ExitError
+	var missingErr *ssh.ExitMissingError
+	switch {
+	case errors.Is(err, errSSHTimeout):
+		result.TimedOut = true
+		result.ExitCode = -1
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+	case errors.As(err, &missingErr):
+		result.ExitCode = -1
+	case err != nil:
+		return nil, err
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result, nil
+}
+
+// Uploads a file from the workspace to a remote host. The remote directory is created if needed, and an existing file is overwritten.
+// host: Name of the host.
+// path: Path of the file in the workspace.
+// remote_path: Absolute path to write the file to on the host. It must be inside one of the host's allowed paths.
+func SSHUpload(ws *Workspace, host string, path string, remote_path string) (string, error) {
+	local, err := ws.Path(path)
+	if err != nil {
+		return "", err
+	}
+	remote, err := allowedRemotePath(host, remote_path)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxSSHTransfer {
+		return "", fmt.Errorf("%s is larger than %d MB", path, maxSSHTransfer>>20)
+	}
+
+	session, err := sshSession(host)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdin = f
+	session.Stderr = &stderr
+	command := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(pathDir(remote)), shellQuote(remote))
+	if err := runSession(session, command, transferTimeout(info.Size())); err != nil {
+		return "", transferError(err, &stderr)
+	}
+	return fmt.Sprintf("Uploaded %s (%d bytes) to %s:%s", path, info.Size(), host, remote), nil
+}
+
+// Downloads a file from a remote host into the workspace.
+// @mutates
+// host: Name of the host.
+// remote_path: Absolute path of the file on the host. It must be inside one of the host's allowed paths.
+// path: Path to save the file to in the workspace. Defaults to the name of the remote file. @optional
+func SSHDownload(ws *Workspace, host string, remote_path string, path string) (string, error) {
+	remote, err := allowedRemotePath(host, remote_path)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = pathBase(remote)
+	}
+	local, err := ws.Path(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+		return "", err
+	}
+
+	session, err := sshSession(host)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	// Write to a temporary file, so that a failed download doesn't clobber
+	// an existing file.
+	f, err := os.CreateTemp(filepath.Dir(local), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	out := &limitedWriter{w: f, max: maxSSHTransfer}
+	var stderr bytes.Buffer
+	session.Stdout = out
+	session.Stderr = &stderr
+	err = runSession(session, "cat -- "+shellQuote(remote), transferTimeout(maxSSHTransfer))
+	if out.exceeded {
+		return "", fmt.Errorf("%s is larger than %d MB", remote, maxSSHTransfer>>20)
+	}
+	if err != nil {
+		return "", transferError(err, &stderr)
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), local); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Downloaded %s:%s (%d bytes) to %s", host, remote, out.n, path), nil
+}
+
+var errSSHTimeout = errors.New("timed out")
+
+// runSession runs command in session, and kills it if it doesn't exit within
+// timeout.
+func runSession(session *ssh.Session, command string, timeout time.Duration) error {
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		return errSSHTimeout
+	}
+}
+
+// transferTimeout allows a minute, plus ten seconds per megabyte to transfer.
+func transferTimeout(size int64) time.Duration {
+	return time.Minute + time.Duration(size>>20)*10*time.Second
+}
+
+func transferError(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return err
+}
+
+// limitedWriter writes up to max bytes to w, and fails once more is written.
+type limitedWriter struct {
+	w        io.Writer
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n+int64(len(p)) > l.max {
+		l.exceeded = true
+		return 0, errors.New("file too large")
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// shellOperators are the characters that let a command do more than run a
+// single program with arguments.
+const shellOperators = ";&|`$<>(){}\n\r"
+
+// matchCommand reports whether command matches one of patterns, in which *
+// matches any text.
+func matchCommand(patterns []string, command string) bool {
+	command = strings.TrimSpace(command)
+	operators := strings.ContainsAny(command, shellOperators)
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if operators {
+			continue
+		}
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSpace(pattern)), `\*`, ".*") + "$"
+		if regexp.MustCompile(expr).MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRemotePath cleans the remote path p, and checks that it is inside
+// one of the allowed paths of host.
+func allowedRemotePath(host, p string) (string, error) {
+	c, ok := config.SSH[host]
+	if !ok {
+		return "", fmt.Errorf("unknown SSH host: %s", host)
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("remote path %q must be absolute", p)
+	}
+	p = path.Clean(p)
+	for _, dir := range c.AllowedPaths {
+		dir = path.Clean(dir)
+		if dir == "/" || p == dir || strings.HasPrefix(p, dir+"/") {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in the allowed paths of %s, see SSHHosts", p, host)
+}
+
+// pathDir and pathBase are used by the tools whose path parameter shadows the
+// path package.
+func pathDir(p string) string  { return path.Dir(p) }
+func pathBase(p string) string { return path.Base(p) }
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (c SSHHostConfig) address() string {
+	if _, _, err := net.SplitHostPort(c.Address); err == nil {
+		return c.Address
+	}
+	return net.JoinHostPort(strings.Trim(c.Address, "[]"), "22")
+}
+
+func (c SSHHostConfig) clientConfig() (*ssh.ClientConfig, error) {
+	key, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var signer ssh.Signer
+	if c.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(os.ExpandEnv(c.Passphrase)))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("%s is encrypted, but no passphrase is configured", c.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.KeyFile, err)
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if c.HostKey != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("host_key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	} else {
+		file := c.KnownHosts
+		if file == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			file = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		if hostKeyCallback, err = knownhosts.New(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+var (
+	sshClients   = map[string]*ssh.Client{}
+	sshClientsMu sync.Mutex
+)
+
+// sshSession opens a session on the named host, reusing its connection if it
+// is still alive.
+func sshSession(name string) (*ssh.Session, error) {
+	c, ok := config.SSH[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown SSH host: %s", name)
+	}
+
+	// Connecting can take up to sshDialTimeout, so it is done without holding
+	// the lock, which would hold up the calls to every other host.
+	sshClientsMu.Lock()
+	client, ok := sshClients[name]
+	sshClientsMu.Unlock()
+	if ok {
+		session, err := client.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		sshClientsMu.Lock()
+		if sshClients[name] == client {
+			delete(sshClients, name)
+		}
+		sshClientsMu.Unlock()
+		client.Close()
+	}
+
+	cc, err := c.clientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	client, err = ssh.Dial("tcp", c.address(), cc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	sshClientsMu.Lock()
+	if other, ok := sshClients[name]; ok {
+		// Another call connected first, so share its connection.
+		sshClientsMu.Unlock()
+		client.Close()
+		client = other
+	} else {
+		sshClients[name] = client
+		sshClientsMu.Unlock()
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return session, nil
+}
+
+// CloseSSH closes the connections of the SSH tools.
+func CloseSSH() {
+	sshClientsMu.Lock()
+	defer sshClientsMu.Unlock()
+	for name, client := range sshClients {
+		client.Close()
+		delete(sshClients, name)
+	}
+}
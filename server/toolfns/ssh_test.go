@@ -0,0 +1,361 @@
+package toolfns
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHServer starts an SSH server on a loopback listener that runs the
+// commands of its clients with sh, and returns its address and host key. Only
+// clientKey may log in.
+func newSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	hostKey := newSSHSigner(t)
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, cfg)
+		}
+	}()
+	return l.Addr().String(), hostKey.PublicKey()
+}
+
+func serveSSH(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, reqs)
+	}
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	var cmd *exec.Cmd
+	done := make(chan struct{})
+	for req := range reqs {
+		switch {
+		case req.Type == "exec" && cmd == nil:
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			cmd = exec.Command("sh", "-c", payload.Command)
+			cmd.Stdin = ch
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			go func() {
+				defer close(done)
+				status := uint32(0)
+				if err := cmd.Wait(); err != nil {
+					status = 1
+					if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+						status = uint32(exitErr.ExitCode())
+					}
+				}
+				var payload [4]byte
+				binary.BigEndian.PutUint32(payload[:], status)
+				ch.SendRequest("exit-status", false, payload[:])
+				ch.Close()
+			}()
+		case req.Type == "signal" && cmd != nil:
+			cmd.Process.Kill()
+		default:
+			req.Reply(false, nil)
+		}
+	}
+	// The client closed the channel.
+	if cmd != nil {
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+func newSSHSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// setSSHHosts configures the SSH tools with hosts that log in to a new SSH
+// server with a new key.
+func setSSHHosts(t *testing.T, hosts map[string]SSHHostConfig) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, hostKey := newSSHServer(t, signer.PublicKey())
+
+	old := config.SSH
+	config.SSH = map[string]SSHHostConfig{}
+	for name, c := range hosts {
+		c.Address = addr
+		c.User = "test"
+		c.KeyFile = keyFile
+		if c.HostKey == "" {
+			c.HostKey = string(ssh.MarshalAuthorizedKey(hostKey))
+		}
+		config.SSH[name] = c
+	}
+	t.Cleanup(func() {
+		CloseSSH()
+		config.SSH = old
+	})
+}
+
+func TestSSHRun(t *testing.T) {
+	setSSHHosts(t, map[string]SSHHostConfig{
+		"app": {AllowedCommands: []string{"echo *", "ls *", "sleep *"}},
+	})
+
+	result, err := SSHRun("app", "echo hello", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "hello\n" {
+		t.Errorf("echo hello = %+v", result)
+	}
+
+	// The connection is reused.
+	result, err = SSHRun("app", "ls /nonexistent", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode == 0 || result.Stderr == "" {
+		t.Errorf("ls /nonexistent = %+v", result)
+	}
+
+	if _, err := SSHRun("app", "echo hi; rm -rf /", 10); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("command with an operator: %v", err)
+	}
+	if _, err := SSHRun("app", "cat /etc/passwd", 10); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("command that isn't allowed: %v", err)
+	}
+
+	result, err = SSHRun("app", "sleep 30", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("sleep 30 = %+v", result)
+	}
+}
+
+func TestSSHHostKeyMismatch(t *testing.T) {
+	other := newSSHSigner(t)
+	setSSHHosts(t, map[string]SSHHostConfig{
+		"app": {
+			AllowedCommands: []string{"*"},
+			HostKey:         string(ssh.MarshalAuthorizedKey(other.PublicKey())),
+		},
+	})
+	_, err := SSHRun("app", "echo hello", 10)
+	if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("SSHRun with the wrong host key: %v", err)
+	}
+}
+
+func TestSSHDialDoesNotBlockOtherHosts(t *testing.T) {
+	setSSHHosts(t, map[string]SSHHostConfig{
+		"app": {AllowedCommands: []string{"*"}},
+	})
+
+	// A host that accepts connections but never completes the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	stuck := config.SSH["app"]
+	stuck.Address = l.Addr().String()
+	config.SSH["stuck"] = stuck
+
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := SSHRun("stuck", "echo hello", 10)
+		dialed <- err
+	}()
+	conn := <-accepted
+	defer func() {
+		conn.Close()
+		l.Close()
+		<-dialed
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := SSHRun("app", "echo hello", 10)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SSHRun waited for the connection to another host")
+	}
+}
+
+func TestSSHTransfer(t *testing.T) {
+	remote := t.TempDir()
+	setSSHHosts(t, map[string]SSHHostConfig{
+		"app": {AllowedPaths: []string{remote}},
+	})
+	ws := &Workspace{ChatID: "test", Dir: t.TempDir()}
+	writeFile(t, ws, "a.txt", "hello\n")
+
+	if _, err := SSHUpload(ws, "app", "a.txt", remote+"/dir/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(remote, "dir", "a.txt")); err != nil || string(data) != "hello\n" {
+		t.Fatalf("uploaded file = %q, %v", data, err)
+	}
+	if _, err := SSHDownload(ws, "app", remote+"/dir/a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(ws.Dir, "b.txt")); err != nil || string(data) != "hello\n" {
+		t.Fatalf("downloaded file = %q, %v", data, err)
+	}
+
+	if _, err := SSHUpload(ws, "app", "a.txt", remote+"2/a.txt"); err == nil {
+		t.Error("uploaded outside of the allowed paths")
+	}
+}
+
+func TestMatchCommand(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		command  string
+		want     bool
+	}{
+		{nil, "ls", false},
+		{[]string{"*"}, "ls; rm -rf /", true},
+		{[]string{"systemctl status *"}, "systemctl status nginx", true},
+		{[]string{"systemctl status *"}, "  systemctl status nginx  ", true},
+		{[]string{"systemctl status *"}, "systemctl restart nginx", false},
+		{[]string{"systemctl status *"}, "systemctl status nginx; reboot", false},
+		{[]string{"systemctl status *"}, "systemctl status $(reboot)", false},
+		{[]string{"systemctl status *"}, "systemctl status nginx > /etc/passwd", false},
+		{[]string{"systemctl status *"}, "systemctl status nginx\nreboot", false},
+		{[]string{"uptime"}, "uptime", true},
+		{[]string{"uptime"}, "uptime -p", false},
+		{[]string{"ls [a]"}, "ls a", false},
+	}
+	for _, tt := range tests {
+		if got := matchCommand(tt.patterns, tt.command); got != tt.want {
+			t.Errorf("matchCommand(%q, %q) = %v, want %v", tt.patterns, tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedRemotePath(t *testing.T) {
+	old := config.SSH
+	config.SSH = map[string]SSHHostConfig{
+		"app": {AllowedPaths: []string{"/srv/app", "/var/log/"}},
+	}
+	t.Cleanup(func() { config.SSH = old })
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/srv/app", "/srv/app"},
+		{"/srv/app/config.yml", "/srv/app/config.yml"},
+		{"/srv/app/./a/../b", "/srv/app/b"},
+		{"/var/log/syslog", "/var/log/syslog"},
+		{"/srv/app/../../etc/passwd", ""},
+		{"/srv/app2/config.yml", ""},
+		{"/srv/ap", ""},
+		{"/srv", ""},
+		{"srv/app/config.yml", ""},
+	}
+	for _, tt := range tests {
+		got, err := allowedRemotePath("app", tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("allowedRemotePath(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("allowedRemotePath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+
+	if _, err := allowedRemotePath("other", "/srv/app"); err == nil {
+		t.Error("allowedRemotePath of an unknown host succeeded")
+	}
+}
@@ -0,0 +1,506 @@
+package toolfns
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/hinshun/vt10x"
+)
+
+type TerminalInfo struct {
+	Name     string    `json:"name"`
+	Command  string    `json:"command"`
+	Cols     int       `json:"cols"`
+	Rows     int       `json:"rows"`
+	Running  bool      `json:"running"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Started  time.Time `json:"started"`
+	// Path is where the session can be watched live over WebSocket.
+	Path string `json:"path"`
+}
+
+// TerminalScreen is what a terminal session currently displays.
+type TerminalScreen struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+	// Screen holds the lines of the screen, with trailing spaces and blank
+	// lines removed.
+	Screen   string `json:"screen"`
+	CursorX  int    `json:"cursor_x"`
+	CursorY  int    `json:"cursor_y"`
+	Running  bool   `json:"running"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+const (
+	// terminalSettle is how long a terminal has to stay quiet for its output
+	// to be considered complete.
+	terminalSettle = 300 * time.Millisecond
+	// maxTerminalSize bounds the columns and rows a client can resize a
+	// terminal to.
+	maxTerminalSize = 1000
+	// maxTerminalWait bounds the wait of WriteTerminal and ReadTerminal.
+	maxTerminalWait = 30 * time.Second
+)
+
+// terminalKeys maps the special keys accepted by WriteTerminal to what a
+// terminal sends for them.
+var terminalKeys = map[string]string{
+	"Enter":     "\r",
+	"Tab":       "\t",
+	"Esc":       "\x1b",
+	"Backspace": "\x7f",
+	"Space":     " ",
+	"Up":        "\x1b[A",
+	"Down":      "\x1b[B",
+	"Right":     "\x1b[C",
+	"Left":      "\x1b[D",
+	"Home":      "\x1b[H",
+	"End":       "\x1b[F",
+	"PageUp":    "\x1b[5~",
+	"PageDown":  "\x1b[6~",
+	"Delete":    "\x1b[3~",
+}
+
+// Opens an interactive terminal session in the workspace, for programs that need a TTY such as REPLs, `top` or interactive installers. Use WriteTerminal to type into it, and ReadTerminal to read its screen.
+// @mutates
+// name: A name for the session, used to refer to it later. @example shell
+// command: The command to run in the terminal. @default bash
+// cols: Width of the terminal in characters. @min 20 @max 400 @default 120
+// rows: Height of the terminal in lines. @min 5 @max 200 @default 32
+func OpenTerminal(ws *Workspace, name string, command string, cols int, rows int) (*TerminalInfo, error) {
+	if !processNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid terminal name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	t, err := terminals.open(ws, name, command, cols, rows)
+	if err != nil {
+		return nil, err
+	}
+	return t.info(), nil
+}
+
+// Types into a terminal session, then waits for its output to settle and returns the screen.
+// @mutates
+// name: The name of the session.
+// keys: The text to type. Special keys are written in angle brackets: <Enter>, <Tab>, <Esc>, <Backspace>, <Space>, <Up>, <Down>, <Left>, <Right>, <Home>, <End>, <PageUp>, <PageDown>, <Delete>, and <C-x> for Ctrl+x. @example ls -la<Enter>
+// wait: Maximum time to wait for the output to settle, in milliseconds. @min 0 @max 30000 @default 2000
+func WriteTerminal(ws *Workspace, name string, keys string, wait int) (*TerminalScreen, error) {
+	t, err := terminals.get(ws.ChatID, name)
+	if err != nil {
+		return nil, err
+	}
+	since := t.version()
+	if _, err := t.Write([]byte(parseTerminalKeys(keys))); err != nil {
+		return nil, err
+	}
+	t.settle(since, time.Duration(wait)*time.Millisecond)
+	return t.Screen(), nil
+}
+
+// Returns the screen of a terminal session, optionally waiting for new output first.
+// name: The name of the session.
+// wait: Maximum time to wait for new output to settle, in milliseconds. Returns the screen right away if 0. @min 0 @max 30000 @default 0
+func ReadTerminal(ws *Workspace, name string, wait int) (*TerminalScreen, error) {
+	t, err := terminals.get(ws.ChatID, name)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		t.settle(t.version(), time.Duration(wait)*time.Millisecond)
+	}
+	return t.Screen(), nil
+}
+
+// Lists the terminal sessions of this chat.
+func ListTerminals(ws *Workspace) ([]*TerminalInfo, error) {
+	return terminals.list(ws.ChatID), nil
+}
+
+// Closes a terminal session, hanging up on the program running in it.
+// name: The name of the session.
+func CloseTerminal(ws *Workspace, name string) (*TerminalInfo, error) {
+	t, err := terminals.remove(ws.ChatID, name)
+	if err != nil {
+		return nil, err
+	}
+	t.close()
+	return t.info(), nil
+}
+
+// OpenedTerminal returns an open terminal session of a chat.
+func OpenedTerminal(chatID, name string) (*Terminal, error) {
+	return terminals.get(chatID, name)
+}
+
+// CloseTerminals closes the terminal sessions of all chats.
+func CloseTerminals() {
+	terminals.mu.Lock()
+	chatIDs := make([]string, 0, len(terminals.chats))
+	for chatID := range terminals.chats {
+		chatIDs = append(chatIDs, chatID)
+	}
+	terminals.mu.Unlock()
+
+	for _, chatID := range chatIDs {
+		terminals.closeChat(chatID)
+	}
+}
+
+// parseTerminalKeys replaces the special keys in keys with what a terminal
+// sends for them. Unknown names in angle brackets are left as they are.
+func parseTerminalKeys(keys string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(keys, '<')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(keys[i:], '>')
+		if j < 0 {
+			break
+		}
+		b.WriteString(keys[:i])
+		key := keys[i+1 : i+j]
+		if seq, ok := terminalKeys[key]; ok {
+			b.WriteString(seq)
+		} else if len(key) == 3 && (key[:2] == "C-" || key[:2] == "c-") && isCtrlKey(key[2]) {
+			b.WriteByte(key[2] & 0x1f)
+		} else {
+			// Not a key, but the next '<' might start one.
+			b.WriteByte('<')
+			keys = keys[i+1:]
+			continue
+		}
+		keys = keys[i+j+1:]
+	}
+	b.WriteString(keys)
+	return b.String()
+}
+
+func isCtrlKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte("@[\\]^_", c) >= 0
+}
+
+// terminalManager tracks the terminal sessions of each chat.
+type terminalManager struct {
+	mu    sync.Mutex
+	chats map[string]map[string]*Terminal
+}
+
+var terminals = &terminalManager{chats: map[string]map[string]*Terminal{}}
+
+func (m *terminalManager) open(ws *Workspace, name, command string, cols, rows int) (*Terminal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := m.chats[ws.ChatID]
+	if sessions == nil {
+		sessions = map[string]*Terminal{}
+		m.chats[ws.ChatID] = sessions
+	}
+	if old, ok := sessions[name]; ok {
+		if old.running() {
+			return nil, fmt.Errorf("terminal %s is already open", name)
+		}
+		old.close()
+	}
+
+	t := &Terminal{
+		chatID:  ws.ChatID,
+		name:    name,
+		command: command,
+		cols:    cols,
+		rows:    rows,
+		done:    make(chan struct{}),
+		subs:    map[chan struct{}]bool{},
+	}
+	t.cmd = exec.Command("bash", "-c", command)
+	t.cmd.Dir = ws.Dir
+	t.cmd.Env = append(os.Environ(), "TERM=xterm")
+	ptmx, err := pty.StartWithSize(t.cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if err != nil {
+		if errors.Is(err, pty.ErrUnsupported) {
+			return nil, errors.New("terminals are not supported on this platform")
+		}
+		return nil, err
+	}
+	t.pty = ptmx
+	// Replies to queries, such as the cursor position, go back to the program.
+	t.vt = vt10x.New(vt10x.WithSize(cols, rows), vt10x.WithWriter(ptmx))
+	t.started = time.Now()
+	sessions[name] = t
+
+	go t.read()
+	go func() {
+		t.cmd.Wait()
+		t.mu.Lock()
+		code := t.cmd.ProcessState.ExitCode()
+		t.exitCode = &code
+		t.mu.Unlock()
+		close(t.done)
+		t.notify()
+	}()
+
+	return t, nil
+}
+
+func (m *terminalManager) get(chatID, name string) (*Terminal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.chats[chatID][name]
+	if !ok {
+		return nil, fmt.Errorf("no terminal named %s", name)
+	}
+	return t, nil
+}
+
+func (m *terminalManager) remove(chatID, name string) (*Terminal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.chats[chatID][name]
+	if !ok {
+		return nil, fmt.Errorf("no terminal named %s", name)
+	}
+	delete(m.chats[chatID], name)
+	return t, nil
+}
+
+func (m *terminalManager) list(chatID string) []*TerminalInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := []*TerminalInfo{}
+	for _, t := range m.chats[chatID] {
+		infos = append(infos, t.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Started.Before(infos[j].Started) })
+	return infos
+}
+
+// closeChat closes the terminal sessions of a chat and forgets them.
+func (m *terminalManager) closeChat(chatID string) {
+	m.mu.Lock()
+	sessions := m.chats[chatID]
+	delete(m.chats, chatID)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.close()
+		}()
+	}
+	wg.Wait()
+}
+
+// Terminal is a program running in a pseudo-terminal, whose output is kept
+// on an emulated screen.
+type Terminal struct {
+	chatID  string
+	name    string
+	command string
+	cmd     *exec.Cmd
+	pty     *os.File
+	vt      vt10x.Terminal
+	started time.Time
+	done    chan struct{}
+
+	mu         sync.Mutex
+	cols, rows int
+	exitCode   *int
+	// output counts the reads from the terminal, to tell when it changed.
+	output int
+	subs   map[chan struct{}]bool
+}
+
+// Write types p into the terminal.
+func (t *Terminal) Write(p []byte) (int, error) {
+	if !t.running() {
+		return 0, fmt.Errorf("terminal %s has exited", t.name)
+	}
+	return t.pty.Write(p)
+}
+
+// Resize changes the size of the terminal.
+func (t *Terminal) Resize(cols, rows int) error {
+	if cols < 1 || rows < 1 || cols > maxTerminalSize || rows > maxTerminalSize {
+		return fmt.Errorf("invalid terminal size %dx%d: must be between 1 and %d", cols, rows, maxTerminalSize)
+	}
+	t.mu.Lock()
+	t.cols, t.rows = cols, rows
+	t.mu.Unlock()
+	t.vt.Resize(cols, rows)
+	if err := pty.Setsize(t.pty, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}); err != nil {
+		return err
+	}
+	t.notify()
+	return nil
+}
+
+// Screen returns what the terminal currently displays.
+func (t *Terminal) Screen() *TerminalScreen {
+	t.mu.Lock()
+	exitCode := t.exitCode
+	t.mu.Unlock()
+
+	t.vt.Lock()
+	cols, rows := t.vt.Size()
+	lines := make([]string, rows)
+	var line []rune
+	for y := 0; y < rows; y++ {
+		line = line[:0]
+		for x := 0; x < cols; x++ {
+			c := t.vt.Cell(x, y).Char
+			if c == 0 {
+				c = ' '
+			}
+			line = append(line, c)
+		}
+		lines[y] = strings.TrimRight(string(line), " ")
+	}
+	cursor := t.vt.Cursor()
+	title := t.vt.Title()
+	t.vt.Unlock()
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return &TerminalScreen{
+		Name:     t.name,
+		Title:    title,
+		Screen:   strings.Join(lines, "\n"),
+		CursorX:  cursor.X,
+		CursorY:  cursor.Y,
+		Running:  exitCode == nil,
+		ExitCode: exitCode,
+	}
+}
+
+// Changes returns a channel that receives a value when the screen changes,
+// and a function to stop watching. Changes that happen while a value is
+// pending are merged into it.
+func (t *Terminal) Changes() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	t.mu.Lock()
+	t.subs[ch] = true
+	t.mu.Unlock()
+	return ch, func() {
+		t.mu.Lock()
+		delete(t.subs, ch)
+		t.mu.Unlock()
+	}
+}
+
+// Done returns a channel that is closed when the program exits.
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// read feeds the output of the program to the emulated screen, until it
+// exits or the terminal is closed.
+func (t *Terminal) read() {
+	buf := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := t.pty.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			// The emulator leaves incomplete characters at the end unread.
+			written, _ := t.vt.Write(pending)
+			pending = append(pending[:0], pending[written:]...)
+			t.mu.Lock()
+			t.output++
+			t.mu.Unlock()
+			t.notify()
+		}
+		if err != nil {
+			// Linux returns EIO rather than EOF once the program has exited.
+			return
+		}
+	}
+}
+
+func (t *Terminal) notify() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Terminal) version() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.output
+}
+
+// settle waits until the terminal has produced output since version and
+// then stayed quiet, the program exits, or wait is over.
+func (t *Terminal) settle(since int, wait time.Duration) {
+	deadline := time.Now().Add(min(wait, maxTerminalWait))
+	last := since
+	quietSince := time.Now()
+	for time.Now().Before(deadline) {
+		select {
+		case <-t.done:
+			// Give the last of the output a moment to be read.
+			time.Sleep(50 * time.Millisecond)
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		v := t.version()
+		if v != last {
+			last = v
+			quietSince = time.Now()
+		} else if v != since && time.Since(quietSince) >= terminalSettle {
+			return
+		}
+	}
+}
+
+func (t *Terminal) running() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close hangs up the terminal, kills the program if it doesn't exit in time,
+// and waits for it.
+func (t *Terminal) close() {
+	t.pty.Close()
+	select {
+	case <-t.done:
+	case <-time.After(processStopTimeout):
+		terminateProcess(t.cmd, true)
+		<-t.done
+	}
+}
+
+func (t *Terminal) info() *TerminalInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &TerminalInfo{
+		Name:     t.name,
+		Command:  t.command,
+		Cols:     t.cols,
+		Rows:     t.rows,
+		Running:  t.exitCode == nil,
+		ExitCode: t.exitCode,
+		Started:  t.started,
+		Path:     fmt.Sprintf("/terminals/%s/%s", t.chatID, t.name),
+	}
+}
@@ -0,0 +1,556 @@
+package toolfns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TestReport struct {
+	Runner   string  `json:"runner"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	Duration float64 `json:"duration"`
+	ExitCode int     `json:"exit_code"`
+	TimedOut bool    `json:"timed_out,omitempty"`
+	// Failures holds the failed tests, with their output.
+	Failures []*TestResult `json:"failures"`
+	// Tests holds the passed and skipped tests, if all of them were asked for.
+	Tests []*TestResult `json:"tests,omitempty"`
+	// Output is the end of the output of the run, when its results couldn't
+	// be parsed, or it failed outside of any test.
+	Output string `json:"output,omitempty"`
+}
+
+type TestResult struct {
+	// Package is the Go package, Python module or JavaScript file of the test.
+	Package  string  `json:"package,omitempty"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"` // "pass", "fail" or "skip"
+	Duration float64 `json:"duration"`
+	Output   string  `json:"output,omitempty"`
+	// File and Line point at where the test failed, when known.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+const (
+	// maxTestFailures is the number of failures returned with their output.
+	maxTestFailures = 50
+	// maxTestOutput is the size of the output kept per failure, and of the
+	// output of the run. The end is kept, as that's where errors are.
+	maxTestOutput = 4 * 1024
+)
+
+// Runs the tests of a Go, Python or JavaScript project in the workspace, and returns a summary with the output and location of each failure.
+// @mutates
+// runner: The test runner. auto picks go if there is a go.mod, npm if there is a package.json, and pytest otherwise. npm understands Jest, Vitest and node --test. @enum auto, go, pytest, npm @default auto
+// path: The directory of the project, relative to the workspace. @default .
+// filter: Only run the tests whose name matches: a regular expression for go and npm, or a -k expression for pytest. @optional
+// all: List the passed and skipped tests too, not only the failures. @optional
+// timeout: Maximum time the tests may run, in seconds. @min 1 @max 3600 @default 300
+func Test(ws *Workspace, runner string, path string, filter string, all bool, timeout int) (*TestReport, error) {
+	dir, err := ws.Path(path)
+	if err != nil {
+		return nil, err
+	}
+	if runner == "auto" {
+		if runner, err = detectTestRunner(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := os.CreateTemp("", "llum-tests-*")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	var (
+		name string
+		args []string
+		env  []string
+	)
+	switch runner {
+	case "go":
+		name, args = "go", []string{"test", "-json"}
+		if filter != "" {
+			args = append(args, "-run", filter)
+		}
+		args = append(args, "./...")
+	case "pytest":
+		// The xunit1 flavor of JUnit XML has the file and line of each test.
+		name, args = config.Code.python(), []string{"-m", "pytest", "-q", "-o", "junit_family=xunit1", "--junitxml=" + out.Name()}
+		if filter != "" {
+			args = append(args, "-k", filter)
+		}
+	case "npm":
+		name, args, env, err = npmTestCommand(dir, filter, out.Name())
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown test runner %q", runner)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	cmd := projectCommand(ctx, dir, name, args...)
+	cmd.Env = append(cmd.Env, env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	report := &TestReport{Runner: runner, Failures: []*TestResult{}}
+	start := time.Now()
+	err = cmd.Run()
+	report.Duration = time.Since(start).Seconds()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		report.TimedOut = true
+		report.ExitCode = -1
+	case errors.As(err, &exitErr):
+		report.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, err
+	}
+
+	var results []*TestResult
+	switch {
+	case runner == "go":
+		results = parseGoTestJSON(&stdout, stderr.String())
+	default:
+		data, _ := os.ReadFile(out.Name())
+		var parseErr error
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			results, parseErr = parseJUnitXML(data)
+		} else if len(data) > 0 {
+			results, parseErr = parseJestJSON(data)
+		}
+		if parseErr != nil {
+			return nil, fmt.Errorf("reading test results: %w", parseErr)
+		}
+	}
+
+	for _, r := range results {
+		if r.File != "" {
+			r.File = relativeTestPath(dir, r.File)
+		}
+		if filepath.IsAbs(r.Package) {
+			r.Package = relativeTestPath(dir, r.Package)
+		}
+		switch r.Status {
+		case "pass":
+			report.Passed++
+		case "fail":
+			report.Failed++
+		case "skip":
+			report.Skipped++
+		}
+		if r.Status == "fail" {
+			if len(report.Failures) < maxTestFailures {
+				r.Output = truncateHead(r.Output, maxTestOutput)
+				report.Failures = append(report.Failures, r)
+			}
+		} else if all {
+			r.Output = ""
+			report.Tests = append(report.Tests, r)
+		}
+	}
+
+	// Builds that fail, and crashes outside of tests, only show in the output.
+	if (report.ExitCode != 0 && report.Failed == 0) || len(results) == 0 {
+		report.Output = truncateHead(strings.TrimSpace(stdout.String()+"\n"+stderr.String()), maxTestOutput)
+	}
+	return report, nil
+}
+
+// projectCommand returns a command that runs name in the project dir, as in
+// CI and without colors. It runs in its own process group, which is killed
+// when ctx is done.
+func projectCommand(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CI=1", "NO_COLOR=1", "FORCE_COLOR=0")
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		terminateProcess(cmd, true)
+		return nil
+	}
+	// Children that outlive the process may hold on to its output.
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// detectTestRunner picks the test runner of the project in dir.
+func detectTestRunner(dir string) (string, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	switch {
+	case exists("go.mod"):
+		return "go", nil
+	case exists("package.json"):
+		return "npm", nil
+	case exists("pytest.ini"), exists("pyproject.toml"), exists("setup.py"), exists("setup.cfg"), exists("tox.ini"), exists("conftest.py"):
+		return "pytest", nil
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.py")); len(matches) > 0 {
+		return "pytest", nil
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "tests", "*.py")); len(matches) > 0 {
+		return "pytest", nil
+	}
+	return "", errors.New("couldn't tell how to run the tests: pass a runner")
+}
+
+// npmTestCommand returns the command running `npm test` in dir, with the
+// arguments that make its runner write its results to out.
+func npmTestCommand(dir, filter, out string) (name string, args, env []string, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return "", nil, nil, fmt.Errorf("reading package.json: %w", err)
+	}
+	script, ok := pkg.Scripts["test"]
+	if !ok {
+		return "", nil, nil, errors.New("package.json has no test script")
+	}
+
+	name, args = "npm", []string{"test", "--"}
+	switch {
+	case strings.Contains(script, "vitest"):
+		args = append(args, "--run", "--reporter=json", "--outputFile="+out)
+		if filter != "" {
+			args = append(args, "-t", filter)
+		}
+	case strings.Contains(script, "jest"):
+		args = append(args, "--json", "--outputFile="+out)
+		if filter != "" {
+			args = append(args, "-t", filter)
+		}
+	case strings.Contains(script, "node --test"):
+		// Arguments after the files would be taken for more files.
+		args = args[:1]
+		if filter != "" {
+			return "", nil, nil, errors.New("filter isn't supported with node --test")
+		}
+		env = append(env, "NODE_OPTIONS=--test-reporter=junit --test-reporter-destination="+out)
+	default:
+		// The results can't be parsed, but the output is still returned.
+		args = args[:1]
+	}
+	return name, args, env, nil
+}
+
+// goTestEvent is a line of `go test -json`.
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+var goFileLineRegex = regexp.MustCompile(`(?m)^\s*([^\s:]+\.go):(\d+):`)
+
+// parseGoTestJSON reads the output of `go test -json`. Before Go 1.24, build
+// errors are only written to stderr, under a "# package" line.
+func parseGoTestJSON(r io.Reader, stderr string) []*TestResult {
+	buildErrors := map[string]string{}
+	for _, block := range strings.Split("\n"+stderr, "\n# ")[1:] {
+		header, body, _ := strings.Cut(block, "\n")
+		pkg, _, _ := strings.Cut(header, " ")
+		buildErrors[pkg] += body
+	}
+
+	var results []*TestResult
+	tests := map[[2]string]*TestResult{}
+	output := map[[2]string]*strings.Builder{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		key := [2]string{ev.Package, ev.Test}
+		switch ev.Action {
+		case "build-output":
+			if pkg, _, _ := strings.Cut(ev.ImportPath, " "); !strings.HasPrefix(ev.Output, "# ") {
+				buildErrors[pkg] += ev.Output
+			}
+		case "output":
+			if output[key] == nil {
+				output[key] = &strings.Builder{}
+			}
+			output[key].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			status := ev.Action
+			if ev.Test == "" {
+				// Only report packages that failed outside of their tests.
+				if status != "fail" || hasFailedTest(results, ev.Package) {
+					continue
+				}
+			}
+			r := &TestResult{Package: ev.Package, Name: ev.Test, Status: status, Duration: ev.Elapsed}
+			if b := output[key]; b != nil {
+				r.Output = b.String()
+			}
+			if ev.Test == "" && buildErrors[ev.Package] != "" {
+				r.Output = buildErrors[ev.Package] + r.Output
+			}
+			if m := goFileLineRegex.FindAllStringSubmatch(r.Output, -1); len(m) > 0 {
+				r.File = m[0][1]
+				r.Line, _ = strconv.Atoi(m[0][2])
+			}
+			if tests[key] == nil {
+				results = append(results, r)
+			}
+			tests[key] = r
+		}
+	}
+
+	// Parents fail along with their subtests, which say more.
+	var kept []*TestResult
+	for _, r := range results {
+		if r.Status == "fail" && r.Name != "" && hasFailedSubtest(results, r) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+func hasFailedTest(results []*TestResult, pkg string) bool {
+	for _, r := range results {
+		if r.Package == pkg && r.Status == "fail" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFailedSubtest(results []*TestResult, parent *TestResult) bool {
+	for _, r := range results {
+		if r.Package == parent.Package && r.Status == "fail" && strings.HasPrefix(r.Name, parent.Name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// junitTestSuite is a test suite in JUnit XML, as written by pytest and
+// node --test. Suites may be nested, or hold test cases directly.
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Suites    []junitTestSuite `xml:"testsuite"`
+	TestCases []struct {
+		ClassName string  `xml:"classname,attr"`
+		Name      string  `xml:"name,attr"`
+		File      string  `xml:"file,attr"`
+		Line      int     `xml:"line,attr"`
+		Time      float64 `xml:"time,attr"`
+		Failure   *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"failure"`
+		Error *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"error"`
+		Skipped   *struct{} `xml:"skipped"`
+		SystemOut string    `xml:"system-out"`
+		SystemErr string    `xml:"system-err"`
+	} `xml:"testcase"`
+}
+
+var (
+	pythonFileLineRegex = regexp.MustCompile(`(?m)^([^\s:]+\.py):(\d+): `)
+	// ESM stack frames are file:// URLs, and those of Node.js internals start
+	// with node:, whose schemes must not be taken for a drive letter. File
+	// URLs of Windows paths have a slash before the drive.
+	jsFileLineRegex = regexp.MustCompile(`\(?(file://|node:)?(/?(?:[A-Za-z]:)?[^\s():]+\.[cm]?[jt]sx?):(\d+):\d+\)?`)
+)
+
+func parseJUnitXML(data []byte) ([]*TestResult, error) {
+	var root junitTestSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var results []*TestResult
+	var walk func(s junitTestSuite, prefix string)
+	walk = func(s junitTestSuite, prefix string) {
+		for _, tc := range s.TestCases {
+			r := &TestResult{Package: tc.ClassName, Name: prefix + tc.Name, Status: "pass", Duration: tc.Time, File: tc.File, Line: tc.Line}
+			failure := tc.Failure
+			if failure == nil {
+				failure = tc.Error
+			}
+			switch {
+			case failure != nil:
+				r.Status = "fail"
+				r.Output = strings.TrimSpace(failure.Text)
+				if r.Output == "" {
+					r.Output = failure.Message
+				}
+				if extra := strings.TrimSpace(tc.SystemOut + "\n" + tc.SystemErr); extra != "" {
+					r.Output += "\n\n" + extra
+				}
+				// Point at where it failed rather than where the test starts.
+				if m := pythonFileLineRegex.FindAllStringSubmatch(r.Output, -1); len(m) > 0 {
+					r.File = m[len(m)-1][1]
+					r.Line, _ = strconv.Atoi(m[len(m)-1][2])
+				} else if file, line := jsFailureLocation(r.Output); file != "" {
+					r.File, r.Line = file, line
+				}
+			case tc.Skipped != nil:
+				r.Status = "skip"
+			}
+			results = append(results, r)
+		}
+		for _, child := range s.Suites {
+			childPrefix := prefix
+			// pytest puts all tests in a suite named after itself.
+			if child.Name != "" && child.Name != "pytest" {
+				childPrefix = prefix + child.Name + " > "
+			}
+			walk(child, childPrefix)
+		}
+	}
+	walk(root, "")
+	return results, nil
+}
+
+// jestResults are the results written by `jest --json`, and by Vitest's json
+// reporter.
+type jestResults struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Message          string `json:"message"`
+		Status           string `json:"status"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Title           string   `json:"title"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"`
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+func parseJestJSON(data []byte) ([]*TestResult, error) {
+	var jr jestResults
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return nil, err
+	}
+
+	var results []*TestResult
+	for _, file := range jr.TestResults {
+		for _, a := range file.AssertionResults {
+			r := &TestResult{Package: file.Name, Name: a.FullName}
+			if r.Name == "" {
+				r.Name = a.Title
+			}
+			if a.Duration != nil {
+				r.Duration = *a.Duration / 1000
+			}
+			switch a.Status {
+			case "passed":
+				r.Status = "pass"
+			case "failed":
+				r.Status = "fail"
+				r.Output = strings.Join(a.FailureMessages, "\n")
+				if f, line := jsFailureLocation(r.Output); f != "" {
+					r.File, r.Line = f, line
+				} else if a.Location != nil {
+					r.File, r.Line = file.Name, a.Location.Line
+				}
+			default:
+				r.Status = "skip"
+			}
+			results = append(results, r)
+		}
+		// A file that fails to load has no assertions, only a message.
+		if file.Status == "failed" && len(file.AssertionResults) == 0 {
+			r := &TestResult{Package: file.Name, Status: "fail", Output: file.Message}
+			r.File, r.Line = jsFailureLocation(file.Message)
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// jsFailureLocation returns the first frame of a JavaScript stack trace that
+// isn't in Node.js itself or in a dependency.
+func jsFailureLocation(stack string) (string, int) {
+	for _, m := range jsFileLineRegex.FindAllStringSubmatch(stack, -1) {
+		file := m[2]
+		if m[1] == "node:" || strings.Contains(file, "node_modules") {
+			continue
+		}
+		if m[1] == "file://" {
+			if unescaped, err := url.PathUnescape(file); err == nil {
+				file = unescaped
+			}
+			if len(file) > 2 && file[0] == '/' && file[2] == ':' {
+				file = filepath.FromSlash(file[1:])
+			}
+		}
+		line, _ := strconv.Atoi(m[3])
+		return file, line
+	}
+	return "", 0
+}
+
+// relativeTestPath makes absolute paths relative to the project dir.
+func relativeTestPath(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// truncateHead keeps the last max bytes of s.
+func truncateHead(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[len(s)-max:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i < 200 {
+		s = s[i+1:]
+	}
+	return "[…]\n" + strings.ToValidUTF8(s, "")
+}
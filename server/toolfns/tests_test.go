@@ -0,0 +1,28 @@
+package toolfns
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJSFailureLocation(t *testing.T) {
+	tests := []struct {
+		stack string
+		file  string
+		line  int
+	}{
+		{"at Object.<anonymous> (/app/src/sum.test.js:3:15)", "/app/src/sum.test.js", 3},
+		{"at file:///app/src/sum.test.mjs:7:3", "/app/src/sum.test.mjs", 7},
+		{"at file:///app/my%20dir/a.test.ts:2:1", "/app/my dir/a.test.ts", 2},
+		{"at file:///C:/app/a.test.js:4:9", filepath.FromSlash("C:/app/a.test.js"), 4},
+		{`at Object.<anonymous> (C:\app\a.test.js:5:1)`, `C:\app\a.test.js`, 5},
+		{"at node:internal/modules/run_main.js:1:2\n    at /app/b.test.js:9:1", "/app/b.test.js", 9},
+		{"at /app/node_modules/x/index.js:1:1", "", 0},
+	}
+	for _, tt := range tests {
+		file, line := jsFailureLocation(tt.stack)
+		if file != tt.file || line != tt.line {
+			t.Errorf("jsFailureLocation(%q) = %q, %d, want %q, %d", tt.stack, file, line, tt.file, tt.line)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package toolfns
 
 import (
+	"context"
+	"fmt"
 	"github.com/byte-sat/llum-tools/tools"
 	"log"
 	"os/exec"
@@ -11,39 +13,199 @@ import (
 
 var ToolGroups []*Group
 
+// injector declares the types that tool functions can take as leading
+// parameters. The values themselves are provided on each call.
+var injector, _ = tools.Inject(
+	func() *Workspace { return nil },
+	func() *UI { return nil },
+)
+
 func init() {
 	ToolGroups = []*Group{
 		NewGroup("System",
 			Shell,
 		),
+		NewGroup("Output",
+			ReadOutput,
+		),
+		NewGroup("Files",
+			ShareFile,
+		),
+		NewGroup("Process",
+			StartProcess,
+			ProcessLogs,
+			ProcessStatus,
+			StopProcess,
+		),
+		NewGroup("Terminal",
+			OpenTerminal,
+			WriteTerminal,
+			ReadTerminal,
+			ListTerminals,
+			CloseTerminal,
+		),
+		NewGroup("Git",
+			GitStatus,
+			GitDiff,
+			GitLog,
+			GitShow,
+			GitBlame,
+			GitBranch,
+			GitCommit,
+			GitCheckout,
+		),
+		NewGroup("Database",
+			DatabaseConnections,
+			DatabaseTables,
+			DatabaseSchema,
+			DatabaseQuery,
+		),
+		NewGroup("SSH",
+			SSHHosts,
+			SSHRun,
+			SSHUpload,
+			SSHDownload,
+		),
+		NewGroup("HTTP",
+			HTTPDestinations,
+			HTTPRequest,
+		),
+		NewGroup("Code",
+			RunCode,
+		),
+		NewGroup("Test",
+			Test,
+		),
+		NewGroup("Diagnostics",
+			Diagnostics,
+		),
+		NewGroup("Search",
+			Search,
+		),
+		NewGroup("Documents",
+			Extract,
+		),
+		NewGroup("Image",
+			ImageInfo,
+			ImageResize,
+			ImageCrop,
+			ImageRotate,
+			ImageConvert,
+			ImageAnnotate,
+		),
+		NewGroup("Chart",
+			Chart,
+		),
+		NewGroup("Memory",
+			Remember,
+			Recall,
+			ListMemories,
+			Forget,
+		),
 	}
 }
 
 type Group struct {
-	Name string      `json:"name"`
-	Repo *tools.Repo `json:"-"`
+	Name   string      `json:"name"`
+	Repo   *tools.Repo `json:"-"`
+	Schema []Function  `json:"schema"`
+
+	functions map[string]*function
+	// plugin runs the tools of groups loaded from WebAssembly modules, which
+	// have no Repo.
+	plugin *plugin
+	hooks  []Hook
 }
 
 func NewGroup(name string, fns ...any) *Group {
-	repo, err := tools.New(nil, fns...)
+	repo, err := tools.New(injector, fns...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &Group{
-		Name: name,
-		Repo: repo,
+
+	g := &Group{
+		Name:      name,
+		Repo:      repo,
+		functions: make(map[string]*function, len(fns)),
+	}
+	for i, generated := range repo.Schema() {
+		fn, err := newFunction(fns[i], generated)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.Schema = append(g.Schema, fn.schema)
+		g.functions[fn.schema.Name] = fn
+	}
+	return g
+}
+
+// Has reports whether the group contains the named tool.
+func (g *Group) Has(name string) bool {
+	_, ok := g.functions[name]
+	return ok
+}
+
+// Mutates reports whether the named tool may change the files of a workspace,
+// and so needs a checkpoint before it runs.
+func (g *Group) Mutates(name string) bool {
+	fn, ok := g.functions[name]
+	return ok && fn.mutates
+}
+
+// Invoke calls the named tool through Repo, or the plugin of the group, after
+// running it through the registered hooks, filling in the defaults of omitted
+// optional arguments and validating the annotated constraints.
+func (g *Group) Invoke(ctx context.Context, ws *Workspace, id string, name string, args map[string]any) (any, error) {
+	call := &Call{
+		Context:   ctx,
+		ID:        id,
+		ChatID:    ws.ChatID,
+		Group:     g.Name,
+		Name:      name,
+		Args:      args,
+		Workspace: ws,
+	}
+	return g.chain(g.invoke)(call)
+}
+
+func (g *Group) invoke(call *Call) (any, error) {
+	args := call.Args
+	fn, ok := g.functions[call.Name]
+	if ok {
+		var err error
+		args, err = fn.prepare(args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.plugin != nil {
+		if !ok {
+			return nil, fmt.Errorf("tool not found: %s", call.Name)
+		}
+		return g.plugin.call(call.Workspace, fn, args)
+	}
+	ui := &UI{ctx: call.Context, chatID: call.ChatID, callID: call.ID, tool: call.Name}
+	inj, err := tools.Inject(call.Workspace, ui)
+	if err != nil {
+		return nil, err
 	}
+	return g.Repo.Invoke(inj, call.Name, args)
 }
 
 type ContentTypeResponse struct {
+	// Name is the name of the file the content comes from, if any.
+	Name        string `json:"name,omitempty"`
 	ContentType string `json:"contentType"`
 	Content     string `json:"content"`
 }
 
 // Executes the given bash command and returns the output of the command.
+// @mutates
 // command: The bash command to execute.
-func Shell(command string) string {
+func Shell(ws *Workspace, command string) string {
 	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = ws.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return err.Error() + "\n" + string(out)
@@ -0,0 +1,251 @@
+package toolfns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UI lets a tool ask the user questions while it runs, and wait for the
+// answers. Tools receive it by taking a *UI as a leading parameter, after the
+// *Workspace. The questions are shown by the UI of the chat, which watches
+// them over a WebSocket.
+type UI struct {
+	// ctx is the context of the call, which stops the wait for an answer when
+	// the client goes away.
+	ctx    context.Context
+	chatID string
+	callID string
+	tool   string
+}
+
+// Question is a question asked by a tool, as sent to the UI.
+type Question struct {
+	ID string `json:"id"`
+	// CallID is the ID of the tool call that asks the question.
+	CallID string `json:"call_id,omitempty"`
+	Tool   string `json:"tool"`
+	// Kind is "prompt" for a free text answer, "confirm" for yes or no, or
+	// "choice" for one of Choices.
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	Choices []string `json:"choices,omitempty"`
+	// Default is the text the answer to a prompt starts with.
+	Default string `json:"default,omitempty"`
+}
+
+// Answer is the answer of the user to a question, as sent by the UI.
+type Answer struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+	// Cancelled is set when the user dismissed the question.
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// questionTimeout is how long a tool waits for an answer, including for the
+// UI to connect.
+const questionTimeout = 10 * time.Minute
+
+var (
+	ErrQuestionCancelled = errors.New("the user dismissed the question")
+	ErrNoAnswer          = errors.New("the user didn't answer in time")
+	ErrQuestionNotFound  = errors.New("question not found")
+)
+
+// Prompt asks the user for some text, and returns what they typed.
+func (u *UI) Prompt(message, def string) (string, error) {
+	a, err := u.ask(Question{Kind: "prompt", Message: message, Default: def})
+	if err != nil {
+		return "", err
+	}
+	return a.Value, nil
+}
+
+// Confirm asks the user a yes or no question.
+func (u *UI) Confirm(message string) (bool, error) {
+	a, err := u.ask(Question{Kind: "confirm", Message: message, Choices: []string{"Yes", "No"}})
+	if err != nil {
+		return false, err
+	}
+	return a.Value == "Yes", nil
+}
+
+// Choose asks the user to pick one of choices, and returns the index of the
+// one picked.
+func (u *UI) Choose(message string, choices []string) (int, error) {
+	if len(choices) == 0 {
+		return 0, errors.New("no choices")
+	}
+	a, err := u.ask(Question{Kind: "choice", Message: message, Choices: choices})
+	if err != nil {
+		return 0, err
+	}
+	return slices.Index(choices, a.Value), nil
+}
+
+// ask shows q in the UI of the chat, and blocks until it is answered, the
+// call is canceled, or questionTimeout passes.
+func (u *UI) ask(q Question) (Answer, error) {
+	ctx := u.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, questionTimeout)
+	defer cancel()
+
+	q.CallID = u.callID
+	q.Tool = u.tool
+	pending := questions.add(u.chatID, q)
+	defer questions.remove(u.chatID, pending.ID)
+
+	select {
+	case a := <-pending.answer:
+		if a.Cancelled {
+			return a, ErrQuestionCancelled
+		}
+		return a, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Answer{}, ErrNoAnswer
+		}
+		return Answer{}, ctx.Err()
+	}
+}
+
+// PendingQuestions returns the unanswered questions of a chat, oldest first.
+func PendingQuestions(chatID string) []Question {
+	return questions.pending(chatID)
+}
+
+// WatchQuestions returns a channel that receives a value when the questions of
+// a chat change, and a function to stop watching.
+func WatchQuestions(chatID string) (<-chan struct{}, func()) {
+	return questions.watch(chatID)
+}
+
+// AnswerQuestion passes the answer of the user to the tool that asked.
+func AnswerQuestion(chatID string, a Answer) error {
+	return questions.answer(chatID, a)
+}
+
+// questionBroker keeps the questions waiting for an answer, per chat.
+type questionBroker struct {
+	mu     sync.Mutex
+	next   int
+	byChat map[string][]*pendingQuestion
+	subs   map[string]map[chan struct{}]bool
+}
+
+type pendingQuestion struct {
+	Question
+	answer chan Answer
+}
+
+var questions = &questionBroker{
+	byChat: map[string][]*pendingQuestion{},
+	subs:   map[string]map[chan struct{}]bool{},
+}
+
+func (b *questionBroker) add(chatID string, q Question) *pendingQuestion {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	q.ID = "q-" + strconv.Itoa(b.next)
+	p := &pendingQuestion{Question: q, answer: make(chan Answer, 1)}
+	b.byChat[chatID] = append(b.byChat[chatID], p)
+	b.notify(chatID)
+	return p
+}
+
+func (b *questionBroker) remove(chatID, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	qs := b.byChat[chatID]
+	for i, p := range qs {
+		if p.ID == id {
+			qs = append(qs[:i:i], qs[i+1:]...)
+			break
+		}
+	}
+	if len(qs) == 0 {
+		delete(b.byChat, chatID)
+	} else {
+		b.byChat[chatID] = qs
+	}
+	b.notify(chatID)
+}
+
+func (b *questionBroker) pending(chatID string) []Question {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	qs := make([]Question, 0, len(b.byChat[chatID]))
+	for _, p := range b.byChat[chatID] {
+		qs = append(qs, p.Question)
+	}
+	return qs
+}
+
+func (b *questionBroker) answer(chatID string, a Answer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.byChat[chatID] {
+		if p.ID != a.ID {
+			continue
+		}
+		if !a.Cancelled && len(p.Choices) > 0 && !slices.Contains(p.Choices, a.Value) {
+			return fmt.Errorf("answer %q is not one of the choices", a.Value)
+		}
+		select {
+		case p.answer <- a:
+		default:
+			// Already answered, from another window.
+		}
+		return nil
+	}
+	return ErrQuestionNotFound
+}
+
+// cancelChat dismisses the questions of a chat, whose workspace is gone.
+func (b *questionBroker) cancelChat(chatID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.byChat[chatID] {
+		select {
+		case p.answer <- Answer{ID: p.ID, Cancelled: true}:
+		default:
+		}
+	}
+}
+
+func (b *questionBroker) watch(chatID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	if b.subs[chatID] == nil {
+		b.subs[chatID] = map[chan struct{}]bool{}
+	}
+	b.subs[chatID][ch] = true
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs[chatID], ch)
+		if len(b.subs[chatID]) == 0 {
+			delete(b.subs, chatID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// notify tells the watchers of chatID that its questions changed. b.mu must
+// be held.
+func (b *questionBroker) notify(chatID string) {
+	for ch := range b.subs[chatID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package toolfns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// nextQuestion waits for a question to be asked in chatID.
+func nextQuestion(t *testing.T, chatID string) Question {
+	t.Helper()
+	changes, stop := WatchQuestions(chatID)
+	defer stop()
+	for {
+		if qs := PendingQuestions(chatID); len(qs) > 0 {
+			return qs[0]
+		}
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no question was asked")
+		}
+	}
+}
+
+func TestUIConfirm(t *testing.T) {
+	ui := &UI{chatID: "ui-test", callID: "call-1", tool: "Deploy"}
+	answered := make(chan bool)
+	go func() {
+		ok, err := ui.Confirm("Deploy to production?")
+		if err != nil {
+			t.Error(err)
+		}
+		answered <- ok
+	}()
+
+	q := nextQuestion(t, "ui-test")
+	if q.Kind != "confirm" || q.CallID != "call-1" || q.Tool != "Deploy" || q.Message != "Deploy to production?" {
+		t.Errorf("question = %+v", q)
+	}
+	if err := AnswerQuestion("ui-test", Answer{ID: q.ID, Value: "Maybe"}); err == nil {
+		t.Error("accepted an answer that isn't one of the choices")
+	}
+	if err := AnswerQuestion("other", Answer{ID: q.ID, Value: "Yes"}); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("answer to the question of another chat: %v", err)
+	}
+	if err := AnswerQuestion("ui-test", Answer{ID: q.ID, Value: "Yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-answered; !ok {
+		t.Error("Confirm = false, want true")
+	}
+	if qs := PendingQuestions("ui-test"); len(qs) != 0 {
+		t.Errorf("questions left after the answer: %+v", qs)
+	}
+}
+
+func TestUIChooseAndPrompt(t *testing.T) {
+	ui := &UI{chatID: "ui-test"}
+	chose := make(chan int)
+	go func() {
+		i, err := ui.Choose("Which region?", []string{"eu", "us"})
+		if err != nil {
+			t.Error(err)
+		}
+		chose <- i
+	}()
+	q := nextQuestion(t, "ui-test")
+	if err := AnswerQuestion("ui-test", Answer{ID: q.ID, Value: "us"}); err != nil {
+		t.Fatal(err)
+	}
+	if i := <-chose; i != 1 {
+		t.Errorf("Choose = %d, want 1", i)
+	}
+
+	typed := make(chan string)
+	go func() {
+		s, err := ui.Prompt("Commit message?", "Fix")
+		if err != nil {
+			t.Error(err)
+		}
+		typed <- s
+	}()
+	q = nextQuestion(t, "ui-test")
+	if q.Kind != "prompt" || q.Default != "Fix" {
+		t.Errorf("question = %+v", q)
+	}
+	if err := AnswerQuestion("ui-test", Answer{ID: q.ID, Value: "Fix the build"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-typed; s != "Fix the build" {
+		t.Errorf("Prompt = %q", s)
+	}
+}
+
+func TestUICancel(t *testing.T) {
+	ui := &UI{chatID: "ui-test"}
+	done := make(chan error)
+	go func() {
+		_, err := ui.Confirm("Delete everything?")
+		done <- err
+	}()
+	q := nextQuestion(t, "ui-test")
+	if err := AnswerQuestion("ui-test", Answer{ID: q.ID, Cancelled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Is(err, ErrQuestionCancelled) {
+		t.Errorf("Confirm = %v, want ErrQuestionCancelled", err)
+	}
+}
+
+func TestUITimeout(t *testing.T) {
+	// A deadline of the call is the same as questionTimeout passing.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ui := &UI{ctx: ctx, chatID: "ui-test"}
+	if _, err := ui.Prompt("Anyone there?", ""); !errors.Is(err, ErrNoAnswer) {
+		t.Errorf("Prompt = %v, want ErrNoAnswer", err)
+	}
+	if qs := PendingQuestions("ui-test"); len(qs) != 0 {
+		t.Errorf("questions left after the timeout: %+v", qs)
+	}
+}
+
+func TestUICallCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ui := &UI{ctx: ctx, chatID: "ui-test"}
+	done := make(chan error)
+	go func() {
+		_, err := ui.Confirm("Still there?")
+		done <- err
+	}()
+	nextQuestion(t, "ui-test")
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Confirm = %v, want context.Canceled", err)
+	}
+	if qs := PendingQuestions("ui-test"); len(qs) != 0 {
+		t.Errorf("questions left after the call was canceled: %+v", qs)
+	}
+}
+
+func TestUIChatRemoved(t *testing.T) {
+	workspaces := &Workspaces{Root: t.TempDir()}
+	ws, err := workspaces.Get("ui-removed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui := &UI{chatID: ws.ChatID}
+	done := make(chan error)
+	go func() {
+		_, err := ui.Prompt("Name?", "")
+		done <- err
+	}()
+	nextQuestion(t, ws.ChatID)
+	if err := workspaces.Remove(ws.ChatID); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Is(err, ErrQuestionCancelled) {
+		t.Errorf("Prompt = %v, want ErrQuestionCancelled", err)
+	}
+}
@@ -0,0 +1,219 @@
+package toolfns
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workspace is the working directory of a single conversation. Tools receive
+// it by taking a *Workspace as their first parameter.
+type Workspace struct {
+	ChatID string
+	Dir    string
+}
+
+// Path resolves a path relative to the workspace, refusing paths that point
+// outside of it.
+func (w *Workspace) Path(rel string) (string, error) {
+	path := filepath.Join(w.Dir, filepath.FromSlash(rel))
+	if path != w.Dir && !strings.HasPrefix(path, w.Dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside of the workspace", rel)
+	}
+	return path, nil
+}
+
+// WorkspaceInfo describes a workspace on disk.
+type WorkspaceInfo struct {
+	ChatID   string    `json:"chat_id"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// DefaultChatID is the workspace used by calls that don't specify a chat_id.
+const DefaultChatID = "default"
+
+var (
+	ErrInvalidChatID     = errors.New("invalid chat_id")
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+)
+
+var chatIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// Workspaces manages one workspace directory per conversation under Root.
+type Workspaces struct {
+	Root string
+
+	mu           sync.Mutex
+	checkpointMu sync.Mutex
+}
+
+// Get returns the workspace of the given conversation, creating it if needed.
+// Each call marks the workspace as used, which keeps it from being collected.
+func (ws *Workspaces) Get(chatID string) (*Workspace, error) {
+	if chatID == "" {
+		chatID = DefaultChatID
+	}
+	dir, err := ws.dir(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return nil, err
+	}
+
+	return &Workspace{ChatID: chatID, Dir: dir}, nil
+}
+
+// Open returns an existing workspace, without marking it as used.
+func (ws *Workspaces) Open(chatID string) (*Workspace, error) {
+	dir, err := ws.dir(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	return &Workspace{ChatID: chatID, Dir: dir}, nil
+}
+
+// List returns all workspaces, most recently used first.
+func (ws *Workspaces) List() ([]WorkspaceInfo, error) {
+	entries, err := os.ReadDir(ws.Root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []WorkspaceInfo{}, nil
+		}
+		return nil, err
+	}
+
+	infos := []WorkspaceInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !chatIDRegex.MatchString(entry.Name()) {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		var size int64
+		filepath.WalkDir(filepath.Join(ws.Root, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				size += info.Size()
+			}
+			return nil
+		})
+
+		infos = append(infos, WorkspaceInfo{
+			ChatID:   entry.Name(),
+			Size:     size,
+			Modified: fi.ModTime(),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Modified.After(infos[j].Modified)
+	})
+	return infos, nil
+}
+
+// Remove stops the background processes and terminals of the given
+// conversation, dismisses the questions its tools are waiting on, and deletes
+// its workspace and checkpoints.
+func (ws *Workspaces) Remove(chatID string) error {
+	dir, err := ws.dir(chatID)
+	if err != nil {
+		return err
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return ErrWorkspaceNotFound
+	}
+	processes.stopChat(chatID)
+	terminals.closeChat(chatID)
+	outputs.removeChat(chatID)
+	questions.cancelChat(chatID)
+	if err := ws.removeCheckpoints(chatID); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// Collect removes the workspaces that haven't been used for longer than maxAge,
+// along with their checkpoints, background processes and terminals, and
+// returns the chat IDs of the removed workspaces.
+func (ws *Workspaces) Collect(maxAge time.Duration) ([]string, error) {
+	infos, err := ws.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, info := range infos {
+		if time.Since(info.Modified) < maxAge {
+			continue
+		}
+
+		dir := filepath.Join(ws.Root, info.ChatID)
+
+		ws.mu.Lock()
+		// Check again under the lock, in case it was used in the meantime.
+		if fi, err := os.Stat(dir); err == nil && time.Since(fi.ModTime()) >= maxAge {
+			processes.stopChat(info.ChatID)
+			terminals.closeChat(info.ChatID)
+			outputs.removeChat(info.ChatID)
+			questions.cancelChat(info.ChatID)
+			err := ws.removeCheckpoints(info.ChatID)
+			if err == nil {
+				err = os.RemoveAll(dir)
+			}
+			if err != nil {
+				ws.mu.Unlock()
+				return removed, err
+			}
+			removed = append(removed, info.ChatID)
+		}
+		ws.mu.Unlock()
+	}
+	return removed, nil
+}
+
+func (ws *Workspaces) dir(chatID string) (string, error) {
+	if err := CheckChatID(chatID); err != nil {
+		return "", err
+	}
+	return filepath.Join(ws.Root, chatID), nil
+}
+
+// CheckChatID returns ErrInvalidChatID if chatID can't name a workspace.
+func CheckChatID(chatID string) error {
+	if !chatIDRegex.MatchString(chatID) {
+		return fmt.Errorf("%w: %q", ErrInvalidChatID, chatID)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zakkor/server/toolfns"
+)
+
+type WorkspaceHandler struct {
+	Workspaces *toolfns.Workspaces
+}
+
+func (wh *WorkspaceHandler) List(w http.ResponseWriter, r *http.Request) {
+	infos, err := wh.Workspaces.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(infos)
+}
+
+// Tarball streams the workspace as a gzipped tarball.
+func (wh *WorkspaceHandler) Tarball(w http.ResponseWriter, r *http.Request) {
+	ws, err := wh.Workspaces.Open(chi.URLParam(r, "chatID"))
+	if err != nil {
+		workspaceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ws.ChatID+".tar.gz"))
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err = filepath.WalkDir(ws.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(ws.Dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		// Headers are already sent, all we can do is cut the archive short.
+		log.Printf("workspace %s: tarball: %v", ws.ChatID, err)
+		return
+	}
+	tw.Close()
+	gw.Close()
+}
+
+func (wh *WorkspaceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := wh.Workspaces.Remove(chi.URLParam(r, "chatID")); err != nil {
+		workspaceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checkpoints lists the checkpoints of a workspace, oldest first. Each is
+// named after the tool call it was taken before.
+func (wh *WorkspaceHandler) Checkpoints(w http.ResponseWriter, r *http.Request) {
+	infos, err := wh.Workspaces.Checkpoints(chi.URLParam(r, "chatID"))
+	if err != nil {
+		workspaceError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(infos)
+}
+
+// Restore puts the workspace back the way it was before a tool call.
+func (wh *WorkspaceHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	info, err := wh.Workspaces.Restore(chi.URLParam(r, "chatID"), chi.URLParam(r, "id"))
+	if err != nil {
+		workspaceError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+// collectWorkspaces periodically removes the workspaces unused for longer than maxAge.
+func collectWorkspaces(workspaces *toolfns.Workspaces, maxAge time.Duration) {
+	for {
+		removed, err := workspaces.Collect(maxAge)
+		if err != nil {
+			log.Printf("collecting workspaces: %v", err)
+		}
+		for _, chatID := range removed {
+			log.Printf("removed unused workspace %s", chatID)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+func workspaceError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), workspaceStatus(err))
+}
+
+// workspaceStatus returns the HTTP status of an error of toolfns.Workspaces.
+func workspaceStatus(err error) int {
+	switch {
+	case errors.Is(err, toolfns.ErrInvalidChatID):
+		return http.StatusBadRequest
+	case errors.Is(err, toolfns.ErrWorkspaceNotFound), errors.Is(err, toolfns.ErrCheckpointNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
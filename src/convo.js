@@ -271,7 +271,7 @@ function messageToOpenAIFormat(msg) {
 		];
 	} else if (msg.role === 'tool') {
 		msgConverted.content =
-			typeof msg.content === 'object' ? JSON.stringify(msg.content) : msg.content;
+			typeof msg.content === 'object' ? stringifyToolContent(msg.content) : msg.content;
 	} else {
 		msgConverted.content = msg.content;
 	}
@@ -298,6 +298,25 @@ function messageToOpenAIFormat(msg) {
 	return msgConverted;
 }
 
+// stringifyToolContent serializes a tool result for the model, leaving out the contents
+// of any files or images it returned as data URLs, which are only meant to be shown to the user.
+function stringifyToolContent(content) {
+	if (typeof content.content === 'string' && content.content.startsWith('data:')) {
+		return JSON.stringify({ ...content, content: '[shown to the user]' });
+	}
+	if (!Array.isArray(content.files)) {
+		return JSON.stringify(content);
+	}
+	return JSON.stringify({
+		...content,
+		files: content.files.map((file) =>
+			file.content && file.content.startsWith('data:')
+				? { ...file, content: '[shown to the user]' }
+				: file
+		),
+	});
+}
+
 function messageToAnthropicFormat(msg) {
 	const msgConverted = {
 		role: msg.role === 'tool' ? 'user' : msg.role,
@@ -323,19 +342,22 @@ function messageToAnthropicFormat(msg) {
 	} else if (msg.role === 'tool') {
 		let content;
 		if (typeof msg.content === 'object') {
-			if (msg.content.contentType === 'image/png') {
+			if (
+				(msg.content.contentType === 'image/png' || msg.content.contentType === 'image/jpeg') &&
+				msg.content.content
+			) {
 				content = [
 					{
 						type: 'image',
 						source: {
 							type: 'base64',
-							media_type: 'image/png',
-							data: msg.content.content.slice('data:image/png;base64,'.length),
+							media_type: msg.content.contentType,
+							data: msg.content.content.slice(msg.content.content.indexOf(',') + 1),
 						},
 					},
 				];
 			} else {
-				content = JSON.stringify(msg.content);
+				content = stringifyToolContent(msg.content);
 			}
 		} else {
 			content = msg.content;
@@ -0,0 +1,35 @@
+import { openServerSocket } from './workspace.js';
+
+/**
+ * Watches the questions that server-side tools of a chat ask the user while they run. The tool
+ * server sends every pending question each time they change, and the tools block until the
+ * answers are sent back.
+ *
+ * @param {string} chatID - The ID of the chat.
+ * @param {(questions: Array<object>) => void} onQuestions - Called with the pending questions,
+ * each with an id, call_id, tool, kind ("prompt", "confirm" or "choice"), message, and choices
+ * or a default answer.
+ * @returns {{ answer: (id: string, value: string | null) => void, close: () => void }} - answer
+ * sends the answer to a question, or dismisses it when value is null.
+ */
+export function watchQuestions(chatID, onQuestions) {
+	const socket = openServerSocket(`/questions/${chatID}`);
+	socket.onmessage = (event) => {
+		onQuestions(JSON.parse(event.data).questions);
+	};
+	socket.onerror = (err) => {
+		console.error('Failed to watch tool questions:', err);
+	};
+
+	return {
+		answer(id, value) {
+			if (socket.readyState !== WebSocket.OPEN) {
+				return;
+			}
+			socket.send(JSON.stringify({ id, value: value ?? '', cancelled: value === null }));
+		},
+		close() {
+			socket.close();
+		},
+	};
+}
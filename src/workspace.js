@@ -0,0 +1,171 @@
+import { get } from 'svelte/store';
+import { remoteServer, toolSchema } from './stores.js';
+
+/**
+ * Puts the chat's workspace on the tool server back the way it was before the given messages,
+ * which are being dropped from the conversation, so that files are rewound along with it.
+ * The server takes a checkpoint before each tool call that may change files, named after the call.
+ *
+ * @param {object} convo - The conversation the messages belong to.
+ * @param {Array<object>} messages - The messages being dropped, in order.
+ */
+export async function rewindWorkspace(convo, messages) {
+	const toolcalls = messages.flatMap((msg) => msg.toolcalls || []);
+	const server = get(remoteServer);
+	for (const toolcall of toolcalls) {
+		try {
+			const resp = await fetch(
+				`${server.address}/workspaces/${convo.id}/checkpoints/${toolcall.id}/restore`,
+				{
+					method: 'POST',
+					headers: {
+						Authorization: `Basic ${server.password}`,
+					},
+				}
+			);
+			// Read-only and client-side tool calls have no checkpoint, so try the next one.
+			if (resp.status !== 404) {
+				return;
+			}
+		} catch (err) {
+			console.error('Failed to rewind workspace:', err);
+			return;
+		}
+	}
+}
+
+/**
+ * Reports whether the conversation has tools enabled that run on the tool server, and so has
+ * a workspace there.
+ *
+ * @param {object} convo - The conversation.
+ * @returns {boolean}
+ */
+export function usesServerTools(convo) {
+	const clientGroup = get(toolSchema).find((g) => g.name === 'Client-side');
+	const clientTools = new Set(
+		(clientGroup?.schema || []).map((t) => t.clientDefinition && t.clientDefinition.name)
+	);
+	return (convo.tools || []).some((name) => !clientTools.has(name));
+}
+
+/**
+ * Uploads files into the chat's workspace on the tool server, so that tools can work on them.
+ *
+ * @param {object} convo - The conversation the files are attached to.
+ * @param {Array<File>} files - The files to upload.
+ * @returns {Promise<Array<object>>} - References to the uploaded files, as returned by the server.
+ */
+export async function uploadFiles(convo, files) {
+	const server = get(remoteServer);
+	const form = new FormData();
+	form.append('chat_id', convo.id);
+	for (const file of files) {
+		form.append('file', file, file.name);
+	}
+	const resp = await fetch(`${server.address}/files`, {
+		method: 'POST',
+		headers: {
+			Authorization: `Basic ${server.password}`,
+		},
+		body: form,
+	});
+	if (!resp.ok) {
+		throw new Error(await resp.text());
+	}
+	return resp.json();
+}
+
+/**
+ * Finds the file references in a tool result, such as those returned by ShareFile.
+ *
+ * @param {any} content - The content of a tool result.
+ * @returns {Array<object>}
+ */
+export function findFileReferences(content) {
+	if (Array.isArray(content)) {
+		return content.flatMap(findFileReferences);
+	}
+	if (!content || typeof content !== 'object') {
+		return [];
+	}
+	if (
+		content.type === 'file' &&
+		typeof content.url === 'string' &&
+		content.url.startsWith('/files/')
+	) {
+		return [content];
+	}
+	return Object.values(content).flatMap(findFileReferences);
+}
+
+// Types of files that are shown as they are. Others, such as HTML or SVG, could run scripts with
+// access to the app, so they are shown as text.
+const inlineTypes = /^(image\/(png|jpeg|gif|webp|avif|bmp)|audio\/.*|video\/.*|application\/pdf|text\/plain)(;|$)/;
+
+/**
+ * Opens a referenced file in a new tab, or saves it. Links can't send the Authorization header,
+ * and the password must not end up in URLs, so the file is fetched here and opened as a blob.
+ *
+ * @param {object} ref - The file reference.
+ * @param {boolean} download - Whether the file should be saved rather than shown.
+ */
+export async function openFile(ref, download = false) {
+	// Open the tab before fetching, while the click still allows popups.
+	const tab = download ? null : window.open('', '_blank');
+	try {
+		const server = get(remoteServer);
+		const resp = await fetch(new URL(ref.url, server.address), {
+			headers: {
+				Authorization: `Basic ${server.password}`,
+			},
+		});
+		if (!resp.ok) {
+			throw new Error(await resp.text());
+		}
+		let blob = await resp.blob();
+		if (!download && !inlineTypes.test(blob.type)) {
+			blob = new Blob([blob], { type: 'text/plain' });
+		}
+		const url = URL.createObjectURL(blob);
+		// Give the tab or the download time to load it.
+		setTimeout(() => URL.revokeObjectURL(url), 60_000);
+		if (tab) {
+			tab.opener = null;
+			tab.location = url;
+			return;
+		}
+		const a = document.createElement('a');
+		a.href = url;
+		a.download = ref.name;
+		a.click();
+	} catch (err) {
+		if (tab) {
+			tab.close();
+		}
+		console.error('Failed to open file:', err);
+	}
+}
+
+/**
+ * Opens a WebSocket to the tool server. Browsers can't set headers on WebSockets, so the password
+ * is offered as a subprotocol rather than put in the URL.
+ *
+ * @param {string} path - The path of the WebSocket on the server.
+ * @returns {WebSocket}
+ */
+export function openServerSocket(path) {
+	const server = get(remoteServer);
+	const url = new URL(path, server.address);
+	url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:';
+	const protocols = ['llum'];
+	if (server.password) {
+		const bytes = new TextEncoder().encode(server.password);
+		const encoded = btoa(String.fromCharCode(...bytes))
+			.replace(/\+/g, '-')
+			.replace(/\//g, '_')
+			.replace(/=+$/, '');
+		protocols.push(`auth.${encoded}`);
+	}
+	return new WebSocket(url, protocols);
+}
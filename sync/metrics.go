@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llum_sync_requests_total",
+		Help: "Number of requests handled, by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llum_sync_request_duration_seconds",
+		Help:    "Time taken to handle requests, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	requestSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llum_sync_request_size_bytes",
+		Help:    "Size of request payloads, by endpoint.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"endpoint"})
+
+	responseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llum_sync_response_size_bytes",
+		Help:    "Size of response payloads, by endpoint.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"endpoint"})
+
+	storageSaveDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "llum_sync_storage_save_duration_seconds",
+		Help:    "Time taken to save storage.json.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(storageCollector{})
+}
+
+// storageCollector reports the contents of the in-memory storage at scrape time.
+type storageCollector struct{}
+
+var (
+	usersDesc = prometheus.NewDesc(
+		"llum_sync_users",
+		"Number of users with stored data.",
+		nil, nil,
+	)
+	itemsDesc = prometheus.NewDesc(
+		"llum_sync_items",
+		"Number of stored items, excluding tombstones, by kind.",
+		[]string{"kind"}, nil,
+	)
+	tombstonesDesc = prometheus.NewDesc(
+		"llum_sync_tombstones",
+		"Number of stored tombstones, by kind.",
+		[]string{"kind"}, nil,
+	)
+)
+
+func (storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersDesc
+	ch <- itemsDesc
+	ch <- tombstonesDesc
+}
+
+func (storageCollector) Collect(ch chan<- prometheus.Metric) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var conversations, messages, deletedConversations, deletedMessages int
+	for _, userData := range storage {
+		for _, conv := range userData.Conversations {
+			if deleted, ok := conv["deleted"].(bool); ok && deleted {
+				deletedConversations++
+			} else {
+				conversations++
+			}
+		}
+		for _, msg := range userData.Messages {
+			if deleted, ok := msg["deleted"].(bool); ok && deleted {
+				deletedMessages++
+			} else {
+				messages++
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(len(storage)))
+	ch <- prometheus.MustNewConstMetric(itemsDesc, prometheus.GaugeValue, float64(conversations), "conversation")
+	ch <- prometheus.MustNewConstMetric(itemsDesc, prometheus.GaugeValue, float64(messages), "message")
+	ch <- prometheus.MustNewConstMetric(tombstonesDesc, prometheus.GaugeValue, float64(deletedConversations), "conversation")
+	ch <- prometheus.MustNewConstMetric(tombstonesDesc, prometheus.GaugeValue, float64(deletedMessages), "message")
+}
+
+// metricsMiddleware records request counts, durations and payload sizes per endpoint
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		// Use the route pattern rather than the path, to keep the label set bounded
+		endpoint := chi.RouteContext(r.Context()).RoutePattern()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		requestsTotal.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+		requestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		requestSize.WithLabelValues(endpoint).Observe(float64(body.n))
+		responseSize.WithLabelValues(endpoint).Observe(float64(ww.BytesWritten()))
+	})
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Conversation represents a chat conversation containing multiple messages
@@ -130,6 +131,10 @@ func main() {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(metricsMiddleware)
+
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler())
 
 	// Add a simple health check endpoint
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -655,6 +660,11 @@ func parseBody(r *http.Request, v interface{}) error {
 
 // saveStorageToFile persists the in-memory storage to a file
 func saveStorageToFile() {
+	start := time.Now()
+	defer func() {
+		storageSaveDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	mutex.RLock()
 	defer mutex.RUnlock()
 